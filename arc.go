@@ -3,6 +3,9 @@ package shift
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
 
 	"github.com/luno/jettison/errors"
 	"github.com/luno/jettison/j"
@@ -10,9 +13,16 @@ import (
 	"github.com/luno/reflex/rsql"
 )
 
-// NewArcFSM returns a new ArcFSM builder.
-func NewArcFSM(events eventInserter[int64], opts ...option) arcbuilder {
-	fsm := ArcFSM{
+// NewArcFSM returns a new ArcFSM builder that supports a user table with an
+// int64 primary key.
+func NewArcFSM(events EventInserter[int64], opts ...option) arcbuilder[int64] {
+	return NewGenArcFSM[int64](events, opts...)
+}
+
+// NewGenArcFSM returns a new ArcFSM builder. The type T should match the type
+// of the user table's primary key.
+func NewGenArcFSM[T primary](events EventInserter[T], opts ...option) arcbuilder[T] {
+	fsm := GenArcFSM[T]{
 		updates: make(map[int][]tuple),
 		events:  events,
 	}
@@ -20,26 +30,30 @@ func NewArcFSM(events eventInserter[int64], opts ...option) arcbuilder {
 	for _, opt := range opts {
 		opt(&fsm.options)
 	}
+	fsm.buildErr = checkOptions[T](fsm.options)
 
-	return arcbuilder(fsm)
+	return arcbuilder[T](fsm)
 }
 
-type arcbuilder ArcFSM
+type arcbuilder[T primary] GenArcFSM[T]
 
-func (b arcbuilder) Insert(st Status, inserter Inserter[int64]) arcbuilder {
+func (b arcbuilder[T]) Insert(st Status, inserter Inserter[T]) arcbuilder[T] {
 	b.inserts = append(b.inserts, tuple{
 		Status: st.ShiftStatus(),
 		Type:   inserter,
+		To:     st,
 	})
 	return b
 }
 
-func (b arcbuilder) Update(from, to Status, updater Updater[int64]) arcbuilder {
+func (b arcbuilder[T]) Update(from, to Status, updater Updater[T]) arcbuilder[T] {
 	tups := b.updates[from.ShiftStatus()]
 
 	tups = append(tups, tuple{
 		Status: to.ShiftStatus(),
 		Type:   updater,
+		From:   from,
+		To:     to,
 	})
 
 	b.updates[from.ShiftStatus()] = tups
@@ -47,102 +61,274 @@ func (b arcbuilder) Update(from, to Status, updater Updater[int64]) arcbuilder {
 	return b
 }
 
-func (b arcbuilder) Build() *ArcFSM {
-	fsm := ArcFSM(b)
-	return &fsm
+// Build returns the built ArcFSM. It panics if the options are invalid, see BuildE.
+func (b arcbuilder[T]) Build() *GenArcFSM[T] {
+	fsm, err := b.BuildE()
+	if err != nil {
+		// Ok to panic since it is build time.
+		panic(fmt.Sprintf("%+v", err))
+	}
+	return fsm
+}
+
+// BuildE returns the built ArcFSM or an ErrInvalidFSM error if the options are
+// invalid. ArcFSMs allow arbitrary transitions, so unlike the FSM builder's
+// BuildE, the transitions are not validated.
+func (b arcbuilder[T]) BuildE() (*GenArcFSM[T], error) {
+	if b.buildErr != nil {
+		return nil, b.buildErr
+	}
+	fsm := GenArcFSM[T](b)
+	return &fsm, nil
 }
 
 type tuple struct {
 	Status int
 	Type   interface{}
+
+	// From and To are the statuses of the transition, From is nil for inserts.
+	From Status
+	To   Status
+}
+
+// expectedTypes returns the comma separated request types of the tuples
+// with the status, empty if there are none.
+func expectedTypes(tl []tuple, st int) string {
+	var names []string
+	for _, tup := range tl {
+		if tup.Status == st {
+			names = append(names, typeString(reflect.TypeOf(tup.Type)))
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// nextStatuses returns the distinct to statuses of the update tuples ordered
+// by ShiftStatus.
+func nextStatuses(tl []tuple) []Status {
+	var res []Status
+	seen := make(map[int]bool)
+	for _, tup := range tl {
+		if seen[tup.Status] {
+			continue
+		}
+		seen[tup.Status] = true
+		res = append(res, tup.To)
+	}
+	sortStatuses(res)
+	return res
 }
 
-// ArcFSM is a defined Finite-State-Machine that allows specific mutations of
+type ArcFSM = GenArcFSM[int64]
+
+// GenArcFSM is a defined Finite-State-Machine that allows specific mutations of
 // the domain model in the underlying sql table via inserts and updates.
 // All mutations update the status of the model, mutates some fields and
 // inserts a reflex event.
 //
-// ArcFSM doesn't have the restriction of FSM and can be defined with arbitrary transitions.
-type ArcFSM struct {
+// The type of the GenArcFSM is the type of the primary key used by the user table.
+//
+// GenArcFSM doesn't have the restriction of FSM and can be defined with arbitrary transitions.
+type GenArcFSM[T primary] struct {
 	options
-	events  eventInserter[int64]
+	events  EventInserter[T]
 	inserts []tuple
 	updates map[int][]tuple
+
+	// buildErr is the error of invalid options returned by BuildE.
+	buildErr error
 }
 
-func (fsm *ArcFSM) Insert(ctx context.Context, dbc *sql.DB, st Status, inserter Inserter[int64]) (int64, error) {
-	tx, err := dbc.Begin()
-	if err != nil {
-		return 0, err
+func (fsm *GenArcFSM[T]) Insert(ctx context.Context, dbc Beginner, st Status, inserter Inserter[T]) (_ T, err error) {
+	ctx, end := fsm.startSpan(ctx, "shift.Insert", nil, st)
+	defer func() { end(err) }()
+	ctx, done := fsm.observe(ctx, nil, st)
+	defer func() { done(err) }()
+
+	// Fail fast without starting a transaction.
+	if err := fsm.checkInsert(st, inserter); err != nil {
+		var zeroT T
+		return zeroT, err
 	}
-	defer tx.Rollback()
 
-	id, notify, err := fsm.InsertTx(ctx, tx, st, inserter)
+	var id T
+	err = fsm.runTx(ctx, dbc, func(ctx context.Context, tx *sql.Tx) (notify rsql.NotifyFunc, err error) {
+		id, notify, err = fsm.InsertTx(ctx, tx, st, inserter)
+		return notify, err
+	})
 	if err != nil {
-		return 0, err
+		var zeroT T
+		return zeroT, err
 	}
+	return id, nil
+}
 
-	err = tx.Commit()
-	if err != nil {
-		return 0, err
+func (fsm *GenArcFSM[T]) InsertTx(ctx context.Context, tx *sql.Tx, st Status, inserter Inserter[T]) (T, rsql.NotifyFunc, error) {
+	if err := fsm.checkInsert(st, inserter); err != nil {
+		var zeroT T
+		return zeroT, nil, err
 	}
 
-	notify()
-	return id, nil
+	var (
+		id     T
+		notify rsql.NotifyFunc
+	)
+	err := fsm.savepoint(ctx, tx, func() (err error) {
+		id, notify, err = insertTx(ctx, tx, st, inserter, fsm.events, reflex.EventType(st), fsm.options)
+		return err
+	})
+	if err != nil {
+		var zeroT T
+		return zeroT, nil, err
+	}
+	return id, notify, nil
 }
 
-func (fsm *ArcFSM) InsertTx(ctx context.Context, tx *sql.Tx, st Status, inserter Inserter[int64]) (int64, rsql.NotifyFunc, error) {
-	var found bool
+// checkInsert returns an error if the inserter can't be used to insert in the status.
+func (fsm *GenArcFSM[T]) checkInsert(st Status, inserter Inserter[T]) error {
 	for _, tup := range fsm.inserts {
 		if tup.Status == st.ShiftStatus() && sameType(tup.Type, inserter) {
-			found = true
-			break
+			return nil
 		}
 	}
-	if !found {
-		return 0, nil, errors.Wrap(ErrInvalidStateTransition, "invalid insert status and inserter", j.KV("status", st.ShiftStatus()))
-	}
-
-	return insertTx(ctx, tx, st, inserter, fsm.events, reflex.EventType(st), fsm.options)
+	return errors.Wrap(ErrInvalidStateTransition, "invalid insert status and inserter", j.MKV{
+		"to":       fmt.Sprintf("%v", st),
+		"expected": expectedTypes(fsm.inserts, st.ShiftStatus()),
+		"provided": typeString(reflect.TypeOf(inserter)),
+	})
 }
 
-func (fsm *ArcFSM) Update(ctx context.Context, dbc *sql.DB, from, to Status, updater Updater[int64]) error {
-	tx, err := dbc.Begin()
-	if err != nil {
+func (fsm *GenArcFSM[T]) Update(ctx context.Context, dbc Beginner, from, to Status, updater Updater[T]) (err error) {
+	ctx, end := fsm.startSpan(ctx, "shift.Update", from, to)
+	defer func() { end(err) }()
+	ctx, done := fsm.observe(ctx, from, to)
+	defer func() { done(err) }()
+
+	// Fail fast without starting a transaction.
+	if err := fsm.checkUpdate(from, to, updater); err != nil {
 		return err
 	}
-	defer tx.Rollback()
 
-	notify, err := fsm.UpdateTx(ctx, tx, from, to, updater)
-	if err != nil {
-		return err
+	return fsm.runTx(ctx, dbc, func(ctx context.Context, tx *sql.Tx) (rsql.NotifyFunc, error) {
+		return fsm.UpdateTx(ctx, tx, from, to, updater)
+	})
+}
+
+func (fsm *GenArcFSM[T]) UpdateTx(ctx context.Context, tx *sql.Tx, from, to Status, updater Updater[T]) (rsql.NotifyFunc, error) {
+	if err := fsm.checkUpdate(from, to, updater); err != nil {
+		return nil, err
 	}
 
-	err = tx.Commit()
-	if err != nil {
+	var notify rsql.NotifyFunc
+	err := fsm.savepoint(ctx, tx, func() (err error) {
+		notify, err = updateTx(ctx, tx, from, to, updater, fsm.events, reflex.EventType(to), "", fsm.options)
 		return err
+	})
+	if err != nil {
+		return nil, err
 	}
-
-	notify()
-	return nil
+	return notify, nil
 }
 
-func (fsm *ArcFSM) UpdateTx(ctx context.Context, tx *sql.Tx, from, to Status, updater Updater[int64]) (rsql.NotifyFunc, error) {
+// checkUpdate returns an error if the updater can't be used to update from
+// and to the statuses.
+func (fsm *GenArcFSM[T]) checkUpdate(from, to Status, updater Updater[T]) error {
 	tl, ok := fsm.updates[from.ShiftStatus()]
 	if !ok {
-		return nil, errors.Wrap(ErrInvalidStateTransition, "invalid update from status", j.KV("status", from.ShiftStatus()))
+		return errors.Wrap(ErrInvalidStateTransition, "invalid update from status", j.MKV{"from": fmt.Sprintf("%v", from), "to": fmt.Sprintf("%v", to)})
 	}
 
-	var found bool
+	var declared bool // An arc from the from to the to status exists.
 	for _, tup := range tl {
-		if tup.Status == to.ShiftStatus() && sameType(tup.Type, updater) {
-			found = true
-			break
+		if tup.Status != to.ShiftStatus() {
+			continue
+		}
+		if sameType(tup.Type, updater) {
+			return nil
+		}
+		declared = true
+	}
+	kv := j.MKV{
+		"from":     fmt.Sprintf("%v", from),
+		"to":       fmt.Sprintf("%v", to),
+		"valid":    fmt.Sprintf("%v", nextStatuses(tl)),
+		"expected": expectedTypes(tl, to.ShiftStatus()),
+		"provided": typeString(reflect.TypeOf(updater)),
+	}
+	if from.ShiftStatus() == to.ShiftStatus() && !declared {
+		return errors.Wrap(ErrAlreadyInState, "invalid update to status and updater", kv)
+	}
+	return errors.Wrap(ErrInvalidStateTransition, "invalid update to status and updater", kv)
+}
+
+// IsValidTransition returns true if the ArcFSM allows updating from and to the
+// statuses with any updater. It returns false for unknown statuses.
+func (fsm *GenArcFSM[T]) IsValidTransition(from, to Status) bool {
+	for _, tup := range fsm.updates[from.ShiftStatus()] {
+		if tup.Status == to.ShiftStatus() {
+			return true
 		}
 	}
-	if !found {
-		return nil, errors.Wrap(ErrInvalidStateTransition, "invalid update to status and updater", j.KV("status", from.ShiftStatus()))
+	return false
+}
+
+// InsertStatuses returns the distinct insert statuses of the ArcFSM ordered by
+// ShiftStatus.
+func (fsm *GenArcFSM[T]) InsertStatuses() []Status {
+	var res []Status
+	seen := make(map[int]bool)
+	for _, tup := range fsm.inserts {
+		if seen[tup.Status] {
+			continue
+		}
+		seen[tup.Status] = true
+		res = append(res, tup.To)
 	}
+	sortStatuses(res)
+	return res
+}
 
-	return updateTx(ctx, tx, from, to, updater, fsm.events, reflex.EventType(to), fsm.options)
+// States returns the distinct statuses of the ArcFSM's inserts and updates
+// ordered by ShiftStatus.
+func (fsm *GenArcFSM[T]) States() []Status {
+	var res []Status
+	seen := make(map[int]bool)
+	add := func(st Status) {
+		if seen[st.ShiftStatus()] {
+			return
+		}
+		seen[st.ShiftStatus()] = true
+		res = append(res, st)
+	}
+	for _, tup := range fsm.inserts {
+		add(tup.To)
+	}
+	for _, tl := range fsm.updates {
+		for _, tup := range tl {
+			add(tup.From)
+			add(tup.To)
+		}
+	}
+	sortStatuses(res)
+	return res
+}
+
+// Transitions returns the distinct update transitions of the ArcFSM ordered
+// by the ShiftStatus of the from and then the to status. Transitions allowed
+// for multiple updaters are only returned once.
+func (fsm *GenArcFSM[T]) Transitions() []Transition {
+	var res []Transition
+	seen := make(map[[2]int]bool)
+	for from, tl := range fsm.updates {
+		for _, tup := range tl {
+			key := [2]int{from, tup.Status}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			res = append(res, Transition{From: tup.From, To: tup.To})
+		}
+	}
+	sortTransitions(res)
+	return res
 }
@@ -2,10 +2,15 @@ package shift_test
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
+	"github.com/luno/jettison/errors"
+	"github.com/luno/jettison/j"
 	"github.com/luno/jettison/jtest"
+	"github.com/luno/reflex"
+	"github.com/luno/reflex/rsql"
 	"github.com/stretchr/testify/require"
 
 	"github.com/luno/shift"
@@ -53,12 +58,187 @@ func TestArcFSM(t *testing.T) {
 	err = afsm.Update(ctx, dbc, StatusUpdate, StatusInit, move{ID: id1})
 	jtest.RequireNil(t, err)
 
-	assertUser(t, dbc, events.ToStream(dbc), usersTable, id1, "insert", t0, Currency{}, 1, 2, 1)
+	assertUser(t, dbc, events, usersTable, id1, "insert", t0, Currency{}, 1, 2, 1)
 
 	// Init another model
 	id2, err := afsm.Insert(ctx, dbc, StatusInit, insert2{Name: "insert2", DateOfBirth: t0, Amount: amount})
 	jtest.RequireNil(t, err)
 	require.Equal(t, int64(2), id2)
 
-	assertUser(t, dbc, events.ToStream(dbc), usersTable, id2, "insert2", t0, amount, 1)
+	assertUser(t, dbc, events, usersTable, id2, "insert2", t0, amount, 1)
+}
+
+func TestArcFSM_Introspection(t *testing.T) {
+	require.Equal(t, []shift.Status{StatusInit}, afsm.InsertStatuses())
+	require.Equal(t, []shift.Status{StatusInit, StatusUpdate}, afsm.States())
+	require.Equal(t, []shift.Transition{
+		{From: StatusInit, To: StatusUpdate},
+		{From: StatusUpdate, To: StatusInit},
+	}, afsm.Transitions())
+}
+
+func TestArcFSM_Graph(t *testing.T) {
+	require.Equal(t, shift.Graph{
+		InsertStatus: StatusInit,
+		Inserts: []shift.Edge{
+			{To: StatusInit, ReqType: "shift_test.insert"},
+			{To: StatusInit, ReqType: "shift_test.insert2"},
+		},
+		Transitions: []shift.Edge{
+			{From: StatusInit, To: StatusUpdate, ReqType: "shift_test.move"},
+			{From: StatusUpdate, To: StatusInit, ReqType: "shift_test.move"},
+		},
+	}, afsm.Graph())
+}
+
+func TestArcFSM_GraphNilType(t *testing.T) {
+	fsm := shift.NewArcFSM(events).
+		Insert(StatusInit, insert{}).
+		Update(StatusInit, StatusUpdate, nil).
+		Build()
+
+	require.Equal(t, []shift.Edge{
+		{From: StatusInit, To: StatusUpdate, ReqType: "<nil>"},
+	}, fsm.Graph().Transitions)
+}
+
+func TestArcFSM_IsValidTransition(t *testing.T) {
+	require.True(t, afsm.IsValidTransition(StatusInit, StatusUpdate))
+	require.True(t, afsm.IsValidTransition(StatusUpdate, StatusInit))
+	require.False(t, afsm.IsValidTransition(StatusUpdate, StatusComplete))
+	require.False(t, afsm.IsValidTransition(TestStatus(99), StatusInit))
+}
+
+func TestArcFSM_InvalidTransition(t *testing.T) {
+	_, err := afsm.UpdateTx(context.Background(), nil, StatusInit, StatusComplete, move{ID: 1})
+	jtest.Require(t, shift.ErrInvalidStateTransition, err)
+	jtest.AssertKeyValues(t, j.MKS{
+		"from":     "1",
+		"to":       "3",
+		"valid":    fmt.Sprintf("%v", []shift.Status{StatusUpdate}),
+		"expected": "",
+		"provided": "shift_test.move",
+	}, err)
+	require.False(t, errors.Is(err, shift.ErrAlreadyInState))
+
+	_, err = afsm.UpdateTx(context.Background(), nil, StatusInit, StatusInit, move{ID: 1})
+	jtest.Require(t, shift.ErrAlreadyInState, err)
+	jtest.Require(t, shift.ErrInvalidStateTransition, err)
+}
+
+func TestArcFSM_SelfLoopInvalidType(t *testing.T) {
+	selfLoop := shift.NewArcFSM(events).
+		Insert(StatusInit, insert{}).
+		Update(StatusInit, StatusInit, move{}).
+		Build()
+
+	_, err := selfLoop.UpdateTx(context.Background(), nil, StatusInit, StatusInit, complete{ID: 1})
+	jtest.Require(t, shift.ErrInvalidStateTransition, err)
+	require.False(t, errors.Is(err, shift.ErrAlreadyInState))
+	jtest.AssertKeyValues(t, j.MKS{
+		"expected": "shift_test.move",
+		"provided": "shift_test.complete",
+	}, err)
+}
+
+func TestArcFSM_BuildE(t *testing.T) {
+	// ArcFSMs without inserts or with updates from statuses that are only
+	// reached by rows inserted elsewhere are valid.
+	_, err := shift.NewArcFSM(events).
+		Update(StatusInit, StatusUpdate, move{}).
+		BuildE()
+	jtest.RequireNil(t, err)
+
+	_, err = shift.NewArcFSM(events).
+		Insert(StatusInit, insert{}).
+		Update(StatusInit, StatusUpdate, move{}).
+		Update(StatusComplete, StatusUpdate, move{}).
+		BuildE()
+	jtest.RequireNil(t, err)
+
+	require.NotPanics(t, func() {
+		shift.NewArcFSM(events).
+			Update(StatusComplete, StatusUpdate, move{}).
+			Build()
+	})
+}
+
+func TestArcFSM_WithValidation(t *testing.T) {
+	dbc := setup(t)
+	ctx := context.Background()
+
+	fsm := shift.NewArcFSM(events, shift.WithValidation()).
+		Insert(s(1), i{}).
+		Update(s(1), s(2), u{}).
+		Update(s(2), s(2), u{}). // Allow 2 -> 2 update, validation will fail.
+		Build()
+
+	// First insert is ok
+	id, err := fsm.Insert(ctx, dbc, s(1), i{I3: time.Now()})
+	jtest.RequireNil(t, err)
+
+	// Second insert fails.
+	_, err = fsm.Insert(ctx, dbc, s(1), i{I3: time.Now()})
+	jtest.Require(t, errInsertInvalid, err)
+
+	// Update from 1 -> 2 is ok
+	err = fsm.Update(ctx, dbc, s(1), s(2), u{ID: id})
+	jtest.RequireNil(t, err)
+
+	// Update from 2 -> 2 fails
+	err = fsm.Update(ctx, dbc, s(2), s(2), u{ID: id, U1: true})
+	jtest.Require(t, errUpdateInvalid, err)
+}
+
+func TestArcFSM_WithMetadata(t *testing.T) {
+	dbc := setup(t)
+	ctx := context.Background()
+
+	events := events.Clone(rsql.WithEventMetadataField("metadata"))
+	fsm := shift.NewArcFSM(events, shift.WithMetadata()).
+		Insert(s(1), i{}).
+		Update(s(1), s(2), u{}).
+		Build()
+
+	id, err := fsm.Insert(ctx, dbc, s(1), i{I3: time.Now()})
+	jtest.RequireNil(t, err)
+	err = fsm.Update(ctx, dbc, s(1), s(2), u{ID: id})
+	jtest.RequireNil(t, err)
+
+	sc, err := events.ToStream(dbc)(ctx, "")
+	jtest.RequireNil(t, err)
+
+	for _, st := range []shift.Status{s(1), s(2)} {
+		e, err := sc.Recv()
+		jtest.RequireNil(t, err)
+		require.True(t, reflex.IsType(st, e.Type))
+		require.Equal(t, e.ForeignID, string(e.MetaData))
+	}
+}
+
+// afsmStr defines an ArcFSM for a user table with a string primary key.
+var afsmStr = shift.NewGenArcFSM[string](eventsStr).
+	Insert(StatusInit, insertStr{}).
+	Update(StatusInit, StatusUpdate, completeStr{}).
+	Update(StatusUpdate, StatusInit, completeStr{}).
+	Build()
+
+func TestArcFSM_String(t *testing.T) {
+	dbc := setup(t)
+
+	t0 := time.Now().Truncate(time.Second)
+	ctx := context.Background()
+
+	// Init model
+	id, err := afsmStr.Insert(ctx, dbc, StatusInit, insertStr{ID: "abc", Name: "insert", DateOfBirth: t0})
+	jtest.RequireNil(t, err)
+	require.Equal(t, "abc", id)
+
+	// Move to Updated and back to Init
+	err = afsmStr.Update(ctx, dbc, StatusInit, StatusUpdate, completeStr{ID: id})
+	jtest.RequireNil(t, err)
+	err = afsmStr.Update(ctx, dbc, StatusUpdate, StatusInit, completeStr{ID: id})
+	jtest.RequireNil(t, err)
+
+	assertUser(t, dbc, eventsStr, usersStrTable, id, "insert", t0, Currency{}, 1, 2, 1)
 }
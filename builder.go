@@ -1,10 +1,51 @@
 package shift
 
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/luno/jettison/errors"
+	"github.com/luno/jettison/j"
+	"github.com/luno/reflex"
+	"github.com/luno/reflex/rsql"
+	"go.opentelemetry.io/otel/trace"
+)
+
 type option func(*options)
 
 type options struct {
-	withMetadata   bool
-	withValidation bool
+	withMetadata         bool
+	withValidation       bool
+	validationBefore     bool
+	withUniqueEventTypes bool
+	withCategoryMetadata bool
+	eventSharder         any
+	tracer               trace.Tracer
+	withMetrics          bool
+	metricsTable         string
+	table                string
+	idCol                string
+	typedMetadata        any
+	metadataMarshal      func(v any) ([]byte, error)
+	metadataUnmarshal    func(data []byte, v any) error
+	txOptions            *sql.TxOptions
+	retries              int
+	isRetryable          func(error) bool
+	clock                func() time.Time
+	observer             func(ctx context.Context, e TransitionEvent)
+	withSavepoints       bool
+	metadataFunc         func(ctx context.Context, id any, from, to Status) ([]byte, error)
+	eventsDB             Beginner
+	acyclic              bool
+	allowUnreachable     bool
+	notifier             func(next rsql.NotifyFunc)
 }
 
 // WithMetadata provides an option to enable event metadata with an FSM.
@@ -14,6 +55,17 @@ func WithMetadata() option {
 	}
 }
 
+// WithMetadataFunc provides an option to enable event metadata returned by fn
+// for inserters, updaters and deleters that don't implement the metadata
+// interfaces, ex. the current user from the context. The from status is nil
+// for inserts and the to status is nil for deletes. Requests implementing
+// MetadataInserter, MetadataUpdater or MetadataDeleter use those instead.
+func WithMetadataFunc(fn func(ctx context.Context, id any, from, to Status) ([]byte, error)) option {
+	return func(o *options) {
+		o.metadataFunc = fn
+	}
+}
+
 // WithValidation provides an option to enable insert/update validation.
 func WithValidation() option {
 	return func(o *options) {
@@ -21,15 +73,249 @@ func WithValidation() option {
 	}
 }
 
+// WithValidationBefore provides an option to enable validation like
+// WithValidation, but validating before the transition's event is inserted,
+// ex. for transitions that are mostly invalid. Updates and deletes are
+// validated before the row is changed, so Validate sees the current row.
+// Inserts are validated after the row is inserted (to provide the id).
+func WithValidationBefore() option {
+	return func(o *options) {
+		o.withValidation = true
+		o.validationBefore = true
+	}
+}
+
+// WithTypedMetadata provides an option to enable event metadata of type M with
+// an FSM. Inserters and updaters must implement TypedMetadataInserter or
+// TypedMetadataUpdater, the metadata is JSON encoded unless WithMetadataCodec
+// is provided. The type T must match the FSM's primary key type. Note that the
+// events of forced updates have the encoded ForcedMetadata string instead of
+// an M, so check GenFSM.IsForced before decoding event metadata into M.
+func WithTypedMetadata[T primary, M any]() option {
+	return func(o *options) {
+		o.withMetadata = true
+		o.typedMetadata = typedMetadata[T]{
+			insert: func(ctx context.Context, tx *sql.Tx, inserter Inserter[T], id T, st Status) (any, error) {
+				meta, ok := inserter.(TypedMetadataInserter[T, M])
+				if !ok {
+					return nil, errors.Wrap(ErrInvalidType, "inserter without typed metadata")
+				}
+				return meta.GetMetadata(ctx, tx, id, st)
+			},
+			update: func(ctx context.Context, tx *sql.Tx, updater Updater[T], from, to Status) (any, error) {
+				meta, ok := updater.(TypedMetadataUpdater[T, M])
+				if !ok {
+					return nil, errors.Wrap(ErrInvalidType, "updater without typed metadata")
+				}
+				return meta.GetMetadata(ctx, tx, from, to)
+			},
+			delete: func(ctx context.Context, tx *sql.Tx, deleter Deleter[T], from Status) (any, error) {
+				meta, ok := deleter.(TypedMetadataDeleter[T, M])
+				if !ok {
+					return nil, errors.Wrap(ErrInvalidType, "deleter without typed metadata")
+				}
+				return meta.GetMetadata(ctx, tx, from)
+			},
+			decode: func(data []byte, unmarshal func([]byte, any) error) (any, error) {
+				var meta M
+				if err := unmarshal(data, &meta); err != nil {
+					return nil, err
+				}
+				return meta, nil
+			},
+		}
+	}
+}
+
+// WithMetadataCodec provides an option to encode typed metadata with marshal
+// and decode it with unmarshal (ex. by History) instead of json.Marshal and
+// json.Unmarshal, see WithTypedMetadata.
+func WithMetadataCodec(marshal func(v any) ([]byte, error), unmarshal func(data []byte, v any) error) option {
+	return func(o *options) {
+		o.metadataMarshal = marshal
+		o.metadataUnmarshal = unmarshal
+	}
+}
+
+// WithUniqueEventTypes provides an option to ensure at build time that the
+// statuses of an FSM have distinct reflex event types, since consumers can't
+// distinguish transitions to statuses sharing an event type.
+func WithUniqueEventTypes() option {
+	return func(o *options) {
+		o.withUniqueEventTypes = true
+	}
+}
+
+// WithCategoryMetadata provides an option to insert the category of an update
+// transition as its event metadata, see Category. It can't be combined with
+// WithMetadata, WithTypedMetadata or WithMetadataFunc.
+func WithCategoryMetadata() option {
+	return func(o *options) {
+		o.withCategoryMetadata = true
+	}
+}
+
+// WithEventTableSharder provides an option to insert the reflex event of each
+// transition into the events table returned by fn for the entity id, instead of
+// the single events table provided to the FSM. The default events table is used
+// if fn returns nil. The type T must match the FSM's primary key type.
+//
+// Note that fn must be deterministic so that all events of an entity are inserted
+// into the same table, preserving per-entity ordering. There is no ordering across
+// tables, consumers must stream each table separately.
+func WithEventTableSharder[T primary](fn func(id T) EventInserter[T]) option {
+	return func(o *options) {
+		o.eventSharder = fn
+	}
+}
+
+// WithTracer provides an option to start a span for each Insert, InsertBatch,
+// Update, UpdateMany, ForceUpdate and Delete, named like
+// "shift.Update/<from>-><to>" with the statuses, entity id and table (see
+// WithTable) as attributes. The span ends when the transaction
+// commits or rolls back and records the error on failure.
+func WithTracer(tracer trace.Tracer) option {
+	return func(o *options) {
+		o.tracer = tracer
+	}
+}
+
+// WithMetrics provides an option to count each Insert, InsertBatch, Update,
+// UpdateMany, ForceUpdate and Delete by table, from and to status and result,
+// and to observe the duration of its transaction. Results distinguish commit,
+// validation and ErrRowCount failures. The table only labels the metrics, it
+// defaults to the WithTable table if empty. The metrics must be registered once
+// with RegisterMetrics.
+func WithMetrics(table string) option {
+	return func(o *options) {
+		o.withMetrics = true
+		o.metricsTable = table
+	}
+}
+
+// WithTxOptions provides an option to begin the transactions of Insert and
+// Update with the options, ex. to use sql.LevelSerializable isolation.
+func WithTxOptions(opts *sql.TxOptions) option {
+	return func(o *options) {
+		o.txOptions = opts
+	}
+}
+
+// WithSavepoints provides an option to run the transitions of InsertTx, UpdateTx
+// and DeleteTx within a savepoint of the provided transaction.
+// If a transition fails (ex. validation), only its changes are rolled back and
+// the caller's transaction remains usable. It requires a dialect supporting
+// SAVEPOINT, ROLLBACK TO SAVEPOINT and RELEASE SAVEPOINT, ex. MySQL/InnoDB.
+func WithSavepoints() option {
+	return func(o *options) {
+		o.withSavepoints = true
+	}
+}
+
+// WithEventsDB provides an option to insert the reflex events of Insert, Update
+// and Delete in a separate transaction on dbc, ex. an events table in another
+// database or schema, while the entity is mutated in the primary transaction.
+// The primary transaction is committed first, followed by the events
+// transaction (best-effort, not two-phase). If either fails before the primary
+// commit, both are rolled back. If committing the events fails after the
+// primary commit, the entity change is persisted without its events and an
+// error matching ErrEventsNotCommitted is returned, which isn't retried.
+// The *Tx methods and InsertBatch insert events in the provided transaction.
+func WithEventsDB(dbc Beginner) option {
+	return func(o *options) {
+		o.eventsDB = dbc
+	}
+}
+
+// WithRetry provides an option to retry the transaction of Insert, InsertBatch
+// and Update up to n times with backoff if it fails with an error classified as
+// retryable. If isRetryable is nil, IsRetryableMySQL is used. Note that the
+// whole transaction is retried, so inserters and updaters must not have side
+// effects outside it.
+func WithRetry(n int, isRetryable func(error) bool) option {
+	return func(o *options) {
+		o.retries = n
+		o.isRetryable = isRetryable
+		if isRetryable == nil {
+			o.isRetryable = IsRetryableMySQL
+		}
+	}
+}
+
+// WithClock provides an option to use the clock instead of time.Now for
+// FSM-level timestamps, ex. the span timestamps and metrics durations, to
+// freeze time in tests.
+func WithClock(clock func() time.Time) option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// WithTable provides an option to name the FSM's user table, reported to
+// observers and in metrics.
+func WithTable(table string) option {
+	return func(o *options) {
+		o.table = table
+	}
+}
+
+// WithIDColumn provides an option to name the primary key column of the FSM's
+// table (see WithTable) if it isn't "id", ex. for tables generated with
+// shiftgen -id_field. It is used by UpdateMany and by TestFSM to check the
+// table's timestamps.
+func WithIDColumn(col string) option {
+	return func(o *options) {
+		o.idCol = col
+	}
+}
+
+// WithObserver provides an option to call fn after every Insert, InsertBatch,
+// Update, UpdateMany, ForceUpdate and Delete is committed or rolled back, ex. for audit logging. The
+// observer can't affect the transition.
+func WithObserver(fn func(ctx context.Context, e TransitionEvent)) option {
+	return func(o *options) {
+		o.observer = fn
+	}
+}
+
+// WithAcyclic provides an option to make Build fail if the FSM has a cycle
+// (including a status transitioning to itself), for FSMs that must always
+// progress towards a terminal status. By default cycles are allowed.
+func WithAcyclic() option {
+	return func(o *options) {
+		o.acyclic = true
+	}
+}
+
+// WithUnreachableStatuses provides an option to not fail Build if some statuses
+// aren't reachable from an insert status, ex. while an FSM is under construction
+// or for statuses only set via ForceUpdate. By default unreachable statuses are
+// an error, see BuildE.
+func WithUnreachableStatuses() option {
+	return func(o *options) {
+		o.allowUnreachable = true
+	}
+}
+
+// WithNotifier provides an option to wrap the notify func returned for each
+// inserted event. Instead of notifying the events table directly, fn is called
+// with the notify func and may call it, defer it or fan it out, ex. to record
+// notification order in tests. By default notify is called directly.
+func WithNotifier(fn func(next rsql.NotifyFunc)) option {
+	return func(o *options) {
+		o.notifier = fn
+	}
+}
+
 // NewFSM returns a new FSM initer that supports a user table with an int64
 // primary key.
-func NewFSM(events eventInserter[int64], opts ...option) initer[int64] {
+func NewFSM(events EventInserter[int64], opts ...option) initer[int64] {
 	return NewGenFSM[int64](events, opts...)
 }
 
 // NewGenFSM returns a new FSM initer. The type T should match the type of the
 // user table's primary key.
-func NewGenFSM[T primary](events eventInserter[T], opts ...option) initer[T] {
+func NewGenFSM[T primary](events EventInserter[T], opts ...option) initer[T] {
 	fsm := GenFSM[T]{
 		states: make(map[int]status),
 		events: events,
@@ -38,6 +324,7 @@ func NewGenFSM[T primary](events eventInserter[T], opts ...option) initer[T] {
 	for _, opt := range opts {
 		opt(&fsm.options)
 	}
+	fsm.buildErr = checkOptions[T](fsm.options)
 
 	return initer[T](fsm)
 }
@@ -47,29 +334,47 @@ type initer[T primary] GenFSM[T]
 
 // Insert returns an FSM builder with the provided insert status.
 func (c initer[T]) Insert(st Status, inserter Inserter[T], next ...Status) builder[T] {
-	c.states[st.ShiftStatus()] = status{
+	return builder[T](c).Insert(st, inserter, next...)
+}
+
+// builder supports adding inserters and updaters to the FSM.
+type builder[T primary] GenFSM[T]
+
+// Insert returns an FSM builder with the provided additional insert status.
+// Each insert status requires a different inserter type, since the inserter
+// type selects the insert status.
+func (b builder[T]) Insert(st Status, inserter Inserter[T], next ...Status) builder[T] {
+	b = b.clone()
+	if _, has := b.states[st.ShiftStatus()]; has {
+		return b.fail(errors.Wrap(ErrInvalidFSM, "state already added", j.MKV{"status": fmt.Sprintf("%v", st)}))
+	}
+	for _, other := range b.insertStatuses {
+		if sameType(b.states[other.ShiftStatus()].req, inserter) {
+			return b.fail(errors.Wrap(ErrInvalidFSM, "inserter type already added", j.MKV{"status": fmt.Sprintf("%v", st)}))
+		}
+	}
+	b.states[st.ShiftStatus()] = status{
 		st:     st,
 		req:    inserter,
+		typ:    reflect.TypeOf(inserter),
 		t:      st,
-		insert: false,
+		insert: true,
 		next:   toMap(next),
 	}
-	c.insertStatus = st
-	return builder[T](c)
+	b.insertStatuses = append(b.insertStatuses, st)
+	return b
 }
 
-// builder supports adding an updater to the FSM.
-type builder[T primary] GenFSM[T]
-
 // Update returns an FSM builder with the provided status update added.
 func (b builder[T]) Update(st Status, updater Updater[T], next ...Status) builder[T] {
+	b = b.clone()
 	if _, has := b.states[st.ShiftStatus()]; has {
-		// Ok to panic since it is build time.
-		panic("state already added")
+		return b.fail(errors.Wrap(ErrInvalidFSM, "state already added", j.MKV{"status": fmt.Sprintf("%v", st)}))
 	}
 	b.states[st.ShiftStatus()] = status{
 		st:     st,
 		req:    updater,
+		typ:    reflect.TypeOf(updater),
 		t:      st,
 		insert: false,
 		next:   toMap(next),
@@ -77,16 +382,275 @@ func (b builder[T]) Update(st Status, updater Updater[T], next ...Status) builde
 	return b
 }
 
-// Build returns the built FSM.
+// Delete returns an FSM builder allowing entities in the from status to be
+// deleted with the deleter. A reflex event of the type is inserted for each
+// delete, unless it is nil. The from status must already be added.
+func (b builder[T]) Delete(from Status, deleter Deleter[T], event reflex.EventType) builder[T] {
+	b = b.clone()
+	s, ok := b.states[from.ShiftStatus()]
+	if !ok {
+		return b.fail(errors.Wrap(ErrInvalidFSM, "delete from status not added", j.MKV{"status": fmt.Sprintf("%v", from)}))
+	}
+	s.delete = &deletion{req: deleter, typ: reflect.TypeOf(deleter), t: event}
+	b.states[from.ShiftStatus()] = s
+	return b
+}
+
+// clone returns a copy of the builder that doesn't share its states with b,
+// so that builders derived from a common base don't affect each other.
+func (b builder[T]) clone() builder[T] {
+	b.states = maps.Clone(b.states)
+	b.insertStatuses = slices.Clip(b.insertStatuses)
+	return b
+}
+
+// fail returns the builder with the error returned by BuildE, unless it
+// already failed.
+func (b builder[T]) fail(err error) builder[T] {
+	if b.buildErr == nil {
+		b.buildErr = err
+	}
+	return b
+}
+
+// Build returns the built FSM. It panics if the FSM isn't valid, see BuildE.
 func (b builder[T]) Build() *GenFSM[T] {
+	fsm, err := b.BuildE()
+	if err != nil {
+		// Ok to panic since it is build time.
+		panic(fmt.Sprintf("%+v", err))
+	}
+	return fsm
+}
+
+// BuildE returns the built FSM or an ErrInvalidFSM error if a status or inserter
+// type was added twice, a next status wasn't added or a status isn't reachable
+// from an insert status (unless WithUnreachableStatuses), if it has a cycle
+// with WithAcyclic, or if the options are invalid, ex. typed metadata or an
+// event table sharder of another primary key type.
+func (b builder[T]) BuildE() (*GenFSM[T], error) {
+	if b.buildErr != nil {
+		return nil, b.buildErr
+	}
+	if err := checkStatuses(b.states, b.options); err != nil {
+		return nil, err
+	}
+	if err := checkTransitions(b.states, b.insertStatuses, b.allowUnreachable); err != nil {
+		return nil, err
+	}
+	if b.acyclic {
+		if err := checkAcyclic(b.states); err != nil {
+			return nil, err
+		}
+	}
 	fsm := GenFSM[T](b)
-	return &fsm
+	return &fsm, nil
+}
+
+// checkStatuses returns an error if a next status has the same ShiftStatus as
+// a different registered status, since the FSM identifies statuses by ShiftStatus.
+// It also ensures distinct reflex event types if WithUniqueEventTypes is set.
+func checkStatuses(states map[int]status, o options) error {
+	types := make(map[int]Status)
+	for _, s := range states {
+		for next := range s.next {
+			n, ok := states[next.ShiftStatus()]
+			if ok && n.st != next {
+				return errors.Wrap(ErrInvalidFSM, "status conflicts with registered status of same ShiftStatus",
+					j.MKV{"status": fmt.Sprintf("%v", next), "registered": fmt.Sprintf("%v", n.st)})
+			}
+		}
+
+		if !o.withUniqueEventTypes {
+			continue
+		}
+		if other, ok := types[s.t.ReflexType()]; ok {
+			return errors.Wrap(ErrInvalidFSM, "statuses have the same reflex event type",
+				j.MKV{"status": fmt.Sprintf("%v", s.st), "other": fmt.Sprintf("%v", other)})
+		}
+		types[s.t.ReflexType()] = s.st
+	}
+	return nil
+}
+
+// checkTransitions returns an error listing any next statuses that weren't
+// registered, or any registered statuses that aren't reachable from an insert
+// status unless allowUnreachable is set.
+func checkTransitions(states map[int]status, inserts []Status, allowUnreachable bool) error {
+	var dangling []string
+	for _, s := range states {
+		for next := range s.next {
+			if _, ok := states[next.ShiftStatus()]; !ok {
+				dangling = append(dangling, fmt.Sprintf("%v->%v", s.st, next))
+			}
+		}
+	}
+	if len(dangling) > 0 {
+		slices.Sort(dangling)
+		return errors.Wrap(ErrInvalidFSM, "next statuses not registered",
+			j.MKV{"transitions": strings.Join(dangling, ", ")})
+	}
+	if allowUnreachable {
+		return nil
+	}
+
+	reached := make(map[int]bool)
+	for _, st := range inserts {
+		reached[st.ShiftStatus()] = true
+	}
+	queue := slices.Clone(inserts)
+	for len(queue) > 0 {
+		s := states[queue[0].ShiftStatus()]
+		queue = queue[1:]
+		for next := range s.next {
+			if reached[next.ShiftStatus()] {
+				continue
+			}
+			reached[next.ShiftStatus()] = true
+			queue = append(queue, next)
+		}
+	}
+
+	var unreachable []Status
+	for st, s := range states {
+		if !reached[st] {
+			unreachable = append(unreachable, s.st)
+		}
+	}
+	if len(unreachable) > 0 {
+		sortStatuses(unreachable)
+		return errors.Wrap(ErrInvalidFSM, "statuses not reachable from insert statuses",
+			j.MKV{"statuses": fmt.Sprintf("%v", unreachable)})
+	}
+	return nil
+}
+
+// checkAcyclic returns an error including the path of the first cycle found
+// in the transitions of the states, if any.
+func checkAcyclic(states map[int]status) error {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	marks := make(map[int]int)
+	var path []Status
+
+	var visit func(st Status) []Status
+	visit = func(st Status) []Status {
+		switch marks[st.ShiftStatus()] {
+		case visited:
+			return nil
+		case visiting:
+			i := slices.IndexFunc(path, func(s Status) bool { return s.ShiftStatus() == st.ShiftStatus() })
+			return append(slices.Clone(path[i:]), st)
+		}
+
+		marks[st.ShiftStatus()] = visiting
+		path = append(path, st)
+		var next []Status
+		for n := range states[st.ShiftStatus()].next {
+			next = append(next, n)
+		}
+		sortStatuses(next)
+		for _, n := range next {
+			if cycle := visit(n); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		marks[st.ShiftStatus()] = visited
+		return nil
+	}
+
+	var all []Status
+	for _, s := range states {
+		all = append(all, s.st)
+	}
+	sortStatuses(all)
+	for _, st := range all {
+		if cycle := visit(st); cycle != nil {
+			var sl []string
+			for _, st := range cycle {
+				sl = append(sl, fmt.Sprintf("%v", st))
+			}
+			return errors.Wrap(ErrInvalidFSM, "cycle in acyclic fsm",
+				j.MKV{"cycle": strings.Join(sl, "->")})
+		}
+	}
+	return nil
+}
+
+// now returns the current time of the clock, defaulting to time.Now.
+func (o options) now() time.Time {
+	if o.clock == nil {
+		return time.Now()
+	}
+	return o.clock()
+}
+
+// idColumn returns the primary key column of the FSM's table, defaulting to "id".
+func (o options) idColumn() string {
+	if o.idCol == "" {
+		return "id"
+	}
+	return o.idCol
+}
+
+// typedMetadata gets the typed metadata of inserters and updaters of an FSM
+// with primary key type T.
+type typedMetadata[T primary] struct {
+	insert func(ctx context.Context, tx *sql.Tx, inserter Inserter[T], id T, st Status) (any, error)
+	update func(ctx context.Context, tx *sql.Tx, updater Updater[T], from, to Status) (any, error)
+	delete func(ctx context.Context, tx *sql.Tx, deleter Deleter[T], from Status) (any, error)
+	decode func(data []byte, unmarshal func([]byte, any) error) (any, error)
+}
+
+// marshalMetadata encodes typed metadata with the metadata codec, defaulting to JSON.
+func (o options) marshalMetadata(v any) ([]byte, error) {
+	if o.metadataMarshal != nil {
+		return o.metadataMarshal(v)
+	}
+	return json.Marshal(v)
+}
+
+// unmarshalMetadata decodes typed metadata with the metadata codec, defaulting to JSON.
+func (o options) unmarshalMetadata(data []byte, v any) error {
+	if o.metadataUnmarshal != nil {
+		return o.metadataUnmarshal(data, v)
+	}
+	return json.Unmarshal(data, v)
+}
+
+// checkOptions returns an ErrInvalidFSM error if the options are invalid for
+// the primary key type T, ex. an event table sharder of another key type, or
+// conflict with each other.
+func checkOptions[T primary](o options) error {
+	if o.eventSharder != nil {
+		if _, ok := o.eventSharder.(func(T) EventInserter[T]); !ok {
+			return errors.Wrap(ErrInvalidFSM, "event table sharder doesn't match primary key type")
+		}
+	}
+	if o.typedMetadata != nil {
+		if _, ok := o.typedMetadata.(typedMetadata[T]); !ok {
+			return errors.Wrap(ErrInvalidFSM, "typed metadata doesn't match primary key type")
+		}
+	}
+	// The category would replace the metadata of other sources.
+	if o.withCategoryMetadata && (o.withMetadata || o.typedMetadata != nil || o.metadataFunc != nil) {
+		return errors.Wrap(ErrInvalidFSM, "category metadata can't be combined with other metadata options")
+	}
+	return nil
 }
 
-func toMap(sl []Status) map[Status]bool {
-	m := make(map[Status]bool)
+func toMap(sl []Status) map[Status]transition {
+	m := make(map[Status]transition)
 	for _, s := range sl {
-		m[s] = true
+		var t transition
+		if c, ok := s.(categorised); ok {
+			s = c.Status
+			t.category = c.category
+		}
+		m[s] = t
 	}
 	return m
 }
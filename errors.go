@@ -11,6 +11,46 @@ import (
 // state anymore.
 var ErrRowCount = errors.New("unexpected number of rows updated", j.C("ERR_fcb8af57223847b1"))
 
+// ErrRowNotFound is returned by generated shift code (with -classify_row_count)
+// when an update failed since the row doesn't exist. It wraps ErrRowCount.
+var ErrRowNotFound = errors.Wrap(ErrRowCount, "row not found", j.C("ERR_4a0e36bd3f1c7d92"))
+
+// ErrStatusMismatch is returned by generated shift code (with -classify_row_count)
+// when an update failed since the row isn't in the from status anymore. The
+// current status is included in the error. It wraps ErrRowCount.
+var ErrStatusMismatch = errors.Wrap(ErrRowCount, "status mismatch", j.C("ERR_9c2b71e05d8a46f3"))
+
+// ErrVersionConflict is returned by generated shift code for updaters with
+// a version field when the row is in the from status but its version doesn't
+// match the expected version, i.e. the entity was updated concurrently.
+// It wraps ErrRowCount.
+var ErrVersionConflict = errors.Wrap(ErrRowCount, "version conflict", j.C("ERR_6c0f2a93e1d84b57"))
+
+// ErrDuplicate is returned by inserts that failed due to a duplicate key
+// (MySQL error 1062). The driver error remains in the error chain.
+var ErrDuplicate = errors.New("duplicate entry", j.C(codeDuplicate))
+
+const codeDuplicate = "ERR_3e6d0c1f9a7b4528"
+
+// ErrAlreadyInserted is returned with the existing id by inserts generated with
+// shiftgen -idempotent_insert if the row already exists, ex. for a replayed event.
+// Insert and InsertTx treat it as success, but don't insert an event since the
+// row isn't changed. It wraps ErrDuplicate.
+var ErrAlreadyInserted = errors.Wrap(ErrDuplicate, "already inserted", j.C("ERR_e2a97c4d105b8f36"))
+
+// ErrEventsNotCommitted is returned by Insert, Update and Delete with WithEventsDB
+// if committing the events transaction failed after the entity's transaction was
+// committed, i.e. the transition is persisted without its reflex events.
+// The driver error remains in the error chain.
+var ErrEventsNotCommitted = errors.New("events not committed", j.C(codeEventsNotCommitted))
+
+const codeEventsNotCommitted = "ERR_7d14e9b0c2a35f68"
+
+// ErrHistoryUnavailable is returned by History if the events of the entity
+// can't be streamed, i.e. the EventInserter isn't a rsql.EventsTable or
+// rsql.EventsTableInt.
+var ErrHistoryUnavailable = errors.New("history unavailable", j.C("ERR_a58e2c07d41b93f6"))
+
 // ErrUnknownStatus indicates that the status hasn't been registered
 // with the FSM.
 var ErrUnknownStatus = errors.New("unknown status", j.C("ERR_198a4c2d8a654b17"))
@@ -19,6 +59,16 @@ var ErrUnknownStatus = errors.New("unknown status", j.C("ERR_198a4c2d8a654b17"))
 // registered with the FSM.
 var ErrInvalidStateTransition = errors.New("invalid state transition", j.C("ERR_be8211db784bfb67"))
 
+// ErrAlreadyInState is returned by UpdateTx when the from and to statuses are
+// the same, but the status isn't allowed to transition to itself (a declared
+// self-loop is a valid transition), ex. for a double-submitted request.
+// It wraps ErrInvalidStateTransition.
+var ErrAlreadyInState = errors.Wrap(ErrInvalidStateTransition, "already in state", j.C("ERR_0b5e93d2a6c7f184"))
+
 // ErrInvalidType indicates that the provided request type isn't valid, and can't be
 // used for the requested transition.
 var ErrInvalidType = errors.New("invalid type", j.C("ERR_baf1a1f2e99951ec"))
+
+// ErrInvalidFSM indicates that the FSM definition isn't valid, ex. a status was
+// added twice or a next status wasn't added.
+var ErrInvalidFSM = errors.New("invalid fsm", j.C("ERR_5f1b1b997479dc62"))
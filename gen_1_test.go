@@ -13,27 +13,33 @@ import (
 	"github.com/luno/shift"
 )
 
+// Ensure the generated methods implement the shift interfaces.
+var (
+	_ shift.Inserter[int64]      = (*insert)(nil)
+	_ shift.Inserter[int64]      = (*seed)(nil)
+	_ shift.BatchInserter[int64] = (*seedBatch)(nil)
+	_ shift.Updater[int64]       = (*update)(nil)
+	_ shift.Updater[int64]       = (*complete)(nil)
+	_ shift.ForceUpdater[int64]  = (*complete)(nil)
+	_ shift.Deleter[int64]       = (*remove)(nil)
+)
+
+// sqlInsertInsert is the query of the insert Insert method.
+const sqlInsertInsert = "insert into users set `status`=?, `created_at`=?, `updated_at`=?, `name`=?, `dob`=?"
+
 // Insert inserts a new users table entity. All the fields of the
 // insert receiver are set, as well as status, created_at and updated_at.
 // The newly created entity id is returned on success or an error.
 func (一 insert) Insert(
 	ctx context.Context, tx *sql.Tx, st shift.Status,
 ) (int64, error) {
-	var (
-		q    strings.Builder
-		args []interface{}
-	)
-
-	q.WriteString("insert into users set `status`=?, `created_at`=?, `updated_at`=? ")
-	args = append(args, st.ShiftStatus(), time.Now(), time.Now())
-
-	q.WriteString(", `name`=?")
+	now := time.Now()
+	args := make([]interface{}, 0, 5)
+	args = append(args, st.ShiftStatus(), now, now)
 	args = append(args, 一.Name)
-
-	q.WriteString(", `dob`=?")
 	args = append(args, 一.DateOfBirth)
 
-	res, err := tx.ExecContext(ctx, q.String(), args...)
+	res, err := tx.ExecContext(ctx, sqlInsertInsert, args...)
 	if err != nil {
 		return 0, err
 	}
@@ -46,30 +52,88 @@ func (一 insert) Insert(
 	return id, nil
 }
 
-// Update updates the status of a users table entity. All the fields of the
-// update receiver are updated, as well as status and updated_at.
-// The entity id is returned on success or an error.
-func (一 update) Update(
-	ctx context.Context, tx *sql.Tx, from shift.Status, to shift.Status,
+// sqlInsertSeed is the query of the seed Insert method.
+const sqlInsertSeed = "insert into users set `id`=?, `status`=?, `created_at`=?, `updated_at`=?, `name`=?, `dob`=?"
+
+// Insert inserts a new users table entity. All the fields of the
+// seed receiver are set, as well as status, created_at and updated_at.
+// The newly created entity id is returned on success or an error.
+func (一 seed) Insert(
+	ctx context.Context, tx *sql.Tx, st shift.Status,
 ) (int64, error) {
+	now := time.Now()
+	args := make([]interface{}, 0, 6)
+	args = append(args, 一.ID, st.ShiftStatus(), now, now)
+	args = append(args, 一.Name)
+	args = append(args, 一.DateOfBirth)
+
+	_, err := tx.ExecContext(ctx, sqlInsertSeed, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return 一.ID, nil
+}
+
+// seedBatch is a batch of seed rows inserted in a single statement.
+type seedBatch []seed
+
+// InsertBatch inserts new users table entities in a single statement. All the
+// fields of the seed rows are set, as well as status, created_at and updated_at.
+// The newly created entity ids are returned in order on success or an error.
+func (一 seedBatch) InsertBatch(
+	ctx context.Context, tx *sql.Tx, st shift.Status,
+) ([]int64, error) {
+	if len(一) == 0 {
+		return nil, nil
+	}
+
 	var (
 		q    strings.Builder
 		args []interface{}
 	)
 
-	q.WriteString("update users set `status`=?, `updated_at`=? ")
-	args = append(args, to.ShiftStatus(), time.Now())
+	now := time.Now()
+	q.WriteString("insert into users (`id`, `status`, `created_at`, `updated_at`, `name`, `dob`) values ")
+	for i, r := range 一 {
+		if i > 0 {
+			q.WriteString(", ")
+		}
+		q.WriteString("(?, ?, ?, ?, ?, ?)")
+		args = append(args, r.ID, st.ShiftStatus(), now, now)
+		args = append(args, r.Name)
+		args = append(args, r.DateOfBirth)
+	}
 
-	q.WriteString(", `name`=?")
-	args = append(args, 一.Name)
+	_, err := tx.ExecContext(ctx, q.String(), args...)
+	if err != nil {
+		return nil, err
+	}
 
-	q.WriteString(", `amount`=?")
-	args = append(args, 一.Amount)
+	ids := make([]int64, 0, len(一))
+	for _, r := range 一 {
+		ids = append(ids, r.ID)
+	}
+
+	return ids, nil
+}
+
+// sqlUpdateUpdate is the query of the update Update method.
+const sqlUpdateUpdate = "update users set `status`=?, `updated_at`=?, `name`=?, `amount`=? where `id`=? and `status`=?"
 
-	q.WriteString(" where `id`=? and `status`=?")
+// Update updates the status of a users table entity. All the fields of the
+// update receiver are updated, as well as status and updated_at.
+// The entity id is returned on success or an error.
+func (一 update) Update(
+	ctx context.Context, tx *sql.Tx, from shift.Status, to shift.Status,
+) (int64, error) {
+	args := make([]interface{}, 0, 6)
+	args = append(args, to.ShiftStatus(), time.Now())
+	args = append(args, 一.Name)
+	args = append(args, 一.Amount)
 	args = append(args, 一.ID, from.ShiftStatus())
 
-	res, err := tx.ExecContext(ctx, q.String(), args...)
+	res, err := tx.ExecContext(ctx, sqlUpdateUpdate, args...)
 	if err != nil {
 		return 0, err
 	}
@@ -77,31 +141,94 @@ func (一 update) Update(
 	if err != nil {
 		return 0, err
 	}
+	if n == 0 {
+		// Classify why no row was updated.
+		var status int
+		err := tx.QueryRowContext(ctx, "select `status` from users where `id`=?",
+			一.ID).Scan(&status)
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, errors.Wrap(shift.ErrRowNotFound, "update", j.MKV{"table": "users", "id": 一.ID, "from": from.ShiftStatus()})
+		} else if err != nil {
+			return 0, err
+		}
+		if status != from.ShiftStatus() {
+			return 0, errors.Wrap(shift.ErrStatusMismatch, "update", j.MKV{"table": "users", "id": 一.ID, "from": from.ShiftStatus(), "status": status})
+		}
+	}
 	if n != 1 {
-		return 0, errors.Wrap(shift.ErrRowCount, "update", j.KV("count", n))
+		return 0, errors.Wrap(shift.ErrRowCount, "update", j.MKV{"table": "users", "id": 一.ID, "from": from.ShiftStatus(), "count": n})
 	}
 
 	return 一.ID, nil
 }
 
+// sqlUpdateComplete is the query of the complete Update method.
+const sqlUpdateComplete = "update users set `status`=?, `updated_at`=? where `id`=? and `status`=?"
+
 // Update updates the status of a users table entity. All the fields of the
 // complete receiver are updated, as well as status and updated_at.
 // The entity id is returned on success or an error.
 func (一 complete) Update(
 	ctx context.Context, tx *sql.Tx, from shift.Status, to shift.Status,
 ) (int64, error) {
-	var (
-		q    strings.Builder
-		args []interface{}
-	)
-
-	q.WriteString("update users set `status`=?, `updated_at`=? ")
+	args := make([]interface{}, 0, 4)
 	args = append(args, to.ShiftStatus(), time.Now())
-
-	q.WriteString(" where `id`=? and `status`=?")
 	args = append(args, 一.ID, from.ShiftStatus())
 
-	res, err := tx.ExecContext(ctx, q.String(), args...)
+	res, err := tx.ExecContext(ctx, sqlUpdateComplete, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		// Classify why no row was updated.
+		var status int
+		err := tx.QueryRowContext(ctx, "select `status` from users where `id`=?",
+			一.ID).Scan(&status)
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, errors.Wrap(shift.ErrRowNotFound, "complete", j.MKV{"table": "users", "id": 一.ID, "from": from.ShiftStatus()})
+		} else if err != nil {
+			return 0, err
+		}
+		if status != from.ShiftStatus() {
+			return 0, errors.Wrap(shift.ErrStatusMismatch, "complete", j.MKV{"table": "users", "id": 一.ID, "from": from.ShiftStatus(), "status": status})
+		}
+	}
+	if n != 1 {
+		return 0, errors.Wrap(shift.ErrRowCount, "complete", j.MKV{"table": "users", "id": 一.ID, "from": from.ShiftStatus(), "count": n})
+	}
+
+	return 一.ID, nil
+}
+
+// ForceUpdate updates a users table entity like Update, but regardless of
+// its current status, which is locked and read first.
+// The entity id is returned on success or an error.
+func (一 complete) ForceUpdate(
+	ctx context.Context, tx *sql.Tx, to shift.Status,
+) (int64, error) {
+	var from int
+	err := tx.QueryRowContext(ctx, "select `status` from users where `id`=? for update",
+		一.ID).Scan(&from)
+	if err != nil {
+		return 0, err
+	}
+
+	return 一.Update(ctx, tx, shift.RawStatus(from), to)
+}
+
+// sqlDeleteRemove is the query of the remove Delete method.
+const sqlDeleteRemove = "delete from users where `id`=? and `status`=?"
+
+// Delete deletes a users table entity in the from status.
+// The entity id is returned on success or an error.
+func (一 remove) Delete(
+	ctx context.Context, tx *sql.Tx, from shift.Status,
+) (int64, error) {
+	res, err := tx.ExecContext(ctx, sqlDeleteRemove, 一.ID, from.ShiftStatus())
 	if err != nil {
 		return 0, err
 	}
@@ -110,7 +237,7 @@ func (一 complete) Update(
 		return 0, err
 	}
 	if n != 1 {
-		return 0, errors.Wrap(shift.ErrRowCount, "complete", j.KV("count", n))
+		return 0, errors.Wrap(shift.ErrRowCount, "remove", j.MKV{"table": "users", "id": 一.ID, "from": from.ShiftStatus(), "count": n})
 	}
 
 	return 一.ID, nil
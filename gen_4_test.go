@@ -1,11 +1,10 @@
 package shift_test
 
-// Code generated by shiftgen at arc_test.go:14. DO NOT EDIT.
+// Code generated by shiftgen at arc_test.go:19. DO NOT EDIT.
 
 import (
 	"context"
 	"database/sql"
-	"strings"
 	"time"
 
 	"github.com/luno/jettison/errors"
@@ -13,30 +12,29 @@ import (
 	"github.com/luno/shift"
 )
 
+// Ensure the generated methods implement the shift interfaces.
+var (
+	_ shift.Inserter[int64] = (*insert2)(nil)
+	_ shift.Updater[int64]  = (*move)(nil)
+)
+
+// sqlInsertInsert2 is the query of the insert2 Insert method.
+const sqlInsertInsert2 = "insert into users set `status`=?, `created_at`=?, `updated_at`=?, `name`=?, `dob`=?, `amount`=?"
+
 // Insert inserts a new users table entity. All the fields of the
 // insert2 receiver are set, as well as status, created_at and updated_at.
 // The newly created entity id is returned on success or an error.
 func (一 insert2) Insert(
 	ctx context.Context, tx *sql.Tx, st shift.Status,
 ) (int64, error) {
-	var (
-		q    strings.Builder
-		args []interface{}
-	)
-
-	q.WriteString("insert into users set `status`=?, `created_at`=?, `updated_at`=? ")
-	args = append(args, st.ShiftStatus(), time.Now(), time.Now())
-
-	q.WriteString(", `name`=?")
+	now := time.Now()
+	args := make([]interface{}, 0, 6)
+	args = append(args, st.ShiftStatus(), now, now)
 	args = append(args, 一.Name)
-
-	q.WriteString(", `dob`=?")
 	args = append(args, 一.DateOfBirth)
-
-	q.WriteString(", `amount`=?")
 	args = append(args, 一.Amount)
 
-	res, err := tx.ExecContext(ctx, q.String(), args...)
+	res, err := tx.ExecContext(ctx, sqlInsertInsert2, args...)
 	if err != nil {
 		return 0, err
 	}
@@ -49,24 +47,20 @@ func (一 insert2) Insert(
 	return id, nil
 }
 
+// sqlUpdateMove is the query of the move Update method.
+const sqlUpdateMove = "update users set `status`=?, `updated_at`=? where `id`=? and `status`=?"
+
 // Update updates the status of a users table entity. All the fields of the
 // move receiver are updated, as well as status and updated_at.
 // The entity id is returned on success or an error.
 func (一 move) Update(
 	ctx context.Context, tx *sql.Tx, from shift.Status, to shift.Status,
 ) (int64, error) {
-	var (
-		q    strings.Builder
-		args []interface{}
-	)
-
-	q.WriteString("update users set `status`=?, `updated_at`=? ")
+	args := make([]interface{}, 0, 4)
 	args = append(args, to.ShiftStatus(), time.Now())
-
-	q.WriteString(" where `id`=? and `status`=?")
 	args = append(args, 一.ID, from.ShiftStatus())
 
-	res, err := tx.ExecContext(ctx, q.String(), args...)
+	res, err := tx.ExecContext(ctx, sqlUpdateMove, args...)
 	if err != nil {
 		return 0, err
 	}
@@ -75,7 +69,7 @@ func (一 move) Update(
 		return 0, err
 	}
 	if n != 1 {
-		return 0, errors.Wrap(shift.ErrRowCount, "move", j.KV("count", n))
+		return 0, errors.Wrap(shift.ErrRowCount, "move", j.MKV{"table": "users", "id": 一.ID, "from": from.ShiftStatus(), "count": n})
 	}
 
 	return 一.ID, nil
@@ -0,0 +1,53 @@
+package shift_test
+
+// Code generated by shiftgen at shift_test.go:595. DO NOT EDIT.
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/luno/jettison/errors"
+	"github.com/luno/jettison/j"
+	"github.com/luno/shift"
+)
+
+// Ensure the generated methods implement the shift interfaces.
+var (
+	_ shift.Inserter[string] = (*insertOnce)(nil)
+)
+
+// sqlInsertInsertOnce is the query of the insertOnce Insert method.
+const sqlInsertInsertOnce = "insert into usersStr set `id`=?, `status`=?, `created_at`=?, `updated_at`=?, `name`=?, `dob`=? on duplicate key update `id`=`id`"
+
+// Insert inserts a new usersStr table entity. All the fields of the
+// insertOnce receiver are set, as well as status, created_at and updated_at.
+// The newly created entity id is returned on success or an error.
+// If the row already exists, it isn't changed and its id is returned with
+// shift.ErrAlreadyInserted, which the FSM treats as success without inserting
+// an event. This relies on the driver reporting changed rows, not found rows.
+func (一 insertOnce) Insert(
+	ctx context.Context, tx *sql.Tx, st shift.Status,
+) (string, error) {
+	now := time.Now()
+	args := make([]interface{}, 0, 6)
+	args = append(args, 一.ID, st.ShiftStatus(), now, now)
+	args = append(args, 一.Name)
+	args = append(args, 一.DateOfBirth)
+
+	res, err := tx.ExecContext(ctx, sqlInsertInsertOnce, args...)
+	if err != nil {
+		return "", err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return "", err
+	}
+	if n == 0 {
+		// The row already exists and wasn't changed.
+		return 一.ID, errors.Wrap(shift.ErrAlreadyInserted, "insertOnce", j.MKV{"table": "usersStr", "id": 一.ID})
+	}
+
+	return 一.ID, nil
+}
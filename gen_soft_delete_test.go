@@ -0,0 +1,80 @@
+package shift_test
+
+// Code generated by shiftgen at shift_test.go:547. DO NOT EDIT.
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/luno/jettison/errors"
+	"github.com/luno/jettison/j"
+	"github.com/luno/shift"
+)
+
+// Ensure the generated methods implement the shift interfaces.
+var (
+	_ shift.Updater[int64] = (*touch)(nil)
+	_ shift.Deleter[int64] = (*archive)(nil)
+)
+
+// sqlUpdateTouch is the query of the touch Update method.
+const sqlUpdateTouch = "update users set `status`=?, `updated_at`=?, `name`=? where `id`=? and `status`=? and `deleted_at` is null"
+
+// Update updates the status of a users table entity. All the fields of the
+// touch receiver are updated, as well as status and updated_at.
+// The entity id is returned on success or an error.
+func (一 touch) Update(
+	ctx context.Context, tx *sql.Tx, from shift.Status, to shift.Status,
+) (int64, error) {
+	args := make([]interface{}, 0, 5)
+	args = append(args, to.ShiftStatus(), time.Now())
+	args = append(args, 一.Name)
+	args = append(args, 一.ID, from.ShiftStatus())
+
+	res, err := tx.ExecContext(ctx, sqlUpdateTouch, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if n != 1 {
+		return 0, errors.Wrap(shift.ErrRowCount, "touch", j.MKV{"table": "users", "id": 一.ID, "from": from.ShiftStatus(), "count": n})
+	}
+
+	return 一.ID, nil
+}
+
+// sqlDeleteArchive is the query of the archive SoftDelete method.
+const sqlDeleteArchive = "update users set `deleted_at`=? where `id`=? and `status`=? and `deleted_at` is null"
+
+// Delete soft deletes a users table entity in the from status, see SoftDelete.
+// The entity id is returned on success or an error.
+func (一 archive) Delete(
+	ctx context.Context, tx *sql.Tx, from shift.Status,
+) (int64, error) {
+	return 一.SoftDelete(ctx, tx, from)
+}
+
+// SoftDelete marks a users table entity in the from status as deleted by
+// setting deleted_at, after which it can't be updated anymore.
+// The entity id is returned on success or an error.
+func (一 archive) SoftDelete(
+	ctx context.Context, tx *sql.Tx, from shift.Status,
+) (int64, error) {
+	res, err := tx.ExecContext(ctx, sqlDeleteArchive, time.Now(), 一.ID, from.ShiftStatus())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if n != 1 {
+		return 0, errors.Wrap(shift.ErrRowCount, "archive", j.MKV{"table": "users", "id": 一.ID, "from": from.ShiftStatus(), "count": n})
+	}
+
+	return 一.ID, nil
+}
@@ -1,11 +1,10 @@
 package shift_test
 
-// Code generated by shiftgen at shift_test.go:121. DO NOT EDIT.
+// Code generated by shiftgen at shift_test.go:128. DO NOT EDIT.
 
 import (
 	"context"
 	"database/sql"
-	"strings"
 	"time"
 
 	"github.com/luno/jettison/errors"
@@ -13,27 +12,29 @@ import (
 	"github.com/luno/shift"
 )
 
+// Ensure the generated methods implement the shift interfaces.
+var (
+	_ shift.Inserter[string] = (*insertStr)(nil)
+	_ shift.Updater[string]  = (*updateStr)(nil)
+	_ shift.Updater[string]  = (*completeStr)(nil)
+)
+
+// sqlInsertInsertStr is the query of the insertStr Insert method.
+const sqlInsertInsertStr = "insert into usersStr set `id`=?, `status`=?, `created_at`=?, `updated_at`=?, `name`=?, `dob`=?"
+
 // Insert inserts a new usersStr table entity. All the fields of the
 // insertStr receiver are set, as well as status, created_at and updated_at.
 // The newly created entity id is returned on success or an error.
 func (一 insertStr) Insert(
 	ctx context.Context, tx *sql.Tx, st shift.Status,
 ) (string, error) {
-	var (
-		q    strings.Builder
-		args []interface{}
-	)
-
-	q.WriteString("insert into usersStr set `id`=?, `status`=?, `created_at`=?, `updated_at`=? ")
-	args = append(args, 一.ID, st.ShiftStatus(), time.Now(), time.Now())
-
-	q.WriteString(", `name`=?")
+	now := time.Now()
+	args := make([]interface{}, 0, 6)
+	args = append(args, 一.ID, st.ShiftStatus(), now, now)
 	args = append(args, 一.Name)
-
-	q.WriteString(", `dob`=?")
 	args = append(args, 一.DateOfBirth)
 
-	_, err := tx.ExecContext(ctx, q.String(), args...)
+	_, err := tx.ExecContext(ctx, sqlInsertInsertStr, args...)
 	if err != nil {
 		return "", err
 	}
@@ -41,30 +42,22 @@ func (一 insertStr) Insert(
 	return 一.ID, nil
 }
 
+// sqlUpdateUpdateStr is the query of the updateStr Update method.
+const sqlUpdateUpdateStr = "update usersStr set `status`=?, `updated_at`=?, `name`=?, `amount`=? where `id`=? and `status`=?"
+
 // Update updates the status of a usersStr table entity. All the fields of the
 // updateStr receiver are updated, as well as status and updated_at.
 // The entity id is returned on success or an error.
 func (一 updateStr) Update(
 	ctx context.Context, tx *sql.Tx, from shift.Status, to shift.Status,
 ) (string, error) {
-	var (
-		q    strings.Builder
-		args []interface{}
-	)
-
-	q.WriteString("update usersStr set `status`=?, `updated_at`=? ")
+	args := make([]interface{}, 0, 6)
 	args = append(args, to.ShiftStatus(), time.Now())
-
-	q.WriteString(", `name`=?")
 	args = append(args, 一.Name)
-
-	q.WriteString(", `amount`=?")
 	args = append(args, 一.Amount)
-
-	q.WriteString(" where `id`=? and `status`=?")
 	args = append(args, 一.ID, from.ShiftStatus())
 
-	res, err := tx.ExecContext(ctx, q.String(), args...)
+	res, err := tx.ExecContext(ctx, sqlUpdateUpdateStr, args...)
 	if err != nil {
 		return "", err
 	}
@@ -73,30 +66,26 @@ func (一 updateStr) Update(
 		return "", err
 	}
 	if n != 1 {
-		return "", errors.Wrap(shift.ErrRowCount, "updateStr", j.KV("count", n))
+		return "", errors.Wrap(shift.ErrRowCount, "updateStr", j.MKV{"table": "usersStr", "id": 一.ID, "from": from.ShiftStatus(), "count": n})
 	}
 
 	return 一.ID, nil
 }
 
+// sqlUpdateCompleteStr is the query of the completeStr Update method.
+const sqlUpdateCompleteStr = "update usersStr set `status`=?, `updated_at`=? where `id`=? and `status`=?"
+
 // Update updates the status of a usersStr table entity. All the fields of the
 // completeStr receiver are updated, as well as status and updated_at.
 // The entity id is returned on success or an error.
 func (一 completeStr) Update(
 	ctx context.Context, tx *sql.Tx, from shift.Status, to shift.Status,
 ) (string, error) {
-	var (
-		q    strings.Builder
-		args []interface{}
-	)
-
-	q.WriteString("update usersStr set `status`=?, `updated_at`=? ")
+	args := make([]interface{}, 0, 4)
 	args = append(args, to.ShiftStatus(), time.Now())
-
-	q.WriteString(" where `id`=? and `status`=?")
 	args = append(args, 一.ID, from.ShiftStatus())
 
-	res, err := tx.ExecContext(ctx, q.String(), args...)
+	res, err := tx.ExecContext(ctx, sqlUpdateCompleteStr, args...)
 	if err != nil {
 		return "", err
 	}
@@ -105,7 +94,7 @@ func (一 completeStr) Update(
 		return "", err
 	}
 	if n != 1 {
-		return "", errors.Wrap(shift.ErrRowCount, "completeStr", j.KV("count", n))
+		return "", errors.Wrap(shift.ErrRowCount, "completeStr", j.MKV{"table": "usersStr", "id": 一.ID, "from": from.ShiftStatus(), "count": n})
 	}
 
 	return 一.ID, nil
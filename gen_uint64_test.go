@@ -0,0 +1,77 @@
+package shift_test
+
+// Code generated by shiftgen at shift_test.go:184. DO NOT EDIT.
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/luno/jettison/errors"
+	"github.com/luno/jettison/j"
+	"github.com/luno/shift"
+)
+
+// Ensure the generated methods implement the shift interfaces.
+var (
+	_ shift.Inserter[uint64] = (*insertU64)(nil)
+	_ shift.Updater[uint64]  = (*updateU64)(nil)
+)
+
+// sqlInsertInsertU64 is the query of the insertU64 Insert method.
+const sqlInsertInsertU64 = "insert into users set `status`=?, `created_at`=?, `updated_at`=?, `name`=?, `dob`=?"
+
+// Insert inserts a new users table entity. All the fields of the
+// insertU64 receiver are set, as well as status, created_at and updated_at.
+// The newly created entity id is returned on success or an error.
+func (一 insertU64) Insert(
+	ctx context.Context, tx *sql.Tx, st shift.Status,
+) (uint64, error) {
+	now := time.Now()
+	args := make([]interface{}, 0, 5)
+	args = append(args, st.ShiftStatus(), now, now)
+	args = append(args, 一.Name)
+	args = append(args, 一.DateOfBirth)
+
+	res, err := tx.ExecContext(ctx, sqlInsertInsertU64, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(id), nil
+}
+
+// sqlUpdateUpdateU64 is the query of the updateU64 Update method.
+const sqlUpdateUpdateU64 = "update users set `status`=?, `updated_at`=?, `name`=?, `amount`=? where `id`=? and `status`=?"
+
+// Update updates the status of a users table entity. All the fields of the
+// updateU64 receiver are updated, as well as status and updated_at.
+// The entity id is returned on success or an error.
+func (一 updateU64) Update(
+	ctx context.Context, tx *sql.Tx, from shift.Status, to shift.Status,
+) (uint64, error) {
+	args := make([]interface{}, 0, 6)
+	args = append(args, to.ShiftStatus(), time.Now())
+	args = append(args, 一.Name)
+	args = append(args, 一.Amount)
+	args = append(args, 一.ID, from.ShiftStatus())
+
+	res, err := tx.ExecContext(ctx, sqlUpdateUpdateU64, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if n != 1 {
+		return 0, errors.Wrap(shift.ErrRowCount, "updateU64", j.MKV{"table": "users", "id": 一.ID, "from": from.ShiftStatus(), "count": n})
+	}
+
+	return 一.ID, nil
+}
@@ -0,0 +1,65 @@
+package shift_test
+
+// Code generated by shiftgen at shift_test.go:232. DO NOT EDIT.
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/luno/jettison/errors"
+	"github.com/luno/jettison/j"
+	"github.com/luno/shift"
+)
+
+// Ensure the generated methods implement the shift interfaces.
+var (
+	_ shift.Updater[int64] = (*rename)(nil)
+)
+
+// sqlUpdateRename is the query of the rename Update method.
+const sqlUpdateRename = "update users set `status`=?, `updated_at`=?, `name`=?, `version`=`version`+1 where `id`=? and `status`=? and `version`=?"
+
+// Update updates the status of a users table entity. All the fields of the
+// rename receiver are updated, as well as status and updated_at.
+// The entity id is returned on success or an error.
+func (一 rename) Update(
+	ctx context.Context, tx *sql.Tx, from shift.Status, to shift.Status,
+) (int64, error) {
+	args := make([]interface{}, 0, 6)
+	args = append(args, to.ShiftStatus(), time.Now())
+	args = append(args, 一.Name)
+	args = append(args, 一.ID, from.ShiftStatus(), 一.Version)
+
+	res, err := tx.ExecContext(ctx, sqlUpdateRename, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		// Classify why no row was updated.
+		var status int
+		var version int64
+		err := tx.QueryRowContext(ctx, "select `status`, `version` from users where `id`=?",
+			一.ID).Scan(&status, &version)
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, errors.Wrap(shift.ErrRowNotFound, "rename", j.MKV{"table": "users", "id": 一.ID, "from": from.ShiftStatus()})
+		} else if err != nil {
+			return 0, err
+		}
+		if status != from.ShiftStatus() {
+			return 0, errors.Wrap(shift.ErrStatusMismatch, "rename", j.MKV{"table": "users", "id": 一.ID, "from": from.ShiftStatus(), "status": status})
+		}
+		if version != 一.Version {
+			return 0, errors.Wrap(shift.ErrVersionConflict, "rename", j.MKV{"table": "users", "id": 一.ID, "from": from.ShiftStatus(), "version": 一.Version})
+		}
+	}
+	if n != 1 {
+		return 0, errors.Wrap(shift.ErrRowCount, "rename", j.MKV{"table": "users", "id": 一.ID, "from": from.ShiftStatus(), "count": n})
+	}
+
+	return 一.ID, nil
+}
@@ -0,0 +1,92 @@
+package shift
+
+import (
+	"cmp"
+	"reflect"
+	"slices"
+)
+
+// Graph is the structure of a built FSM, ex. to render it or serve it over
+// an admin endpoint.
+type Graph struct {
+	// InsertStatus is the status of inserted entities, or the first insert
+	// status if there are multiple.
+	InsertStatus Status
+
+	// Inserts are the insert edges, their From status is nil.
+	Inserts []Edge
+
+	// Transitions are the update edges.
+	Transitions []Edge
+}
+
+// Edge is a transition of a Graph with the type of the request driving it,
+// ex. "mypkg.pending".
+type Edge struct {
+	From    Status
+	To      Status
+	ReqType string
+
+	// Category is the category of the transition, see Category.
+	Category string
+}
+
+// Graph returns the structure of the FSM with the inserter and updater types
+// of its transitions. Edges are ordered by the ShiftStatus of the from and
+// then the to status.
+func (fsm *GenFSM[T]) Graph() Graph {
+	g := Graph{InsertStatus: fsm.InsertStatus()}
+	for _, st := range fsm.insertStatuses {
+		g.Inserts = append(g.Inserts, Edge{To: st, ReqType: typeString(reflect.TypeOf(fsm.states[st.ShiftStatus()].req))})
+	}
+	for _, t := range fsm.Transitions() {
+		g.Transitions = append(g.Transitions, Edge{
+			From:     t.From,
+			To:       t.To,
+			ReqType:  typeString(reflect.TypeOf(fsm.states[t.To.ShiftStatus()].req)),
+			Category: t.Category,
+		})
+	}
+	sortEdges(g.Inserts)
+	return g
+}
+
+// Graph returns the structure of the ArcFSM with the inserter and updater
+// types of its transitions. Transitions allowed for multiple types have an
+// edge per type. Edges are ordered by the ShiftStatus of the from and then
+// the to status and then by type.
+func (fsm *GenArcFSM[T]) Graph() Graph {
+	var g Graph
+	if sl := fsm.InsertStatuses(); len(sl) > 0 {
+		g.InsertStatus = sl[0]
+	}
+	for _, tup := range fsm.inserts {
+		g.Inserts = append(g.Inserts, Edge{To: tup.To, ReqType: typeString(reflect.TypeOf(tup.Type))})
+	}
+	for _, tl := range fsm.updates {
+		for _, tup := range tl {
+			g.Transitions = append(g.Transitions, Edge{From: tup.From, To: tup.To, ReqType: typeString(reflect.TypeOf(tup.Type))})
+		}
+	}
+	sortEdges(g.Inserts)
+	sortEdges(g.Transitions)
+	return g
+}
+
+func sortEdges(sl []Edge) {
+	shiftStatus := func(st Status) int {
+		if st == nil {
+			return 0
+		}
+		return st.ShiftStatus()
+	}
+	slices.SortStableFunc(sl, func(a, b Edge) int {
+		if c := cmp.Compare(shiftStatus(a.From), shiftStatus(b.From)); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(a.To.ShiftStatus(), b.To.ShiftStatus()); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ReqType, b.ReqType)
+	})
+}
@@ -18,6 +18,8 @@ var schemas = []string{`
     name varchar(255) not null,
     dob datetime not null,
     amount varchar(255),
+    version bigint not null default 0,
+    deleted_at datetime,
 
     status     tinyint not null,
     created_at datetime not null,
@@ -34,6 +36,15 @@ var schemas = []string{`
 
     primary key (id)
   );`, `
+  create temporary table eventsShard (
+    id bigint not null auto_increment,
+    foreign_id bigint not null,
+    timestamp datetime not null,
+    type tinyint not null,
+    metadata blob,
+
+    primary key (id)
+  );`, `
   create temporary table usersStr (
     id     varchar(255) not null,
     name   varchar(255) not null,
@@ -0,0 +1,116 @@
+package shift
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+
+	"github.com/luno/jettison/errors"
+	"github.com/luno/jettison/j"
+	"github.com/luno/reflex"
+)
+
+// HistoryEvent is a reflex event of an entity returned by History.
+type HistoryEvent struct {
+	reflex.Event
+
+	// Metadata is the decoded metadata of the event, nil if it has none:
+	// ForcedMetadata for forced updates, the typed metadata with
+	// WithTypedMetadata (decoded with the metadata codec), the category
+	// string with WithCategoryMetadata, else the raw metadata bytes.
+	Metadata any
+}
+
+// History returns the reflex events of the entity with the id in order
+// with their decoded metadata, ex. to render an audit timeline. The events
+// must be an EventStreamer and the events table must be configured with a
+// metadata field for the metadata to be included. All events up to the
+// current head of the events table are read, so it should only be used
+// for small tables or infrequent lookups.
+func (fsm *GenFSM[T]) History(ctx context.Context, dbc *sql.DB, id T) ([]HistoryEvent, error) {
+	return history(ctx, dbc, fsm.options, eventsFor(fsm.options, id, fsm.events), id)
+}
+
+// History returns the reflex events of the entity with the id in order
+// with their decoded metadata, see GenFSM.History.
+func (fsm *GenArcFSM[T]) History(ctx context.Context, dbc *sql.DB, id T) ([]HistoryEvent, error) {
+	return history(ctx, dbc, fsm.options, eventsFor(fsm.options, id, fsm.events), id)
+}
+
+func history[T primary](ctx context.Context, dbc *sql.DB, o options, events EventInserter[T], id T) ([]HistoryEvent, error) {
+	streamer, ok := events.(EventStreamer)
+	if !ok {
+		return nil, errors.Wrap(ErrHistoryUnavailable, "", j.KV("events", typeString(reflect.TypeOf(events))))
+	}
+
+	foreignID := fmt.Sprint(id)
+	byID, err := readEvents(ctx, streamer.ToStream(dbc), foreignID)
+	if err != nil {
+		return nil, err
+	}
+
+	forced, err := o.forcedMetadata()
+	if err != nil {
+		return nil, err
+	}
+
+	var res []HistoryEvent
+	for _, e := range byID[foreignID] {
+		meta, err := decodeMetadata[T](o, forced, e.MetaData)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode metadata", j.KV("event", e.ID))
+		}
+		res = append(res, HistoryEvent{Event: e, Metadata: meta})
+	}
+	return res, nil
+}
+
+// decodeMetadata returns the decoded event metadata, see HistoryEvent.
+func decodeMetadata[T primary](o options, forced, data []byte) (any, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	if forced != nil && bytes.Equal(data, forced) {
+		return ForcedMetadata, nil
+	}
+	if typed, ok := o.typedMetadata.(typedMetadata[T]); ok {
+		return typed.decode(data, o.unmarshalMetadata)
+	}
+	if o.withCategoryMetadata {
+		return string(data), nil
+	}
+	return data, nil
+}
+
+// readEvents returns the events of the stream up to its current head grouped
+// by foreign id, only including the events of the foreign ids if provided.
+func readEvents(ctx context.Context, stream reflex.StreamFunc, foreignIDs ...string) (map[string][]reflex.Event, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sc, err := stream(ctx, "", reflex.WithStreamToHead())
+	if err != nil {
+		return nil, err
+	}
+
+	include := make(map[string]bool)
+	for _, id := range foreignIDs {
+		include[id] = true
+	}
+
+	res := make(map[string][]reflex.Event)
+	for {
+		e, err := sc.Recv()
+		if reflex.IsHeadReachedErr(err) {
+			return res, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if len(include) > 0 && !include[e.ForeignID] {
+			continue
+		}
+		res[e.ForeignID] = append(res[e.ForeignID], *e)
+	}
+}
@@ -0,0 +1,285 @@
+package shift
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/luno/jettison/jtest"
+	"github.com/luno/reflex"
+	"github.com/luno/reflex/rsql"
+	"github.com/stretchr/testify/require"
+)
+
+type testMeta struct {
+	Reason string `json:"reason"`
+}
+
+type metaInsert struct{}
+
+func (metaInsert) Insert(context.Context, *sql.Tx, Status) (int64, error) { return 1, nil }
+
+func (metaInsert) GetMetadata(context.Context, *sql.Tx, int64, Status) (testMeta, error) {
+	return testMeta{Reason: "created"}, nil
+}
+
+type metaUpdate struct{}
+
+func (metaUpdate) Update(context.Context, *sql.Tx, Status, Status) (int64, error) { return 1, nil }
+
+func (metaUpdate) GetMetadata(context.Context, *sql.Tx, Status, Status) (testMeta, error) {
+	return testMeta{Reason: "updated"}, nil
+}
+
+type plainUpdate struct{}
+
+func (plainUpdate) Update(context.Context, *sql.Tx, Status, Status) (int64, error) { return 1, nil }
+
+type fakeEvents struct {
+	metadata [][]byte
+}
+
+func (e *fakeEvents) InsertWithMetadata(_ context.Context, _ rsql.DBC, _ int64,
+	_ reflex.EventType, metadata []byte,
+) (rsql.NotifyFunc, error) {
+	e.metadata = append(e.metadata, metadata)
+	return func() {}, nil
+}
+
+func TestWithTypedMetadata(t *testing.T) {
+	ctx := context.Background()
+	events := new(fakeEvents)
+	var o options
+	WithTypedMetadata[int64, testMeta]()(&o)
+
+	_, _, err := insertTx[int64](ctx, nil, testStatus(1), metaInsert{}, events, testStatus(1), o)
+	jtest.RequireNil(t, err)
+
+	_, err = updateTx[int64](ctx, nil, testStatus(1), testStatus(2), metaUpdate{}, events, testStatus(2), "", o)
+	jtest.RequireNil(t, err)
+
+	_, err = updateTx[int64](ctx, nil, testStatus(1), testStatus(2), plainUpdate{}, events, testStatus(2), "", o)
+	jtest.Require(t, ErrInvalidType, err)
+
+	require.Equal(t, [][]byte{
+		[]byte(`{"reason":"created"}`),
+		[]byte(`{"reason":"updated"}`),
+	}, events.metadata)
+}
+
+func TestWithMetadataCodec(t *testing.T) {
+	events := new(fakeEvents)
+	var o options
+	WithTypedMetadata[int64, testMeta]()(&o)
+	WithMetadataCodec(func(v any) ([]byte, error) {
+		return []byte(v.(testMeta).Reason), nil
+	}, func(data []byte, v any) error {
+		v.(*testMeta).Reason = string(data)
+		return nil
+	})(&o)
+
+	_, _, err := insertTx[int64](context.Background(), nil, testStatus(1), metaInsert{}, events, testStatus(1), o)
+	jtest.RequireNil(t, err)
+	require.Equal(t, [][]byte{[]byte("created")}, events.metadata)
+}
+
+type forceMetaUpdate struct {
+	metaUpdate
+}
+
+func (forceMetaUpdate) ForceUpdate(context.Context, *sql.Tx, Status) (int64, error) { return 1, nil }
+
+func TestForceUpdate_TypedMetadata(t *testing.T) {
+	events := new(fakeEvents)
+	fsm := NewFSM(events, WithTypedMetadata[int64, testMeta]()).
+		Insert(testStatus(1), metaInsert{}, testStatus(2)).
+		Update(testStatus(2), forceMetaUpdate{}).
+		Build()
+
+	_, err := fsm.ForceUpdateTx(context.Background(), nil, testStatus(2), forceMetaUpdate{})
+	jtest.RequireNil(t, err)
+
+	require.Equal(t, [][]byte{[]byte(`"shift:forced"`)}, events.metadata)
+	require.True(t, fsm.IsForced(events.metadata[0]))
+	require.False(t, fsm.IsForced([]byte(`{"reason":"updated"}`)))
+
+	plain := NewFSM(new(fakeEvents)).
+		Insert(testStatus(1), metaInsert{}, testStatus(2)).
+		Update(testStatus(2), forceMetaUpdate{}).
+		Build()
+	require.False(t, plain.IsForced(events.metadata[0]))
+}
+
+func TestWithTypedMetadata_TypeMismatch(t *testing.T) {
+	_, err := NewGenFSM[string](nil, WithTypedMetadata[int64, testMeta]()).
+		Insert(testStatus(1), stringInsert{}).
+		BuildE()
+	jtest.Require(t, ErrInvalidFSM, err)
+}
+
+type stringInsert struct{}
+
+func (stringInsert) Insert(context.Context, *sql.Tx, Status) (string, error) { return "", nil }
+
+func TestWithCategoryMetadata_Combined(t *testing.T) {
+	fn := func(context.Context, any, Status, Status) ([]byte, error) { return nil, nil }
+	for name, opt := range map[string]option{
+		"metadata":      WithMetadata(),
+		"typed":         WithTypedMetadata[int64, testMeta](),
+		"metadata func": WithMetadataFunc(fn),
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewFSM(nil, opt, WithCategoryMetadata()).
+				Insert(testStatus(1), plainInsert{}).
+				BuildE()
+			jtest.Require(t, ErrInvalidFSM, err)
+
+			_, err = NewArcFSM(nil, opt, WithCategoryMetadata()).BuildE()
+			jtest.Require(t, ErrInvalidFSM, err)
+
+			require.Panics(t, func() { NewArcFSM(nil, opt, WithCategoryMetadata()).Build() })
+		})
+	}
+}
+
+type plainInsert struct{}
+
+func (plainInsert) Insert(context.Context, *sql.Tx, Status) (int64, error) { return 1, nil }
+
+type bytesMetaUpdate struct{}
+
+func (bytesMetaUpdate) Update(context.Context, *sql.Tx, Status, Status) (int64, error) { return 1, nil }
+
+func (bytesMetaUpdate) GetMetadata(context.Context, *sql.Tx, Status, Status) ([]byte, error) {
+	return []byte("interface"), nil
+}
+
+func TestWithMetadataFunc(t *testing.T) {
+	ctx := context.Background()
+	events := new(fakeEvents)
+	var o options
+	WithMetadataFunc(func(_ context.Context, id any, from, to Status) ([]byte, error) {
+		return []byte(fmt.Sprintf("%v:%v->%v", id, from, to)), nil
+	})(&o)
+
+	_, _, err := insertTx[int64](ctx, nil, testStatus(1), plainInsert{}, events, testStatus(1), o)
+	jtest.RequireNil(t, err)
+
+	_, err = updateTx[int64](ctx, nil, testStatus(1), testStatus(2), plainUpdate{}, events, testStatus(2), "", o)
+	jtest.RequireNil(t, err)
+
+	// The interface is preferred over the func.
+	_, err = updateTx[int64](ctx, nil, testStatus(1), testStatus(2), bytesMetaUpdate{}, events, testStatus(2), "", o)
+	jtest.RequireNil(t, err)
+
+	require.Equal(t, [][]byte{
+		[]byte("1:<nil>->1"),
+		[]byte("1:1->2"),
+		[]byte("interface"),
+	}, events.metadata)
+}
+
+func TestHistory_Unavailable(t *testing.T) {
+	fsm := NewFSM(new(fakeEvents)).
+		Insert(testStatus(1), validatingInsert{}).
+		Build()
+
+	_, err := fsm.History(context.Background(), nil, 1)
+	jtest.Require(t, ErrHistoryUnavailable, err)
+}
+
+func TestDecodeMetadata(t *testing.T) {
+	typed := func(o *options) { WithTypedMetadata[int64, testMeta]()(o) }
+	codec := WithMetadataCodec(func(v any) ([]byte, error) {
+		return []byte(fmt.Sprint(v)), nil
+	}, func(data []byte, v any) error {
+		v.(*testMeta).Reason = string(data)
+		return nil
+	})
+
+	cases := []struct {
+		name string
+		opts []option
+		data []byte
+		exp  any
+	}{
+		{name: "empty", opts: []option{WithMetadata()}, exp: nil},
+		{name: "raw", opts: []option{WithMetadata()}, data: []byte("meta"), exp: []byte("meta")},
+		{name: "forced", opts: []option{WithMetadata()}, data: []byte(`"shift:forced"`), exp: ForcedMetadata},
+		{name: "category", opts: []option{WithCategoryMetadata()}, data: []byte("refund"), exp: "refund"},
+		{name: "typed", opts: []option{typed}, data: []byte(`{"reason":"created"}`), exp: testMeta{Reason: "created"}},
+		{name: "codec", opts: []option{typed, codec}, data: []byte("created"), exp: testMeta{Reason: "created"}},
+		{name: "codec forced", opts: []option{typed, codec}, data: []byte(ForcedMetadata), exp: ForcedMetadata},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var o options
+			for _, opt := range c.opts {
+				opt(&o)
+			}
+			forced, err := o.forcedMetadata()
+			jtest.RequireNil(t, err)
+
+			meta, err := decodeMetadata[int64](o, forced, c.data)
+			jtest.RequireNil(t, err)
+			require.Equal(t, c.exp, meta)
+		})
+	}
+}
+
+// streamEvents is an EventStreamer recording the inserted events and
+// counting the streams.
+type streamEvents struct {
+	events  []*reflex.Event
+	streams int
+}
+
+func (e *streamEvents) InsertWithMetadata(_ context.Context, _ rsql.DBC, id int64,
+	typ reflex.EventType, metadata []byte,
+) (rsql.NotifyFunc, error) {
+	e.events = append(e.events, &reflex.Event{ForeignID: fmt.Sprint(id), Type: typ, MetaData: metadata})
+	return func() {}, nil
+}
+
+func (e *streamEvents) ToStream(*sql.DB, ...reflex.StreamOption) reflex.StreamFunc {
+	e.streams++
+	return func(context.Context, string, ...reflex.StreamOption) (reflex.StreamClient, error) {
+		return &streamClient{events: e.events}, nil
+	}
+}
+
+type streamClient struct {
+	events []*reflex.Event
+}
+
+func (c *streamClient) Recv() (*reflex.Event, error) {
+	if len(c.events) == 0 {
+		return nil, reflex.ErrHeadReached
+	}
+	e := c.events[0]
+	c.events = c.events[1:]
+	return e, nil
+}
+
+func TestCheckMetadata(t *testing.T) {
+	ctx := context.Background()
+	events := new(streamEvents)
+	fsm := NewFSM(events, WithMetadata()).
+		Insert(testStatus(1), plainInsert{}).
+		Build()
+
+	var paths []TestPath[int64]
+	for id := int64(1); id <= 3; id++ {
+		_, err := events.InsertWithMetadata(ctx, nil, id, testStatus(1), []byte("meta"))
+		jtest.RequireNil(t, err)
+		paths = append(paths, TestPath[int64]{ID: id, Statuses: []Status{testStatus(1)}})
+	}
+
+	jtest.RequireNil(t, checkMetadata(nil, fsm, paths))
+	require.Equal(t, 1, events.streams)
+
+	_, err := events.InsertWithMetadata(ctx, nil, 2, testStatus(1), nil)
+	jtest.RequireNil(t, err)
+	require.Error(t, checkMetadata(nil, fsm, paths))
+}
@@ -0,0 +1,73 @@
+package shift
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/luno/jettison/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	resultSuccess    = "success"
+	resultError      = "error"
+	resultCommit     = "commit_error"
+	resultValidation = "validation_error"
+	resultRowCount   = "row_count_error"
+)
+
+var (
+	transitionTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "shift",
+		Name:      "transitions_total",
+		Help:      "Number of FSM transitions (inserts, updates and deletes) by table, from and to status and result.",
+	}, []string{"table", "from", "to", "result"})
+
+	transitionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "shift",
+		Name:      "transition_duration_seconds",
+		Help:      "Duration of FSM transition transactions by table and from and to status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"table", "from", "to"})
+)
+
+// RegisterMetrics registers the transition metrics enabled by WithMetrics
+// with the registerer, e.g. prometheus.DefaultRegisterer.
+func RegisterMetrics(r prometheus.Registerer) {
+	r.MustRegister(transitionTotal, transitionDuration)
+}
+
+// recordMetrics records the transition metrics if enabled. A nil from or to
+// status is recorded as empty.
+func (o options) recordMetrics(from, to Status, err error, obs *observation, d time.Duration) {
+	if !o.withMetrics {
+		return
+	}
+
+	var fromLabel string
+	if from != nil {
+		fromLabel = fmt.Sprintf("%v", from)
+	}
+	var toLabel string
+	if to != nil {
+		toLabel = fmt.Sprintf("%v", to)
+	}
+
+	result := resultSuccess
+	if err != nil {
+		switch {
+		case obs.result != "":
+			result = obs.result
+		case errors.Is(err, ErrRowCount):
+			result = resultRowCount
+		default:
+			result = resultError
+		}
+	}
+	table := o.metricsTable
+	if table == "" {
+		table = o.table
+	}
+	transitionTotal.WithLabelValues(table, fromLabel, toLabel, result).Inc()
+	transitionDuration.WithLabelValues(table, fromLabel, toLabel).Observe(d.Seconds())
+}
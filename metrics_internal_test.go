@@ -0,0 +1,82 @@
+package shift
+
+import (
+	"context"
+	"testing"
+
+	"github.com/luno/jettison/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserve(t *testing.T) {
+	o := options{withMetrics: true, table: "test_observe"}
+
+	_, done := o.observe(context.Background(), nil, testStatus(1))
+	done(nil)
+
+	ctx, done := o.observe(context.Background(), testStatus(1), testStatus(2))
+	setResult(ctx, resultValidation)
+	done(errors.New("invalid"))
+
+	ctx, done = o.observe(context.Background(), testStatus(1), testStatus(2))
+	setResult(ctx, resultCommit)
+	done(errors.New("commit failed"))
+
+	_, done = o.observe(context.Background(), testStatus(1), testStatus(2))
+	done(errors.Wrap(ErrRowCount, ""))
+
+	_, done = o.observe(context.Background(), testStatus(1), testStatus(2))
+	done(errors.New("other"))
+
+	count := func(from, to, result string) float64 {
+		return testutil.ToFloat64(transitionTotal.WithLabelValues("test_observe", from, to, result))
+	}
+	require.Equal(t, 1.0, count("", "1", resultSuccess))
+	require.Equal(t, 1.0, count("1", "2", resultValidation))
+	require.Equal(t, 1.0, count("1", "2", resultCommit))
+	require.Equal(t, 1.0, count("1", "2", resultRowCount))
+	require.Equal(t, 1.0, count("1", "2", resultError))
+	require.Equal(t, 0.0, count("1", "2", resultSuccess))
+
+	r := prometheus.NewRegistry()
+	RegisterMetrics(r)
+	n, err := testutil.GatherAndCount(r, "shift_transition_duration_seconds")
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+}
+
+func TestInsertBatch_Metrics(t *testing.T) {
+	fsm := NewFSM(new(fakeEvents), WithMetrics("test_batch")).
+		Insert(testStatus(1), plainInsert{}).
+		Build()
+
+	_, err := fsm.InsertBatch(context.Background(), new(countingBeginner), plainBatch{})
+	require.Error(t, err)
+
+	require.Equal(t, 1.0, testutil.ToFloat64(transitionTotal.WithLabelValues("test_batch", "", "1", resultError)))
+}
+
+func TestWithMetrics_Table(t *testing.T) {
+	var o options
+	WithTable("users")(&o)
+	WithMetrics("")(&o)
+	require.Equal(t, "users", o.table)
+
+	_, done := o.observe(context.Background(), nil, testStatus(1))
+	done(nil)
+	require.Equal(t, 1.0, testutil.ToFloat64(transitionTotal.WithLabelValues("users", "", "1", resultSuccess)))
+
+	o = options{}
+	WithMetrics("users_label")(&o)
+	require.Empty(t, o.table)
+}
+
+func TestObserve_Disabled(t *testing.T) {
+	ctx := context.Background()
+	obsCtx, done := options{}.observe(ctx, testStatus(1), testStatus(2))
+	require.Equal(t, ctx, obsCtx)
+	setResult(obsCtx, resultCommit)
+	done(nil)
+}
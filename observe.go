@@ -0,0 +1,75 @@
+package shift
+
+import (
+	"context"
+	"time"
+)
+
+// TransitionEvent describes a completed Insert, InsertBatch, Update,
+// UpdateMany, ForceUpdate or Delete, see WithObserver.
+type TransitionEvent struct {
+	// Table is the table provided by WithTable.
+	Table string
+	// From is the status before the transition, nil for inserts.
+	From Status
+	// To is the status after the transition, nil for deletes.
+	To Status
+	// ID is the id of the entity, nil if the transition failed before it was known.
+	// It is the slice of ids for batch inserts.
+	ID any
+	// Err is the error of the transition, nil if it was committed.
+	Err error
+	// Duration is the duration of the transition including its transaction.
+	Duration time.Duration
+}
+
+// observation holds the outcome of a transition reported from within its transaction.
+type observation struct {
+	result string
+	id     any
+}
+
+type observationKey struct{}
+
+// observe starts observing a transition if metrics or an observer are enabled.
+// It returns the context to pass to the transaction and a func recording the
+// metrics and calling the observer once the transaction is done.
+func (o options) observe(ctx context.Context, from, to Status) (context.Context, func(error)) {
+	if !o.withMetrics && o.observer == nil {
+		return ctx, noopEnd
+	}
+
+	t0 := o.now()
+	obs := new(observation)
+	ctx = context.WithValue(ctx, observationKey{}, obs)
+
+	return ctx, func(err error) {
+		d := o.now().Sub(t0)
+		o.recordMetrics(from, to, err, obs, d)
+		if o.observer != nil {
+			o.observer(ctx, TransitionEvent{
+				Table:    o.table,
+				From:     from,
+				To:       to,
+				ID:       obs.id,
+				Err:      err,
+				Duration: d,
+			})
+		}
+	}
+}
+
+// setResult sets the result of the transition observed in the context, if any.
+func setResult(ctx context.Context, result string) {
+	if obs, ok := ctx.Value(observationKey{}).(*observation); ok {
+		obs.result = result
+	}
+}
+
+// setID sets the entity id of the transition in the context's span and observation.
+func (o options) setID(ctx context.Context, id any) {
+	o.setSpanID(ctx, id)
+	if obs, ok := ctx.Value(observationKey{}).(*observation); ok {
+		obs.id = id
+	}
+}
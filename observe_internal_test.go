@@ -0,0 +1,56 @@
+package shift
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/luno/jettison/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserve_Observer(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := t0
+
+	var events []TransitionEvent
+	var o options
+	for _, opt := range []option{
+		WithTable("users"),
+		WithClock(func() time.Time { return now }),
+		WithObserver(func(_ context.Context, e TransitionEvent) { events = append(events, e) }),
+	} {
+		opt(&o)
+	}
+
+	ctx, done := o.observe(context.Background(), testStatus(1), testStatus(2))
+	o.setID(ctx, int64(5))
+	now = now.Add(time.Second)
+	done(nil)
+
+	errFailed := errors.New("failed")
+	_, done = o.observe(context.Background(), nil, testStatus(1))
+	done(errFailed)
+
+	require.Equal(t, []TransitionEvent{
+		{Table: "users", From: testStatus(1), To: testStatus(2), ID: int64(5), Duration: time.Second},
+		{Table: "users", To: testStatus(1), Err: errFailed},
+	}, events)
+}
+
+func TestInsertBatch_Observer(t *testing.T) {
+	var events []TransitionEvent
+	fsm := NewFSM(new(fakeEvents), WithTable("users"),
+		WithObserver(func(_ context.Context, e TransitionEvent) { events = append(events, e) })).
+		Insert(testStatus(1), plainInsert{}).
+		Build()
+
+	_, err := fsm.InsertBatch(context.Background(), new(countingBeginner), plainBatch{})
+	require.Error(t, err)
+
+	require.Len(t, events, 1)
+	require.Equal(t, "users", events[0].Table)
+	require.Nil(t, events[0].From)
+	require.Equal(t, testStatus(1), events[0].To)
+	require.Equal(t, err, events[0].Err)
+}
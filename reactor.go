@@ -0,0 +1,83 @@
+package shift
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/luno/jettison/errors"
+	"github.com/luno/jettison/j"
+	"github.com/luno/reflex"
+)
+
+// Transitioner updates entities from one status to another. It is
+// implemented by GenFSM and GenArcFSM.
+type Transitioner[T primary] interface {
+	Update(ctx context.Context, dbc Beginner, from, to Status, updater Updater[T]) error
+}
+
+// ReactFunc returns the next status of the entity of the event and the
+// updater to transition it with. A nil to status skips the event.
+type ReactFunc[T primary] func(ctx context.Context, e *reflex.Event) (to Status, updater Updater[T], err error)
+
+type reaction[T primary] struct {
+	from Status
+	fn   ReactFunc[T]
+}
+
+// Reactor drives entities through an FSM by reacting to the FSM's own events,
+// ex. to trigger the next step of a workflow once an entity reaches a status.
+//
+//	r := shift.NewReactor[int64](fsm, dbc).
+//		On(PENDING, func(ctx context.Context, e *reflex.Event) (shift.Status, shift.Updater[int64], error) {
+//			return COMPLETED, completed{ID: e.ForeignIDInt()}, nil
+//		})
+//	consumer := reflex.NewConsumer("workflow", r.Consume)
+type Reactor[T primary] struct {
+	fsm       Transitioner[T]
+	dbc       Beginner
+	reactions map[int]reaction[T]
+}
+
+// NewReactor returns a Reactor transitioning entities of the FSM.
+func NewReactor[T primary](fsm Transitioner[T], dbc Beginner) *Reactor[T] {
+	return &Reactor[T]{
+		fsm:       fsm,
+		dbc:       dbc,
+		reactions: make(map[int]reaction[T]),
+	}
+}
+
+// On returns the reactor reacting to events of the from status with fn. It
+// panics if a reaction to the status was already added.
+func (r *Reactor[T]) On(from Status, fn ReactFunc[T]) *Reactor[T] {
+	if _, ok := r.reactions[from.ReflexType()]; ok {
+		// Ok to panic since it is build time.
+		panic(errors.New("reaction already added", j.KV("status", fmt.Sprintf("%v", from))))
+	}
+	r.reactions[from.ReflexType()] = reaction[T]{from: from, fn: fn}
+	return r
+}
+
+// Consume transitions the entity of the event if a reaction to its type was
+// added. It is a reflex.ConsumerFunc. Events of entities that aren't in the
+// from status anymore (i.e. the update fails with ErrRowCount) are skipped,
+// so replayed events are idempotent.
+func (r *Reactor[T]) Consume(ctx context.Context, e *reflex.Event) error {
+	re, ok := r.reactions[e.Type.ReflexType()]
+	if !ok {
+		return nil
+	}
+
+	to, updater, err := re.fn(ctx, e)
+	if err != nil {
+		return err
+	} else if to == nil {
+		return nil
+	}
+
+	err = r.fsm.Update(ctx, r.dbc, re.from, to, updater)
+	if errors.Is(err, ErrRowCount) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,87 @@
+package shift_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/luno/jettison/errors"
+	"github.com/luno/jettison/jtest"
+	"github.com/luno/reflex"
+	"github.com/stretchr/testify/require"
+
+	"github.com/luno/shift"
+)
+
+var (
+	_ shift.Transitioner[int64] = (*shift.FSM)(nil)
+	_ shift.Transitioner[int64] = (*shift.ArcFSM)(nil)
+	_ reflex.ConsumerFunc       = shift.NewReactor[int64](fsm, nil).Consume
+)
+
+type transition struct {
+	from, to shift.Status
+	updater  shift.Updater[int64]
+}
+
+type fakeTransitioner struct {
+	transitions []transition
+	err         error
+}
+
+func (f *fakeTransitioner) Update(_ context.Context, _ shift.Beginner, from, to shift.Status, updater shift.Updater[int64]) error {
+	f.transitions = append(f.transitions, transition{from: from, to: to, updater: updater})
+	return f.err
+}
+
+func TestReactor(t *testing.T) {
+	ctx := context.Background()
+	errFailed := errors.New("failed")
+
+	ft := new(fakeTransitioner)
+	r := shift.NewReactor[int64](ft, nil).
+		On(StatusInit, func(_ context.Context, e *reflex.Event) (shift.Status, shift.Updater[int64], error) {
+			return StatusUpdate, update{ID: e.ForeignIDInt()}, nil
+		}).
+		On(StatusUpdate, func(_ context.Context, e *reflex.Event) (shift.Status, shift.Updater[int64], error) {
+			if e.ForeignIDInt() == 2 {
+				return nil, nil, nil
+			}
+			return nil, nil, errFailed
+		})
+
+	err := r.Consume(ctx, &reflex.Event{ForeignID: "1", Type: StatusInit})
+	jtest.RequireNil(t, err)
+
+	// Skipped by the reaction.
+	err = r.Consume(ctx, &reflex.Event{ForeignID: "2", Type: StatusUpdate})
+	jtest.RequireNil(t, err)
+
+	err = r.Consume(ctx, &reflex.Event{ForeignID: "3", Type: StatusUpdate})
+	jtest.Require(t, errFailed, err)
+
+	// No reaction to the type.
+	err = r.Consume(ctx, &reflex.Event{ForeignID: "1", Type: StatusComplete})
+	jtest.RequireNil(t, err)
+
+	require.Equal(t, []transition{
+		{from: StatusInit, to: StatusUpdate, updater: update{ID: 1}},
+	}, ft.transitions)
+
+	// Entities not in the from status anymore are skipped.
+	ft.err = errors.Wrap(shift.ErrRowCount, "update")
+	err = r.Consume(ctx, &reflex.Event{ForeignID: "1", Type: StatusInit})
+	jtest.RequireNil(t, err)
+
+	ft.err = errFailed
+	err = r.Consume(ctx, &reflex.Event{ForeignID: "1", Type: StatusInit})
+	jtest.Require(t, errFailed, err)
+}
+
+func TestReactor_Duplicate(t *testing.T) {
+	r := shift.NewReactor[int64](new(fakeTransitioner), nil)
+	noop := func(context.Context, *reflex.Event) (shift.Status, shift.Updater[int64], error) {
+		return nil, nil, nil
+	}
+	r.On(StatusInit, noop)
+	require.Panics(t, func() { r.On(StatusInit, noop) })
+}
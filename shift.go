@@ -5,18 +5,26 @@
 // the domain model in the underlying sql table via inserts and updates.
 // All mutations update the status of the model, mutates some fields and
 // inserts a reflex event. Note that FSM is opinionated and has the following
-// restrictions: only a single insert status, no transitions back to
-// insert status, only a single transition per pair of statuses.
+// restrictions: only a single insert status per inserter type, no transitions
+// back to insert statuses, only a single transition per pair of statuses.
 //
 // shift.NewArcFSM builds a ArcFSM instance which is the same as an FSM
 // but without its restrictions. It supports arbitrary transitions.
+//
+// shift.NewGenFSM and shift.NewGenArcFSM build FSM and ArcFSM instances
+// for user tables with the primary key type (int64, uint64 or string) as
+// a type parameter.
 package shift
 
 import (
+	"bytes"
+	"cmp"
 	"context"
 	"database/sql"
 	"fmt"
 	"reflect"
+	"slices"
+	"strings"
 
 	"github.com/luno/jettison/errors"
 	"github.com/luno/jettison/j"
@@ -39,13 +47,16 @@ import (
 //		StatusUnknown MyStatus = 0
 //		StatusInsert  MyStatus = 1
 //	)
+//
+// Statuses implementing fmt.Stringer are formatted with String in errors,
+// ex. "from=Pending to=Completed" instead of "from=2 to=3".
 type Status interface {
 	ShiftStatus() int
 	ReflexType() int
 }
 
 type primary interface {
-	int64 | string
+	int64 | uint64 | string
 }
 
 // Inserter provides an interface for inserting new state machine instance rows.
@@ -60,6 +71,41 @@ type Updater[T primary] interface {
 	Update(ctx context.Context, tx *sql.Tx, from Status, to Status) (T, error)
 }
 
+// ForceUpdater provides an interface for updating existing rows regardless of
+// their current status, see GenFSM.ForceUpdate.
+type ForceUpdater[T primary] interface {
+	// ForceUpdate updates the status of an existing row in any status and returns an id or an error.
+	ForceUpdate(ctx context.Context, tx *sql.Tx, to Status) (T, error)
+}
+
+// RawStatus is a status known only by its ShiftStatus, ex. the current status
+// of a row read by a generated ForceUpdate.
+type RawStatus int
+
+func (s RawStatus) ShiftStatus() int { return int(s) }
+func (s RawStatus) ReflexType() int  { return int(s) }
+
+// ForcedMetadata marks the reflex events of forced updates if metadata is
+// enabled. It is encoded with the FSM's metadata codec (JSON by default),
+// use GenFSM.IsForced to detect it, see GenFSM.ForceUpdate. Note that with
+// WithTypedMetadata it is the encoded string, not a value of the metadata
+// type, so consumers must check IsForced before decoding event metadata.
+const ForcedMetadata = "shift:forced"
+
+// BatchInserter provides an interface for inserting multiple new state machine
+// instance rows in a single statement. It is implemented by a slice of an
+// inserter type, ex. `type insertBatch []insert`.
+type BatchInserter[T primary] interface {
+	// InsertBatch inserts new rows with status and returns their ids or an error.
+	InsertBatch(ctx context.Context, tx *sql.Tx, status Status) ([]T, error)
+}
+
+// Deleter provides an interface for deleting existing rows.
+type Deleter[T primary] interface {
+	// Delete deletes the row if it is in the from status and returns its id or an error.
+	Delete(ctx context.Context, tx *sql.Tx, from Status) (T, error)
+}
+
 // MetadataInserter extends inserter with additional metadata inserted with the reflex event.
 type MetadataInserter[T primary] interface {
 	Inserter[T]
@@ -76,9 +122,45 @@ type MetadataUpdater[T primary] interface {
 	GetMetadata(ctx context.Context, tx *sql.Tx, from Status, to Status) ([]byte, error)
 }
 
+// TypedMetadataInserter extends inserter with additional metadata of type M
+// inserted with the reflex event, see WithTypedMetadata.
+type TypedMetadataInserter[T primary, M any] interface {
+	Inserter[T]
+
+	// GetMetadata returns the metadata to be encoded and inserted with the reflex event for the insert.
+	GetMetadata(ctx context.Context, tx *sql.Tx, id T, status Status) (M, error)
+}
+
+// TypedMetadataDeleter extends deleter with additional metadata of type M
+// inserted with the reflex event, see WithTypedMetadata.
+type TypedMetadataDeleter[T primary, M any] interface {
+	Deleter[T]
+
+	// GetMetadata returns the metadata to be encoded and inserted with the reflex event for the delete.
+	GetMetadata(ctx context.Context, tx *sql.Tx, from Status) (M, error)
+}
+
+// TypedMetadataUpdater extends updater with additional metadata of type M
+// inserted with the reflex event, see WithTypedMetadata.
+type TypedMetadataUpdater[T primary, M any] interface {
+	Updater[T]
+
+	// GetMetadata returns the metadata to be encoded and inserted with the reflex event for the update.
+	GetMetadata(ctx context.Context, tx *sql.Tx, from Status, to Status) (M, error)
+}
+
+// MetadataDeleter extends deleter with additional metadata inserted with the reflex event.
+type MetadataDeleter[T primary] interface {
+	Deleter[T]
+
+	// GetMetadata returns the metadata to be inserted with the reflex event for the delete.
+	GetMetadata(ctx context.Context, tx *sql.Tx, from Status) ([]byte, error)
+}
+
 // ValidatingInserter extends inserter with validation. Assuming the majority
 // validations will be successful, the validation is done after event insertion
 // to allow maximum flexibility sacrificing invalid path performance.
+// Use WithValidationBefore to validate before event insertion instead.
 type ValidatingInserter[T primary] interface {
 	Inserter[T]
 
@@ -89,6 +171,7 @@ type ValidatingInserter[T primary] interface {
 // ValidatingUpdater extends updater with validation. Assuming the majority
 // validations will be successful, the validation is done after event insertion
 // to allow maximum flexibility sacrificing invalid path performance.
+// Use WithValidationBefore to validate before event insertion instead.
 type ValidatingUpdater[T primary] interface {
 	Updater[T]
 
@@ -96,13 +179,29 @@ type ValidatingUpdater[T primary] interface {
 	Validate(ctx context.Context, tx *sql.Tx, from Status, to Status) error
 }
 
-// eventInserter inserts reflex events into a sql DB table.
+// ValidatingDeleter extends deleter with validation. The validation is done
+// after the event insertion (or before with WithValidationBefore), like for
+// inserters and updaters.
+type ValidatingDeleter[T primary] interface {
+	Deleter[T]
+
+	// Validate returns an error if the delete is not valid.
+	Validate(ctx context.Context, tx *sql.Tx, from Status) error
+}
+
+// EventInserter inserts reflex events into a sql DB table.
 // It is implemented by rsql.EventsTable or rsql.EventsTableInt.
-type eventInserter[T primary] interface {
+type EventInserter[T primary] interface {
 	InsertWithMetadata(ctx context.Context, dbc rsql.DBC, foreignID T,
 		typ reflex.EventType, metadata []byte) (rsql.NotifyFunc, error)
 }
 
+// EventStreamer streams the reflex events of an events table. It is
+// implemented by rsql.EventsTable, rsql.EventsTableInt and shifttest.Events.
+type EventStreamer interface {
+	ToStream(dbc *sql.DB, opts ...reflex.StreamOption) reflex.StreamFunc
+}
+
 type FSM = GenFSM[int64]
 
 // GenFSM is a defined Finite-State-Machine that allows specific mutations of
@@ -113,124 +212,514 @@ type FSM = GenFSM[int64]
 // The type of the GenFSM is the type of the primary key used by the user table.
 //
 // Note that this FSM is opinionated and has the following
-// restrictions: only a single insert status, no transitions back to
-// insert status, only a single transition per pair of statuses.
+// restrictions: only a single insert status per inserter type, no transitions
+// back to insert statuses, only a single transition per pair of statuses.
 type GenFSM[T primary] struct {
 	options
-	events       eventInserter[T]
-	states       map[int]status
-	insertStatus Status
+	events         EventInserter[T]
+	states         map[int]status
+	insertStatuses []Status
+
+	// buildErr is the first error encountered while building the FSM.
+	buildErr error
 }
 
 // Insert returns the id of the newly inserted domain model.
-func (fsm *GenFSM[T]) Insert(ctx context.Context, dbc *sql.DB, inserter Inserter[T]) (T, error) {
-	var zeroT T
-	tx, err := dbc.Begin()
-	if err != nil {
-		return zeroT, err
-	}
-	defer tx.Rollback()
+func (fsm *GenFSM[T]) Insert(ctx context.Context, dbc Beginner, inserter Inserter[T]) (_ T, err error) {
+	st := fsm.insertStatusFor(inserter, func(s status, inserter any) bool { return cachedType(s.typ, inserter) })
+	ctx, end := fsm.startSpan(ctx, "shift.Insert", nil, st)
+	defer func() { end(err) }()
+	ctx, done := fsm.observe(ctx, nil, st)
+	defer func() { done(err) }()
 
-	id, notify, err := fsm.InsertTx(ctx, tx, inserter)
-	if err != nil {
+	// Fail fast without starting a transaction.
+	if _, err := fsm.checkInsert(inserter); err != nil {
+		var zeroT T
 		return zeroT, err
 	}
 
-	err = tx.Commit()
+	var id T
+	err = fsm.runTx(ctx, dbc, func(ctx context.Context, tx *sql.Tx) (notify rsql.NotifyFunc, err error) {
+		id, notify, err = fsm.InsertTx(ctx, tx, inserter)
+		return notify, err
+	})
 	if err != nil {
+		var zeroT T
 		return zeroT, err
 	}
-
-	notify()
 	return id, nil
 }
 
 func (fsm *GenFSM[T]) InsertTx(ctx context.Context, tx *sql.Tx, inserter Inserter[T]) (T, rsql.NotifyFunc, error) {
-	st := fsm.insertStatus
-	if !sameType(fsm.states[st.ShiftStatus()].req, inserter) {
+	st, err := fsm.checkInsert(inserter)
+	if err != nil {
+		var zeroT T
+		return zeroT, nil, err
+	}
+
+	var (
+		id     T
+		notify rsql.NotifyFunc
+	)
+	err = fsm.savepoint(ctx, tx, func() (err error) {
+		id, notify, err = insertTx[T](ctx, tx, st, inserter, fsm.events, fsm.states[st.ShiftStatus()].t, fsm.options)
+		return err
+	})
+	if err != nil {
 		var zeroT T
-		return zeroT, nil, errors.Wrap(ErrInvalidType, "inserter can't be used for this transition")
+		return zeroT, nil, err
+	}
+	return id, notify, nil
+}
+
+// checkInsert returns the insert status of the inserter or an error if
+// the inserter can't be used to insert.
+func (fsm *GenFSM[T]) checkInsert(inserter Inserter[T]) (Status, error) {
+	st := fsm.insertStatusFor(inserter, func(s status, inserter any) bool { return cachedType(s.typ, inserter) })
+	if st == nil {
+		return nil, errors.Wrap(ErrInvalidType, "inserter can't be used for this transition", j.MKV{
+			"expected": fsm.insertTypes(func(s status) reflect.Type { return s.typ }),
+			"provided": typeString(reflect.TypeOf(inserter)),
+		})
+	}
+	return st, nil
+}
+
+// InsertBatch returns the ids of the newly inserted domain models. A reflex
+// event is inserted for each row. Metadata and validation are not supported
+// for batch inserts, except for metadata provided via WithMetadataFunc.
+func (fsm *GenFSM[T]) InsertBatch(ctx context.Context, dbc Beginner, batch BatchInserter[T]) (_ []T, err error) {
+	st := fsm.insertStatusFor(batch, func(s status, batch any) bool { return sameElemType(s.req, batch) })
+	ctx, end := fsm.startSpan(ctx, "shift.InsertBatch", nil, st)
+	defer func() { end(err) }()
+	ctx, done := fsm.observe(ctx, nil, st)
+	defer func() { done(err) }()
+
+	// Fail fast without starting a transaction.
+	if _, err := fsm.checkInsertBatch(batch); err != nil {
+		return nil, err
 	}
 
-	return insertTx[T](ctx, tx, st, inserter, fsm.events, fsm.states[st.ShiftStatus()].t, fsm.options)
+	var ids []T
+	err = fsm.runTx(ctx, dbc, func(ctx context.Context, tx *sql.Tx) (notify rsql.NotifyFunc, err error) {
+		ids, notify, err = fsm.InsertBatchTx(ctx, tx, batch)
+		return notify, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
 }
 
-func (fsm *GenFSM[T]) Update(ctx context.Context, dbc *sql.DB, from Status, to Status, updater Updater[T]) error {
-	tx, err := dbc.Begin()
+func (fsm *GenFSM[T]) InsertBatchTx(ctx context.Context, tx *sql.Tx, batch BatchInserter[T]) ([]T, rsql.NotifyFunc, error) {
+	st, err := fsm.checkInsertBatch(batch)
 	if err != nil {
+		return nil, nil, err
+	}
+
+	ids, err := batch.InsertBatch(ctx, tx, st)
+	if err != nil {
+		return nil, nil, wrapDuplicate(err)
+	}
+	fsm.setID(ctx, ids)
+
+	var notifies []rsql.NotifyFunc
+	for _, id := range ids {
+		var metadata []byte
+		if fsm.metadataFunc != nil {
+			metadata, err = fsm.metadataFunc(ctx, id, nil, st)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+
+		notify, err := eventsFor(fsm.options, id, fsm.events).InsertWithMetadata(ctx, eventsDBC(ctx, tx), id, fsm.states[st.ShiftStatus()].t, metadata)
+		if err != nil {
+			return nil, nil, err
+		}
+		notifies = append(notifies, wrapNotify(fsm.options, notify))
+	}
+
+	return ids, func() {
+		for _, notify := range notifies {
+			notify()
+		}
+	}, nil
+}
+
+// checkInsertBatch returns the insert status of the batch inserter or an error
+// if the batch inserter can't be used to insert.
+func (fsm *GenFSM[T]) checkInsertBatch(batch BatchInserter[T]) (Status, error) {
+	st := fsm.insertStatusFor(batch, func(s status, batch any) bool { return sameElemType(s.req, batch) })
+	if st == nil {
+		return nil, errors.Wrap(ErrInvalidType, "batch inserter can't be used for this transition", j.MKV{
+			"expected": fsm.insertTypes(func(s status) reflect.Type { return reflect.SliceOf(s.typ) }),
+			"provided": typeString(reflect.TypeOf(batch)),
+		})
+	}
+	if fsm.withMetadata || fsm.withValidation {
+		return nil, errors.Wrap(ErrInvalidType, "batch inserts don't support metadata or validation")
+	}
+	return st, nil
+}
+
+func (fsm *GenFSM[T]) Update(ctx context.Context, dbc Beginner, from Status, to Status, updater Updater[T]) (err error) {
+	ctx, end := fsm.startSpan(ctx, "shift.Update", from, to)
+	defer func() { end(err) }()
+	ctx, done := fsm.observe(ctx, from, to)
+	defer func() { done(err) }()
+
+	// Fail fast without starting a transaction.
+	if _, _, err := fsm.checkUpdate(from, to, updater); err != nil {
+		return err
+	}
+
+	return fsm.runTx(ctx, dbc, func(ctx context.Context, tx *sql.Tx) (rsql.NotifyFunc, error) {
+		return fsm.UpdateTx(ctx, tx, from, to, updater)
+	})
+}
+
+func (fsm *GenFSM[T]) UpdateTx(ctx context.Context, tx *sql.Tx, from Status, to Status, updater Updater[T]) (rsql.NotifyFunc, error) {
+	t, tr, err := fsm.checkUpdate(from, to, updater)
+	if err != nil {
+		return nil, err
+	}
+
+	var notify rsql.NotifyFunc
+	err = fsm.savepoint(ctx, tx, func() (err error) {
+		notify, err = updateTx(ctx, tx, from, to, updater, fsm.events, t.t, tr.category, fsm.options)
 		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return notify, nil
+}
+
+// checkUpdate returns the to status and the transition or an error if the
+// updater can't be used to transition from the from to the to status.
+func (fsm *GenFSM[T]) checkUpdate(from Status, to Status, updater Updater[T]) (status, transition, error) {
+	if t, ok := fsm.states[to.ShiftStatus()]; ok && !cachedType(t.typ, updater) {
+		return status{}, transition{}, errors.Wrap(ErrInvalidType, "updater can't be used for this transition", j.MKV{
+			"from":     fmt.Sprintf("%v", from),
+			"to":       fmt.Sprintf("%v", to),
+			"expected": typeString(t.typ),
+			"provided": typeString(reflect.TypeOf(updater)),
+		})
+	}
+	return fsm.checkTransition(from, to)
+}
+
+// checkTransition returns the to status and the transition or an error if
+// the FSM doesn't allow transitioning from the from to the to status.
+func (fsm *GenFSM[T]) checkTransition(from Status, to Status) (status, transition, error) {
+	t, ok := fsm.states[to.ShiftStatus()]
+	if !ok {
+		return status{}, transition{}, errors.Wrap(ErrUnknownStatus, "unknown 'to' status", j.MKV{"from": fmt.Sprintf("%v", from), "to": fmt.Sprintf("%v", to)})
+	}
+	f, ok := fsm.states[from.ShiftStatus()]
+	if !ok {
+		return status{}, transition{}, errors.Wrap(ErrUnknownStatus, "unknown 'from' status", j.MKV{"from": fmt.Sprintf("%v", from), "to": fmt.Sprintf("%v", to)})
 	}
-	defer tx.Rollback()
+	tr, ok := f.next[to]
+	if !ok {
+		kv := j.MKV{
+			"from":  fmt.Sprintf("%v", from),
+			"to":    fmt.Sprintf("%v", to),
+			"valid": fmt.Sprintf("%v", f.nextStatuses()),
+		}
+		if from.ShiftStatus() == to.ShiftStatus() {
+			return status{}, transition{}, errors.Wrap(ErrAlreadyInState, "", kv)
+		}
+		return status{}, transition{}, errors.Wrap(ErrInvalidStateTransition, "", kv)
+	}
+	return t, tr, nil
+}
 
-	notify, err := fsm.UpdateTx(ctx, tx, from, to, updater)
+// UpdateMany transitions the entities with the ids from the from to the to
+// status in a single update statement, ex. for operational tasks like expiring
+// all pending entities, and inserts a reflex event for each transitioned entity
+// in the same transaction. It returns the number of entities transitioned and
+// the ids skipped since they aren't in the from status (or don't exist).
+//
+// The FSM's table must be provided via WithTable and have "status" and "id"
+// (see WithIDColumn) columns. Only the status column is updated, so other columns (ex. updated_at)
+// must be updated via an updater instead. Metadata and validation are not
+// supported, except for metadata provided via WithMetadataFunc or
+// WithCategoryMetadata. The ids are queried in chunks of at most 1000.
+//
+// UpdateMany is MySQL only, since its queries use "?" placeholders and
+// "select ... for update" row locks.
+func (fsm *GenFSM[T]) UpdateMany(ctx context.Context, dbc Beginner, from Status, to Status, ids []T) (n int, skipped []T, err error) {
+	ctx, end := fsm.startSpan(ctx, "shift.UpdateMany", from, to)
+	defer func() { end(err) }()
+	ctx, done := fsm.observe(ctx, from, to)
+	defer func() { done(err) }()
+
+	// Fail fast without starting a transaction.
+	if _, _, err := fsm.checkUpdateMany(from, to); err != nil {
+		return 0, nil, err
+	}
+
+	err = fsm.runTx(ctx, dbc, func(ctx context.Context, tx *sql.Tx) (notify rsql.NotifyFunc, err error) {
+		n, skipped, notify, err = fsm.UpdateManyTx(ctx, tx, from, to, ids)
+		return notify, err
+	})
 	if err != nil {
+		return 0, nil, err
+	}
+	return n, skipped, nil
+}
+
+func (fsm *GenFSM[T]) UpdateManyTx(ctx context.Context, tx *sql.Tx, from Status, to Status, ids []T) (int, []T, rsql.NotifyFunc, error) {
+	t, tr, err := fsm.checkUpdateMany(from, to)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	var (
+		updated []T
+		skipped []T
+		notify  rsql.NotifyFunc
+	)
+	err = fsm.savepoint(ctx, tx, func() (err error) {
+		updated, skipped, notify, err = updateManyTx(ctx, tx, from, to, ids, fsm.events, t.t, tr.category, fsm.options)
 		return err
+	})
+	if err != nil {
+		return 0, nil, nil, err
 	}
+	return len(updated), skipped, notify, nil
+}
+
+// checkUpdateMany returns the to status and the transition or an error if
+// UpdateMany can't be used to transition from the from to the to status.
+func (fsm *GenFSM[T]) checkUpdateMany(from Status, to Status) (status, transition, error) {
+	if fsm.table == "" {
+		return status{}, transition{}, errors.Wrap(ErrInvalidFSM, "update many requires WithTable")
+	}
+	if fsm.withValidation || fsm.typedMetadata != nil || (fsm.withMetadata && fsm.metadataFunc == nil) {
+		return status{}, transition{}, errors.Wrap(ErrInvalidType, "update many doesn't support metadata or validation")
+	}
+	return fsm.checkTransition(from, to)
+}
+
+// ForceUpdate updates the entity to the status regardless of its current status
+// and the FSM's transitions, ex. for data repair in admin tooling. It must not
+// be used by normal business logic. The updater type must be the one added for
+// the to status and implement ForceUpdater (ex. generated via -force_updaters).
+// The to status's reflex event is inserted, with the encoded ForcedMetadata
+// if metadata is enabled (WithMetadata, WithTypedMetadata, WithMetadataFunc
+// or WithCategoryMetadata), see IsForced. Validation is skipped.
+func (fsm *GenFSM[T]) ForceUpdate(ctx context.Context, dbc Beginner, to Status, updater ForceUpdater[T]) (err error) {
+	ctx, end := fsm.startSpan(ctx, "shift.ForceUpdate", nil, to)
+	defer func() { end(err) }()
+	ctx, done := fsm.observe(ctx, nil, to)
+	defer func() { done(err) }()
+
+	// Fail fast without starting a transaction.
+	if _, err := fsm.checkForceUpdate(to, updater); err != nil {
+		return err
+	}
+
+	return fsm.runTx(ctx, dbc, func(ctx context.Context, tx *sql.Tx) (rsql.NotifyFunc, error) {
+		return fsm.ForceUpdateTx(ctx, tx, to, updater)
+	})
+}
 
-	err = tx.Commit()
+// ForceUpdateTx is like ForceUpdate, but in a transaction owned by the caller.
+func (fsm *GenFSM[T]) ForceUpdateTx(ctx context.Context, tx *sql.Tx, to Status, updater ForceUpdater[T]) (rsql.NotifyFunc, error) {
+	t, err := fsm.checkForceUpdate(to, updater)
 	if err != nil {
+		return nil, err
+	}
+
+	var notify rsql.NotifyFunc
+	err = fsm.savepoint(ctx, tx, func() error {
+		id, err := updater.ForceUpdate(ctx, tx, to)
+		if err != nil {
+			return err
+		}
+		fsm.setID(ctx, id)
+
+		metadata, err := fsm.forcedMetadata()
+		if err != nil {
+			return err
+		}
+
+		notify, err = eventsFor(fsm.options, id, fsm.events).InsertWithMetadata(ctx, eventsDBC(ctx, tx), id, t.t, metadata)
+		notify = wrapNotify(fsm.options, notify)
 		return err
+	})
+	if err != nil {
+		return nil, err
 	}
+	return notify, nil
+}
 
-	notify()
-	return nil
+// forcedMetadata returns the encoded ForcedMetadata or nil if metadata isn't enabled.
+func (o options) forcedMetadata() ([]byte, error) {
+	if !o.withMetadata && o.typedMetadata == nil && o.metadataFunc == nil && !o.withCategoryMetadata {
+		return nil, nil
+	}
+	return o.marshalMetadata(ForcedMetadata)
 }
 
-func (fsm *GenFSM[T]) UpdateTx(ctx context.Context, tx *sql.Tx, from Status, to Status, updater Updater[T]) (rsql.NotifyFunc, error) {
+// IsForced returns true if the event metadata marks a forced update, see
+// ForceUpdate. It is false if metadata isn't enabled.
+func (fsm *GenFSM[T]) IsForced(metadata []byte) bool {
+	forced, err := fsm.forcedMetadata()
+	if err != nil || forced == nil {
+		return false
+	}
+	return bytes.Equal(metadata, forced)
+}
+
+// checkForceUpdate returns the to status or an error if the updater can't
+// be used to force update to it.
+func (fsm *GenFSM[T]) checkForceUpdate(to Status, updater ForceUpdater[T]) (status, error) {
 	t, ok := fsm.states[to.ShiftStatus()]
 	if !ok {
-		return nil, errors.Wrap(ErrUnknownStatus, "unknown 'to' status", j.MKV{"from": fmt.Sprintf("%v", from), "to": fmt.Sprintf("%v", to)})
+		return status{}, errors.Wrap(ErrUnknownStatus, "unknown 'to' status", j.MKV{"to": fmt.Sprintf("%v", to)})
 	}
-	if !sameType(t.req, updater) {
-		return nil, errors.Wrap(ErrInvalidType, "updater can't be used for this transition", j.MKV{"from": fmt.Sprintf("%v", from), "to": fmt.Sprintf("%v", to)})
+	if !cachedType(t.typ, updater) {
+		return status{}, errors.Wrap(ErrInvalidType, "updater can't be used for this transition", j.MKV{
+			"to":       fmt.Sprintf("%v", to),
+			"expected": typeString(t.typ),
+			"provided": typeString(reflect.TypeOf(updater)),
+		})
 	}
+	return t, nil
+}
+
+// Delete deletes the entity in the from status with the deleter. The from
+// status must allow deletion with the deleter type, see the builder's Delete.
+func (fsm *GenFSM[T]) Delete(ctx context.Context, dbc Beginner, from Status, deleter Deleter[T]) (err error) {
+	ctx, end := fsm.startSpan(ctx, "shift.Delete", from, nil)
+	defer func() { end(err) }()
+	ctx, done := fsm.observe(ctx, from, nil)
+	defer func() { done(err) }()
+
+	// Fail fast without starting a transaction.
+	if _, err := fsm.checkDelete(from, deleter); err != nil {
+		return err
+	}
+
+	return fsm.runTx(ctx, dbc, func(ctx context.Context, tx *sql.Tx) (rsql.NotifyFunc, error) {
+		return fsm.DeleteTx(ctx, tx, from, deleter)
+	})
+}
+
+func (fsm *GenFSM[T]) DeleteTx(ctx context.Context, tx *sql.Tx, from Status, deleter Deleter[T]) (rsql.NotifyFunc, error) {
+	f, err := fsm.checkDelete(from, deleter)
+	if err != nil {
+		return nil, err
+	}
+
+	var notify rsql.NotifyFunc
+	err = fsm.savepoint(ctx, tx, func() (err error) {
+		notify, err = deleteTx(ctx, tx, from, deleter, fsm.events, f.delete.t, fsm.options)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return notify, nil
+}
+
+// checkDelete returns the from status or an error if the deleter can't be
+// used to delete in it.
+func (fsm *GenFSM[T]) checkDelete(from Status, deleter Deleter[T]) (status, error) {
 	f, ok := fsm.states[from.ShiftStatus()]
 	if !ok {
-		return nil, errors.Wrap(ErrUnknownStatus, "unknown 'from' status", j.MKV{"from": fmt.Sprintf("%v", from), "to": fmt.Sprintf("%v", to)})
-	} else if !f.next[to] {
-		return nil, errors.Wrap(ErrInvalidStateTransition, "", j.MKV{"from": fmt.Sprintf("%v", from), "to": fmt.Sprintf("%v", to)})
+		return status{}, errors.Wrap(ErrUnknownStatus, "unknown 'from' status", j.MKV{"from": fmt.Sprintf("%v", from)})
 	}
+	if f.delete == nil {
+		return status{}, errors.Wrap(ErrInvalidStateTransition, "delete not allowed", j.MKV{"from": fmt.Sprintf("%v", from)})
+	}
+	if !cachedType(f.delete.typ, deleter) {
+		return status{}, errors.Wrap(ErrInvalidType, "deleter can't be used for this transition", j.MKV{
+			"from":     fmt.Sprintf("%v", from),
+			"expected": typeString(f.delete.typ),
+			"provided": typeString(reflect.TypeOf(deleter)),
+		})
+	}
+	return f, nil
+}
 
-	return updateTx(ctx, tx, from, to, updater, fsm.events, t.t, fsm.options)
+// TransitionCategory returns the category of the transition between the
+// statuses or an empty string if the transition has no category or doesn't exist.
+func (fsm *GenFSM[T]) TransitionCategory(from Status, to Status) string {
+	return fsm.states[from.ShiftStatus()].next[to].category
+}
+
+// IsValidTransition returns true if the FSM allows updating from and to the
+// statuses. It returns false for unknown statuses.
+func (fsm *GenFSM[T]) IsValidTransition(from Status, to Status) bool {
+	if _, ok := fsm.states[to.ShiftStatus()]; !ok {
+		return false
+	}
+	f, ok := fsm.states[from.ShiftStatus()]
+	if !ok {
+		return false
+	}
+	_, ok = f.next[to]
+	return ok
 }
 
 func insertTx[T primary](ctx context.Context, tx *sql.Tx, st Status, inserter Inserter[T],
-	events eventInserter[T], eventType reflex.EventType, opts options,
+	events EventInserter[T], eventType reflex.EventType, opts options,
 ) (T, rsql.NotifyFunc, error) {
 	var zeroT T
 
 	id, err := inserter.Insert(ctx, tx, st)
-	if err != nil {
-		return zeroT, nil, err
+	if errors.Is(err, ErrAlreadyInserted) {
+		// Replayed idempotent insert, the row isn't changed so no event is inserted.
+		opts.setID(ctx, id)
+		return id, func() {}, nil
+	} else if err != nil {
+		return zeroT, nil, wrapDuplicate(err)
 	}
+	opts.setID(ctx, id)
 
-	var metadata []byte
-	if opts.withMetadata {
-		meta, ok := inserter.(MetadataInserter[T])
-		if !ok {
-			return zeroT, nil, errors.Wrap(ErrInvalidType, "inserter without metadata")
+	if opts.withValidation && opts.validationBefore {
+		if err := validateInsert(ctx, tx, inserter, id, st); err != nil {
+			return zeroT, nil, err
 		}
+	}
 
+	var metadata []byte
+	if typed, ok := opts.typedMetadata.(typedMetadata[T]); ok {
+		meta, err := typed.insert(ctx, tx, inserter, id, st)
+		if err != nil {
+			return zeroT, nil, err
+		}
+		metadata, err = opts.marshalMetadata(meta)
+		if err != nil {
+			return zeroT, nil, err
+		}
+	} else if opts.withMetadata || opts.metadataFunc != nil {
 		var err error
-		metadata, err = meta.GetMetadata(ctx, tx, id, st)
+		if meta, ok := inserter.(MetadataInserter[T]); ok {
+			metadata, err = meta.GetMetadata(ctx, tx, id, st)
+		} else if opts.metadataFunc != nil {
+			metadata, err = opts.metadataFunc(ctx, id, nil, st)
+		} else {
+			return zeroT, nil, errors.Wrap(ErrInvalidType, "inserter without metadata")
+		}
 		if err != nil {
 			return zeroT, nil, err
 		}
 	}
 
-	notify, err := events.InsertWithMetadata(ctx, tx, id, eventType, metadata)
+	notify, err := eventsFor(opts, id, events).InsertWithMetadata(ctx, eventsDBC(ctx, tx), id, eventType, metadata)
 	if err != nil {
 		return zeroT, nil, err
 	}
+	notify = wrapNotify(opts, notify)
 
-	if opts.withValidation {
-		validate, ok := inserter.(ValidatingInserter[T])
-		if !ok {
-			return zeroT, nil, errors.Wrap(ErrInvalidType, "inserter without validate method")
-		}
-
-		err = validate.Validate(ctx, tx, id, st)
-		if err != nil {
+	if opts.withValidation && !opts.validationBefore {
+		if err := validateInsert(ctx, tx, inserter, id, st); err != nil {
 			return zeroT, nil, err
 		}
 	}
@@ -238,56 +727,467 @@ func insertTx[T primary](ctx context.Context, tx *sql.Tx, st Status, inserter In
 	return id, notify, err
 }
 
+// validateInsert validates the insert with the inserter's Validate method.
+func validateInsert[T primary](ctx context.Context, tx *sql.Tx, inserter Inserter[T], id T, st Status) error {
+	validate, ok := inserter.(ValidatingInserter[T])
+	if !ok {
+		return errors.Wrap(ErrInvalidType, "inserter without validate method")
+	}
+
+	err := validate.Validate(ctx, tx, id, st)
+	if err != nil {
+		setResult(ctx, resultValidation)
+		return err
+	}
+	return nil
+}
+
 func updateTx[T primary](ctx context.Context, tx *sql.Tx, from Status, to Status, updater Updater[T],
-	events eventInserter[T], eventType reflex.EventType, opts options,
+	events EventInserter[T], eventType reflex.EventType, category string, opts options,
 ) (rsql.NotifyFunc, error) {
+	if opts.withValidation && opts.validationBefore {
+		if err := validateUpdate(ctx, tx, updater, from, to); err != nil {
+			return nil, err
+		}
+	}
+
 	id, err := updater.Update(ctx, tx, from, to)
 	if err != nil {
 		return nil, err
 	}
+	opts.setID(ctx, id)
 
 	var metadata []byte
-	if opts.withMetadata {
-		meta, ok := updater.(MetadataUpdater[T])
-		if !ok {
+	if opts.withCategoryMetadata && category != "" {
+		metadata = []byte(category)
+	} else if typed, ok := opts.typedMetadata.(typedMetadata[T]); ok {
+		meta, err := typed.update(ctx, tx, updater, from, to)
+		if err != nil {
+			return nil, err
+		}
+		metadata, err = opts.marshalMetadata(meta)
+		if err != nil {
+			return nil, err
+		}
+	} else if opts.withMetadata || opts.metadataFunc != nil {
+		var err error
+		if meta, ok := updater.(MetadataUpdater[T]); ok {
+			metadata, err = meta.GetMetadata(ctx, tx, from, to)
+		} else if opts.metadataFunc != nil {
+			metadata, err = opts.metadataFunc(ctx, id, from, to)
+		} else {
 			return nil, errors.Wrap(ErrInvalidType, "updater without metadata")
 		}
+		if err != nil {
+			return nil, err
+		}
+	}
 
-		var err error
-		metadata, err = meta.GetMetadata(ctx, tx, from, to)
+	notify, err := eventsFor(opts, id, events).InsertWithMetadata(ctx, eventsDBC(ctx, tx), id, eventType, metadata)
+	if err != nil {
+		return nil, err
+	}
+	notify = wrapNotify(opts, notify)
+
+	if opts.withValidation && !opts.validationBefore {
+		if err := validateUpdate(ctx, tx, updater, from, to); err != nil {
+			return nil, err
+		}
+	}
+
+	return notify, nil
+}
+
+// updateManyTx updates the status of the rows with the ids in the from status
+// and inserts an event for each. It returns the updated ids and the skipped ids
+// in the order of ids, ignoring duplicates. The rows are locked before updating
+// so that the events match the rows actually updated.
+func updateManyTx[T primary](ctx context.Context, tx *sql.Tx, from Status, to Status, ids []T,
+	events EventInserter[T], eventType reflex.EventType, category string, opts options,
+) ([]T, []T, rsql.NotifyFunc, error) {
+	if len(ids) == 0 {
+		return nil, nil, func() {}, nil
+	}
+
+	found := make(map[T]bool)
+	for _, chunk := range chunkIDs(ids) {
+		args := make([]any, 0, len(chunk)+1)
+		for _, id := range chunk {
+			args = append(args, id)
+		}
+		args = append(args, from.ShiftStatus())
+
+		rows, err := tx.QueryContext(ctx, "select "+opts.idColumn()+" from "+opts.table+" where "+opts.idColumn()+" in "+inList(len(chunk))+" and status=? for update", args...)
 		if err != nil {
+			return nil, nil, nil, err
+		}
+		for rows.Next() {
+			var id T
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, nil, nil, err
+			}
+			found[id] = true
+		}
+		if err := rows.Err(); err != nil {
+			rows.Close()
+			return nil, nil, nil, err
+		}
+		rows.Close()
+	}
+
+	var updated, skipped []T
+	seen := make(map[T]bool)
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if found[id] {
+			updated = append(updated, id)
+		} else {
+			skipped = append(skipped, id)
+		}
+	}
+	if len(updated) == 0 {
+		return nil, skipped, func() {}, nil
+	}
+
+	// The rows are locked, so all of them are updated, except for self
+	// transitions which don't change the rows.
+	if from.ShiftStatus() != to.ShiftStatus() {
+		for _, chunk := range chunkIDs(updated) {
+			args := make([]any, 0, len(chunk)+2)
+			args = append(args, to.ShiftStatus())
+			for _, id := range chunk {
+				args = append(args, id)
+			}
+			args = append(args, from.ShiftStatus())
+
+			res, err := tx.ExecContext(ctx, "update "+opts.table+" set status=? where "+opts.idColumn()+" in "+inList(len(chunk))+" and status=?", args...)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			n, err := res.RowsAffected()
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			if n != int64(len(chunk)) {
+				return nil, nil, nil, errors.Wrap(ErrRowCount, "", j.MKV{"expected": len(chunk), "updated": n})
+			}
+		}
+	}
+
+	var notifies []rsql.NotifyFunc
+	for _, id := range updated {
+		var metadata []byte
+		if opts.withCategoryMetadata && category != "" {
+			metadata = []byte(category)
+		} else if opts.metadataFunc != nil {
+			var err error
+			metadata, err = opts.metadataFunc(ctx, id, from, to)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+		}
+
+		notify, err := eventsFor(opts, id, events).InsertWithMetadata(ctx, eventsDBC(ctx, tx), id, eventType, metadata)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		notifies = append(notifies, wrapNotify(opts, notify))
+	}
+
+	return updated, skipped, func() {
+		for _, notify := range notifies {
+			notify()
+		}
+	}, nil
+}
+
+// updateManyChunk is the maximum number of ids in the in clause of a single
+// UpdateMany query, keeping the queries within placeholder and packet limits.
+const updateManyChunk = 1000
+
+// chunkIDs returns the ids split into chunks of at most updateManyChunk ids.
+func chunkIDs[T primary](ids []T) [][]T {
+	var res [][]T
+	for len(ids) > updateManyChunk {
+		res = append(res, ids[:updateManyChunk])
+		ids = ids[updateManyChunk:]
+	}
+	if len(ids) > 0 {
+		res = append(res, ids)
+	}
+	return res
+}
+
+// inList returns an in clause with n placeholders, ex. "(?, ?)".
+func inList(n int) string {
+	return "(?" + strings.Repeat(", ?", n-1) + ")"
+}
+
+// validateUpdate validates the update with the updater's Validate method.
+func validateUpdate[T primary](ctx context.Context, tx *sql.Tx, updater Updater[T], from Status, to Status) error {
+	validate, ok := updater.(ValidatingUpdater[T])
+	if !ok {
+		return errors.Wrap(ErrInvalidType, "updater without validate method")
+	}
+
+	err := validate.Validate(ctx, tx, from, to)
+	if err != nil {
+		setResult(ctx, resultValidation)
+		return err
+	}
+	return nil
+}
+
+// Transition is a transition of an FSM from one status to another.
+type Transition struct {
+	From Status
+	To   Status
+
+	// Category is the category of the transition, see Category.
+	Category string
+}
+
+// InsertStatus returns the status of inserted entities, or the first insert
+// status if the FSM has multiple, see InsertStatuses.
+func (fsm *GenFSM[T]) InsertStatus() Status {
+	if len(fsm.insertStatuses) == 0 {
+		return nil
+	}
+	return fsm.insertStatuses[0]
+}
+
+// InsertStatuses returns the insert statuses of the FSM in the order they were added.
+func (fsm *GenFSM[T]) InsertStatuses() []Status {
+	return slices.Clone(fsm.insertStatuses)
+}
+
+// insertStatusFor returns the insert status of the inserter or nil if no
+// insert status matches the inserter according to match.
+func (fsm *GenFSM[T]) insertStatusFor(inserter any, match func(s status, inserter any) bool) Status {
+	for _, st := range fsm.insertStatuses {
+		if match(fsm.states[st.ShiftStatus()], inserter) {
+			return st
+		}
+	}
+	return nil
+}
+
+// States returns the statuses of the FSM ordered by ShiftStatus.
+func (fsm *GenFSM[T]) States() []Status {
+	res := make([]Status, 0, len(fsm.states))
+	for _, s := range fsm.states {
+		res = append(res, s.st)
+	}
+	sortStatuses(res)
+	return res
+}
+
+// Transitions returns the allowed update transitions of the FSM ordered by
+// the ShiftStatus of the from and then the to status.
+func (fsm *GenFSM[T]) Transitions() []Transition {
+	var res []Transition
+	for _, s := range fsm.states {
+		for next, t := range s.next {
+			res = append(res, Transition{From: s.st, To: next, Category: t.category})
+		}
+	}
+	sortTransitions(res)
+	return res
+}
+
+// insertTypes returns the comma separated types of the insert statuses,
+// ex. the expected inserter types.
+func (fsm *GenFSM[T]) insertTypes(typ func(s status) reflect.Type) string {
+	var names []string
+	for _, st := range fsm.insertStatuses {
+		names = append(names, typeString(typ(fsm.states[st.ShiftStatus()])))
+	}
+	return strings.Join(names, ", ")
+}
+
+// typeString returns the name of the type, ex. "pkg.update" or "<nil>".
+func typeString(t reflect.Type) string {
+	if t == nil {
+		return "<nil>"
+	}
+	return t.String()
+}
+
+func sortStatuses(sl []Status) {
+	slices.SortFunc(sl, func(a, b Status) int {
+		return cmp.Compare(a.ShiftStatus(), b.ShiftStatus())
+	})
+}
+
+func sortTransitions(sl []Transition) {
+	slices.SortFunc(sl, func(a, b Transition) int {
+		if c := cmp.Compare(a.From.ShiftStatus(), b.From.ShiftStatus()); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.To.ShiftStatus(), b.To.ShiftStatus())
+	})
+}
+
+func deleteTx[T primary](ctx context.Context, tx *sql.Tx, from Status, deleter Deleter[T],
+	events EventInserter[T], eventType reflex.EventType, opts options,
+) (rsql.NotifyFunc, error) {
+	if opts.withValidation && opts.validationBefore {
+		if err := validateDelete(ctx, tx, deleter, from); err != nil {
 			return nil, err
 		}
 	}
 
-	notify, err := events.InsertWithMetadata(ctx, tx, id, eventType, metadata)
+	id, err := deleter.Delete(ctx, tx, from)
 	if err != nil {
 		return nil, err
 	}
+	opts.setID(ctx, id)
 
-	if opts.withValidation {
-		validate, ok := updater.(ValidatingUpdater[T])
-		if !ok {
-			return nil, errors.Wrap(ErrInvalidType, "updater without validate method")
+	notify := func() {}
+	if eventType != nil {
+		var metadata []byte
+		if typed, ok := opts.typedMetadata.(typedMetadata[T]); ok {
+			meta, err := typed.delete(ctx, tx, deleter, from)
+			if err != nil {
+				return nil, err
+			}
+			metadata, err = opts.marshalMetadata(meta)
+			if err != nil {
+				return nil, err
+			}
+		} else if opts.withMetadata || opts.metadataFunc != nil {
+			if meta, ok := deleter.(MetadataDeleter[T]); ok {
+				metadata, err = meta.GetMetadata(ctx, tx, from)
+			} else if opts.metadataFunc != nil {
+				metadata, err = opts.metadataFunc(ctx, id, from, nil)
+			} else {
+				return nil, errors.Wrap(ErrInvalidType, "deleter without metadata")
+			}
+			if err != nil {
+				return nil, err
+			}
 		}
 
-		err = validate.Validate(ctx, tx, from, to)
+		notify, err = eventsFor(opts, id, events).InsertWithMetadata(ctx, eventsDBC(ctx, tx), id, eventType, metadata)
 		if err != nil {
 			return nil, err
 		}
+		notify = wrapNotify(opts, notify)
+	}
+
+	if opts.withValidation && !opts.validationBefore {
+		if err := validateDelete(ctx, tx, deleter, from); err != nil {
+			return nil, err
+		}
 	}
 
 	return notify, nil
 }
 
+// validateDelete validates the delete with the deleter's Validate method.
+func validateDelete[T primary](ctx context.Context, tx *sql.Tx, deleter Deleter[T], from Status) error {
+	validate, ok := deleter.(ValidatingDeleter[T])
+	if !ok {
+		return errors.Wrap(ErrInvalidType, "deleter without validate method")
+	}
+
+	err := validate.Validate(ctx, tx, from)
+	if err != nil {
+		setResult(ctx, resultValidation)
+		return err
+	}
+	return nil
+}
+
+// eventsFor returns the events table for the entity id. This is the default
+// events table unless an event table sharder is configured.
+func eventsFor[T primary](opts options, id T, events EventInserter[T]) EventInserter[T] {
+	shard, ok := opts.eventSharder.(func(T) EventInserter[T])
+	if !ok {
+		return events
+	}
+	if e := shard(id); e != nil {
+		return e
+	}
+	return events
+}
+
+// wrapNotify returns notify wrapped by the configured notifier, if any.
+func wrapNotify(opts options, notify rsql.NotifyFunc) rsql.NotifyFunc {
+	if opts.notifier == nil || notify == nil {
+		return notify
+	}
+	return func() {
+		opts.notifier(notify)
+	}
+}
+
 type status struct {
-	st     Status
-	t      reflex.EventType
-	req    interface{}
+	st  Status
+	t   reflex.EventType
+	req interface{}
+	// typ is the cached type of req, compared against on every transition.
+	typ    reflect.Type
 	insert bool
-	next   map[Status]bool
+	next   map[Status]transition
+	delete *deletion
+}
+
+// nextStatuses returns the statuses the status can transition to ordered by
+// ShiftStatus.
+func (s status) nextStatuses() []Status {
+	res := make([]Status, 0, len(s.next))
+	for next := range s.next {
+		res = append(res, next)
+	}
+	sortStatuses(res)
+	return res
+}
+
+// deletion holds the deleter type allowed to delete entities in a status
+// and the type of the event inserted, if any.
+type deletion struct {
+	req interface{}
+	typ reflect.Type
+	t   reflex.EventType
+}
+
+// transition holds the properties of a transition to a next status.
+type transition struct {
+	category string
+}
+
+// categorised is a next status with a transition category.
+type categorised struct {
+	Status
+	category string
+}
+
+// Category returns the next status with a category for the transition to it,
+// ex. "user" or "system". Categories are for reporting, they don't affect the
+// FSM behaviour, and are written as event metadata if WithCategoryMetadata is set.
+//
+//	Update(PENDING, pending{}, shift.Category(COMPLETED, "system"), FAILED)
+func Category(st Status, category string) Status {
+	return categorised{Status: st, category: category}
 }
 
 func sameType(a interface{}, b interface{}) bool {
 	return reflect.TypeOf(a) == reflect.TypeOf(b)
 }
+
+// cachedType returns true if req is of the type cached at build time. It
+// avoids reflecting on the registered request on every transition.
+func cachedType(typ reflect.Type, req interface{}) bool {
+	return typ == reflect.TypeOf(req)
+}
+
+// sameElemType returns true if b is a slice of a's type.
+func sameElemType(a interface{}, b interface{}) bool {
+	t := reflect.TypeOf(b)
+	return t != nil && t.Kind() == reflect.Slice && t.Elem() == reflect.TypeOf(a)
+}
@@ -1,8 +1,15 @@
 package shift
 
 import (
+	"context"
+	"database/sql"
+	"math/rand"
+	"reflect"
 	"testing"
+	"time"
 
+	"github.com/luno/jettison/j"
+	"github.com/luno/jettison/jtest"
 	"github.com/stretchr/testify/require"
 )
 
@@ -52,3 +59,277 @@ func Test(t *testing.T) {
 		})
 	}
 }
+
+func TestRandVal_Pointer(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+	var nils, vals int
+	for i := 0; i < 100; i++ {
+		v := randVal(r, nil, reflect.TypeOf((*string)(nil)))
+		require.Equal(t, reflect.TypeOf((*string)(nil)), v.Type())
+		if v.IsNil() {
+			nils++
+		} else {
+			require.NotEmpty(t, *v.Interface().(*string))
+			vals++
+		}
+	}
+	require.NotZero(t, nils)
+	require.NotZero(t, vals)
+}
+
+func TestRandVal_Null(t *testing.T) {
+	types := []reflect.Type{
+		reflect.TypeOf(sql.NullTime{}),
+		reflect.TypeOf(sql.NullString{}),
+		reflect.TypeOf(sql.NullInt64{}),
+		reflect.TypeOf(sql.NullInt32{}),
+		reflect.TypeOf(sql.NullFloat64{}),
+		reflect.TypeOf(sql.NullBool{}),
+	}
+
+	r := rand.New(rand.NewSource(0))
+	for _, typ := range types {
+		var valid bool
+		for i := 0; i < 100; i++ {
+			v := randVal(r, nil, typ)
+			require.Equal(t, typ, v.Type())
+			valid = valid || v.FieldByName("Valid").Bool()
+		}
+		require.True(t, valid, typ.String())
+	}
+}
+
+func TestRandVal_Duration(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < 100; i++ {
+		v := randVal(r, nil, reflect.TypeOf(time.Duration(0)))
+		require.Positive(t, v.Interface().(time.Duration))
+	}
+}
+
+func TestRandVal_Seed(t *testing.T) {
+	val := func(seed int64) any {
+		return randVal(rand.New(rand.NewSource(seed)), nil, reflect.TypeOf("")).Interface()
+	}
+	require.Equal(t, val(42), val(42))
+	require.NotEqual(t, val(42), val(43))
+}
+
+func TestRandVal_Generator(t *testing.T) {
+	type amount struct {
+		Cents int64
+	}
+
+	var o testOptions
+	WithGenerator(reflect.TypeOf(amount{}), func(r *rand.Rand) reflect.Value {
+		return reflect.ValueOf(amount{Cents: r.Int63n(100) + 1})
+	})(&o)
+
+	r := rand.New(rand.NewSource(0))
+	v := randVal(r, o.gens, reflect.TypeOf(amount{}))
+	require.NotZero(t, v.Interface().(amount).Cents)
+
+	p := randVal(r, o.gens, reflect.TypeOf(&amount{}))
+	for p.IsNil() {
+		p = randVal(r, o.gens, reflect.TypeOf(&amount{}))
+	}
+	require.NotZero(t, p.Interface().(*amount).Cents)
+}
+
+func BenchmarkSameType(b *testing.B) {
+	s := status{req: x{}, typ: reflect.TypeOf(x{})}
+	var req interface{} = x{i: 1}
+
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if !sameType(s.req, req) {
+				b.Fatal("expected same type")
+			}
+		}
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if !cachedType(s.typ, req) {
+				b.Fatal("expected same type")
+			}
+		}
+	})
+}
+
+type namedStatus int
+
+func (s namedStatus) ShiftStatus() int { return int(s) }
+func (s namedStatus) ReflexType() int  { return int(s) }
+
+func (s namedStatus) String() string {
+	return map[namedStatus]string{1: "Pending", 2: "Completed", 3: "Failed"}[s]
+}
+
+func TestStatusStringInErrors(t *testing.T) {
+	var updated bool
+	upd := validatingUpdate{updated: &updated}
+
+	cases := []struct {
+		name     string
+		statuses []Status
+		expFrom  string
+		expTo    string
+	}{
+		{
+			name:     "stringer",
+			statuses: []Status{namedStatus(1), namedStatus(2), namedStatus(3)},
+			expFrom:  "Failed",
+			expTo:    "Completed",
+		},
+		{
+			name:     "not stringer",
+			statuses: []Status{testStatus(1), testStatus(2), testStatus(3)},
+			expFrom:  "3",
+			expTo:    "2",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s1, s2, s3 := c.statuses[0], c.statuses[1], c.statuses[2]
+
+			fsm := NewFSM(new(fakeEvents)).
+				Insert(s1, validatingInsert{}, s2).
+				Update(s2, upd, s3).
+				Update(s3, upd).
+				Build()
+
+			_, err := fsm.UpdateTx(context.Background(), nil, s3, s2, upd)
+			jtest.Require(t, ErrInvalidStateTransition, err)
+			jtest.AssertKeyValues(t, j.MKS{"from": c.expFrom, "to": c.expTo, "valid": "[]"}, err)
+
+			arc := NewArcFSM(new(fakeEvents)).
+				Insert(s1, validatingInsert{}).
+				Update(s1, s2, upd).
+				Update(s2, s3, upd).
+				Build()
+
+			_, err = arc.UpdateTx(context.Background(), nil, s3, s2, upd)
+			jtest.Require(t, ErrInvalidStateTransition, err)
+			jtest.AssertKeyValues(t, j.MKS{"from": c.expFrom, "to": c.expTo}, err)
+		})
+	}
+}
+
+func TestTimestampChecker_IDColumn(t *testing.T) {
+	ts := testOptions{}.timestampColumns()
+	cols := map[string]string{"user_id": "BIGINT", "status": "INT", "created_at": "DATETIME", "updated_at": "DATETIME"}
+
+	c, skip := timestampCheckerFor(nil, "accounts", options{}.idColumn(), "?", ts, cols)
+	require.Nil(t, c)
+	require.Equal(t, "id column id not in table accounts, provide it via shift.WithIDColumn", skip)
+
+	var o options
+	WithIDColumn("user_id")(&o)
+	c, skip = timestampCheckerFor(nil, "accounts", o.idColumn(), "?", ts, cols)
+	require.Empty(t, skip)
+	require.Equal(t, "select created_at, updated_at from accounts where user_id=?", c.query())
+
+	c, skip = timestampCheckerFor(nil, "accounts", "id", "?", ts, map[string]string{"id": "BIGINT", "status": "INT"})
+	require.Nil(t, c)
+	require.Empty(t, skip)
+}
+
+func TestTimestampChecker_Columns(t *testing.T) {
+	var o testOptions
+	WithTimestampColumns("inserted_at", "modified_at")(&o)
+	cols := map[string]string{"id": "BIGINT", "inserted_at": "BIGINT", "modified_at": "DATETIME", "created_at": "DATETIME"}
+
+	c, skip := timestampCheckerFor(nil, "accounts", "id", "?", o.timestampColumns(), cols)
+	require.Empty(t, skip)
+	require.Equal(t, "select inserted_at, modified_at from accounts where id=?", c.query())
+	require.Equal(t, map[string]bool{"inserted_at": true, "modified_at": false}, c.unix)
+}
+
+func TestTimestampChecker_Dialect(t *testing.T) {
+	var o testOptions
+	WithDialect("postgres")(&o)
+	ph, err := o.placeholder()
+	jtest.RequireNil(t, err)
+
+	cols := map[string]string{"id": "INT8", "updated_at": "TIMESTAMPTZ"}
+	c, skip := timestampCheckerFor(nil, "accounts", "id", ph, o.timestampColumns(), cols)
+	require.Empty(t, skip)
+	require.Equal(t, "select updated_at from accounts where id=$1", c.query())
+
+	WithDialect("oracle")(&o)
+	_, err = o.placeholder()
+	require.Error(t, err)
+}
+
+func TestHasColumn(t *testing.T) {
+	type tagged struct {
+		ID       int64
+		Modified time.Time `shift:"modified_at"`
+	}
+	type named struct {
+		ID         int64
+		ModifiedAt time.Time
+	}
+	type other struct {
+		ID        int64
+		UpdatedAt time.Time `shift:"deleted_at"`
+	}
+
+	require.True(t, hasColumn(tagged{}, "modified_at"))
+	require.True(t, hasColumn(&named{}, "modified_at"))
+	require.False(t, hasColumn(named{}, "updated_at"))
+	require.False(t, hasColumn(other{}, "updated_at"))
+}
+
+func TestTimestamp_Unix(t *testing.T) {
+	ts := timestamp{unix: true}
+	require.True(t, ts.time().IsZero())
+
+	require.NoError(t, ts.dest().(*sql.NullInt64).Scan(int64(1700000000)))
+	require.Equal(t, time.Unix(1700000000, 0), ts.time())
+
+	ts = timestamp{}
+	now := time.Now()
+	require.NoError(t, ts.dest().(*sql.NullTime).Scan(now))
+	require.Equal(t, now, ts.time())
+}
+
+type taggedUpdate struct {
+	Amount int64
+	UserID int64 `shift:"user_id,primary"`
+}
+
+func (taggedUpdate) Update(context.Context, *sql.Tx, Status, Status) (int64, error) { return 1, nil }
+
+type renamedUpdate struct {
+	Amount int64
+	UserID string `shift:",primary"`
+}
+
+func (renamedUpdate) Update(context.Context, *sql.Tx, Status, Status) (string, error) { return "", nil }
+
+type mismatchUpdate struct {
+	ID string
+}
+
+func (mismatchUpdate) Update(context.Context, *sql.Tx, Status, Status) (int64, error) { return 1, nil }
+
+func TestRandomUpdate_PrimaryField(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+
+	u, err := randomUpdate[int64](r, nil, taggedUpdate{}, 42)
+	jtest.RequireNil(t, err)
+	require.Equal(t, int64(42), u.(taggedUpdate).UserID)
+
+	s, err := randomUpdate[string](r, nil, renamedUpdate{}, "user")
+	jtest.RequireNil(t, err)
+	require.Equal(t, "user", s.(renamedUpdate).UserID)
+
+	_, err = randomUpdate[int64](r, nil, mismatchUpdate{}, 42)
+	require.ErrorContains(t, err, "primary field type mismatch")
+
+	// Fields of the id type aren't assumed to be the primary field.
+	_, err = primaryField(reflect.TypeOf(struct{ Count int64 }{}), reflect.TypeOf(int64(0)))
+	require.ErrorContains(t, err, "primary field not found")
+}
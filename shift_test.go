@@ -17,13 +17,20 @@ import (
 	"github.com/luno/shift"
 )
 
-//go:generate go run github.com/luno/shift/shiftgen -inserter=insert -updaters=update,complete -table=users -out=gen_1_test.go
+//go:generate go run github.com/luno/shift/shiftgen -inserters=insert,seed -batch_inserters=seed -updaters=update,complete -force_updaters=complete -deleters=remove -table=users -classify_row_count -out=gen_1_test.go
 
 type insert struct {
 	Name        string
 	DateOfBirth time.Time `shift:"dob"` // Override column name.
 }
 
+// seed is an inserter with an explicit id, as required by batch inserters.
+type seed struct {
+	ID          int64
+	Name        string
+	DateOfBirth time.Time `shift:"dob"`
+}
+
 type update struct {
 	ID     int64
 	Name   string
@@ -34,6 +41,10 @@ type complete struct {
 	ID int64
 }
 
+type remove struct {
+	ID int64
+}
+
 type TestStatus int
 
 func (s TestStatus) ShiftStatus() int {
@@ -79,23 +90,23 @@ func TestBasic(t *testing.T) {
 	jtest.RequireNil(t, err)
 	require.Equal(t, int64(1), id)
 
-	assertUser(t, dbc, events.ToStream(dbc), usersTable, id, "insertMe", t0, Currency{}, 1)
+	assertUser(t, dbc, events, usersTable, id, "insertMe", t0, Currency{}, 1)
 
 	// Update model
 	err = fsm.Update(ctx, dbc, StatusInit, StatusUpdate, update{ID: id, Name: "updateMe", Amount: amount})
 	jtest.RequireNil(t, err)
 
-	assertUser(t, dbc, events.ToStream(dbc), usersTable, id, "updateMe", t0, amount, 1, 2)
+	assertUser(t, dbc, events, usersTable, id, "updateMe", t0, amount, 1, 2)
 
 	// Complete model
 	err = fsm.Update(ctx, dbc, StatusUpdate, StatusComplete, complete{ID: id})
 	jtest.RequireNil(t, err)
 
-	assertUser(t, dbc, events.ToStream(dbc), usersTable, id, "updateMe", t0, amount, 1, 2, 3)
+	assertUser(t, dbc, events, usersTable, id, "updateMe", t0, amount, 1, 2, 3)
 }
 
-func assertUser(t *testing.T, dbc *sql.DB, stream reflex.StreamFunc, table string,
-	id any, exName string, exDOB time.Time, exAmount Currency, exEvents ...TestStatus,
+func assertUser[T int64 | uint64 | string](t *testing.T, dbc *sql.DB, events shift.EventStreamer, table string,
+	id T, exName string, exDOB time.Time, exAmount Currency, exEvents ...TestStatus,
 ) {
 	var name sql.NullString
 	var amount Currency
@@ -107,15 +118,11 @@ func assertUser(t *testing.T, dbc *sql.DB, stream reflex.StreamFunc, table strin
 	require.Equal(t, exDOB.UTC(), dob.UTC())
 	require.Equal(t, exAmount, amount)
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	sc, err := stream(ctx, "")
-	jtest.RequireNil(t, err)
+	var exStatuses []shift.Status
 	for _, exE := range exEvents {
-		e, err := sc.Recv()
-		jtest.RequireNil(t, err)
-		require.Equal(t, int(exE), e.Type.ReflexType())
+		exStatuses = append(exStatuses, exE)
 	}
+	shift.AssertTransitions(t, dbc, events, id, exStatuses)
 }
 
 //go:generate go run github.com/luno/shift/shiftgen -inserter=insertStr -updaters=updateStr,completeStr -table=usersStr -out=gen_string_test.go
@@ -159,19 +166,115 @@ func TestBasic_StringFSM(t *testing.T) {
 	jtest.RequireNil(t, err)
 	require.Equal(t, "abcdef123456", id)
 
-	assertUser(t, dbc, eventsStr.ToStream(dbc), usersStrTable, id, "insertMe", t0, Currency{}, 1)
+	assertUser(t, dbc, eventsStr, usersStrTable, id, "insertMe", t0, Currency{}, 1)
 
 	// Update model
 	err = fsmStr.Update(ctx, dbc, StatusInit, StatusUpdate, updateStr{ID: id, Name: "updateMe", Amount: amount})
 	jtest.RequireNil(t, err)
 
-	assertUser(t, dbc, eventsStr.ToStream(dbc), usersStrTable, id, "updateMe", t0, amount, 1, 2)
+	assertUser(t, dbc, eventsStr, usersStrTable, id, "updateMe", t0, amount, 1, 2)
 
 	// Complete model
 	err = fsmStr.Update(ctx, dbc, StatusUpdate, StatusComplete, completeStr{ID: id})
 	jtest.RequireNil(t, err)
 
-	assertUser(t, dbc, eventsStr.ToStream(dbc), usersStrTable, id, "updateMe", t0, amount, 1, 2, 3)
+	assertUser(t, dbc, eventsStr, usersStrTable, id, "updateMe", t0, amount, 1, 2, 3)
+}
+
+//go:generate go run github.com/luno/shift/shiftgen -inserter=insertU64 -updaters=updateU64 -table=users -primary_type=uint64 -out=gen_uint64_test.go
+
+type insertU64 struct {
+	Name        string
+	DateOfBirth time.Time `shift:"dob"`
+}
+
+type updateU64 struct {
+	ID     uint64
+	Name   string
+	Amount Currency
+}
+
+// eventsU64 inserts events with uint64 foreign ids into an int events table.
+type eventsU64 struct {
+	*rsql.EventsTableInt
+}
+
+func (e eventsU64) InsertWithMetadata(ctx context.Context, dbc rsql.DBC, foreignID uint64,
+	typ reflex.EventType, metadata []byte,
+) (rsql.NotifyFunc, error) {
+	return e.EventsTableInt.InsertWithMetadata(ctx, dbc, int64(foreignID), typ, metadata)
+}
+
+func TestBasic_Uint64FSM(t *testing.T) {
+	dbc := setup(t)
+	ctx := context.Background()
+	t0 := time.Now().Truncate(time.Second)
+	amount := Currency{Valid: true, Amount: 99}
+
+	events := eventsU64{events}
+	fsm := shift.NewGenFSM[uint64](events).
+		Insert(StatusInit, insertU64{}, StatusUpdate).
+		Update(StatusUpdate, updateU64{}, StatusUpdate).
+		Build()
+
+	id, err := fsm.Insert(ctx, dbc, insertU64{Name: "insertMe", DateOfBirth: t0})
+	jtest.RequireNil(t, err)
+	require.Equal(t, uint64(1), id)
+
+	err = fsm.Update(ctx, dbc, StatusInit, StatusUpdate, updateU64{ID: id, Name: "updateMe", Amount: amount})
+	jtest.RequireNil(t, err)
+
+	assertUser(t, dbc, events, usersTable, id, "updateMe", t0, amount, 1, 2)
+
+	jtest.RequireNil(t, shift.TestGenFSM(t, dbc, fsm))
+}
+
+//go:generate go run github.com/luno/shift/shiftgen -updaters=rename -table=users -out=gen_version_test.go
+
+// rename is an updater with optimistic locking on the version column.
+type rename struct {
+	ID      int64
+	Name    string
+	Version int64 `shift:"version,version"`
+}
+
+func TestUpdate_VersionConflict(t *testing.T) {
+	dbc := setup(t)
+	ctx := context.Background()
+	t0 := time.Now().Truncate(time.Second)
+
+	fsm := shift.NewFSM(events).
+		Insert(StatusInit, insert{}, StatusUpdate).
+		Update(StatusUpdate, rename{}, StatusUpdate).
+		Build()
+
+	id, err := fsm.Insert(ctx, dbc, insert{Name: "insertMe", DateOfBirth: t0})
+	jtest.RequireNil(t, err)
+
+	// Two writers read version 0, the first one to update wins.
+	err = fsm.Update(ctx, dbc, StatusInit, StatusUpdate, rename{ID: id, Name: "first", Version: 0})
+	jtest.RequireNil(t, err)
+
+	err = fsm.Update(ctx, dbc, StatusUpdate, StatusUpdate, rename{ID: id, Name: "second", Version: 0})
+	jtest.Require(t, shift.ErrVersionConflict, err)
+	jtest.Require(t, shift.ErrRowCount, err)
+
+	assertUser(t, dbc, events, usersTable, id, "first", t0, Currency{}, 1, 2)
+
+	// Retrying with the current version succeeds.
+	err = fsm.Update(ctx, dbc, StatusUpdate, StatusUpdate, rename{ID: id, Name: "second", Version: 1})
+	jtest.RequireNil(t, err)
+
+	assertUser(t, dbc, events, usersTable, id, "second", t0, Currency{}, 1, 2, 2)
+
+	// Updates failing for other reasons aren't version conflicts.
+	err = fsm.Update(ctx, dbc, StatusInit, StatusUpdate, rename{ID: id, Name: "third", Version: 2})
+	jtest.Require(t, shift.ErrStatusMismatch, err)
+	require.False(t, errors.Is(err, shift.ErrVersionConflict))
+
+	err = fsm.Update(ctx, dbc, StatusUpdate, StatusUpdate, rename{ID: 99, Name: "third", Version: 2})
+	jtest.Require(t, shift.ErrRowNotFound, err)
+	require.False(t, errors.Is(err, shift.ErrVersionConflict))
 }
 
 func (ii i) Validate(ctx context.Context, tx *sql.Tx, id int64, status shift.Status) error {
@@ -222,6 +325,43 @@ func TestWithValidation(t *testing.T) {
 	jtest.Require(t, errUpdateInvalid, err)
 }
 
+func TestWithSavepoints(t *testing.T) {
+	dbc := setup(t)
+	defer dbc.Close()
+
+	fsm := shift.NewFSM(events, shift.WithValidation(), shift.WithSavepoints()).
+		Insert(s(1), i{}, s(2)).
+		Update(s(2), u{}, s(2)).
+		Build()
+
+	ctx := context.Background()
+
+	tx, err := dbc.Begin()
+	jtest.RequireNil(t, err)
+	defer tx.Rollback()
+
+	id, _, err := fsm.InsertTx(ctx, tx, i{I3: time.Now()})
+	jtest.RequireNil(t, err)
+
+	// Second insert fails validation, only it is rolled back.
+	_, _, err = fsm.InsertTx(ctx, tx, i{I3: time.Now()})
+	jtest.Require(t, errInsertInvalid, err)
+
+	_, err = fsm.UpdateTx(ctx, tx, s(1), s(2), u{ID: id})
+	jtest.RequireNil(t, err)
+
+	jtest.RequireNil(t, tx.Commit())
+
+	var n int
+	err = dbc.QueryRow("select count(*) from tests").Scan(&n)
+	jtest.RequireNil(t, err)
+	require.Equal(t, 1, n)
+
+	err = dbc.QueryRow("select count(*) from events").Scan(&n)
+	jtest.RequireNil(t, err)
+	require.Equal(t, 2, n)
+}
+
 //go:generate go run github.com/luno/shift/shiftgen -inserter=i_t -updaters=u_t -table=tests -out=gen_3_test.go
 
 type i_t struct {
@@ -287,7 +427,7 @@ func TestGenFSM_Update(t *testing.T) {
 	jtest.RequireNil(t, err)
 	require.Equal(t, int64(1), id)
 
-	assertUser(t, dbc, events.ToStream(dbc), usersTable, id, "insertMe", t0, Currency{}, 1)
+	assertUser(t, dbc, events, usersTable, id, "insertMe", t0, Currency{}, 1)
 
 	var unknownShiftStatus TestStatus = 999
 	tests := []struct {
@@ -307,14 +447,26 @@ func TestGenFSM_Update(t *testing.T) {
 			from:   StatusComplete,
 			to:     StatusUpdate,
 			expErr: shift.ErrInvalidStateTransition,
-			expKVs: j.MKS{"from": fmt.Sprintf("%v", StatusComplete), "to": fmt.Sprintf("%v", StatusUpdate)},
+			expKVs: j.MKS{"from": fmt.Sprintf("%v", StatusComplete), "to": fmt.Sprintf("%v", StatusUpdate), "valid": "[]"},
+		},
+		{
+			name:   "Already in state lists valid",
+			from:   StatusUpdate,
+			to:     StatusUpdate,
+			expErr: shift.ErrAlreadyInState,
+			expKVs: j.MKS{"from": fmt.Sprintf("%v", StatusUpdate), "to": fmt.Sprintf("%v", StatusUpdate), "valid": fmt.Sprintf("%v", []shift.Status{StatusComplete})},
 		},
 		{
 			name:   "Invalid Type",
 			from:   StatusInit,
 			to:     StatusComplete,
 			expErr: shift.ErrInvalidType,
-			expKVs: j.MKS{"from": fmt.Sprintf("%v", StatusInit), "to": fmt.Sprintf("%v", StatusComplete)},
+			expKVs: j.MKS{
+				"from":     fmt.Sprintf("%v", StatusInit),
+				"to":       fmt.Sprintf("%v", StatusComplete),
+				"expected": "shift_test.complete",
+				"provided": "shift_test.update",
+			},
 		},
 		{
 			name:   "Unknown 'from' status",
@@ -339,3 +491,688 @@ func TestGenFSM_Update(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdate_ClassifyRowCount(t *testing.T) {
+	dbc := setup(t)
+	ctx := context.Background()
+
+	id, err := fsm.Insert(ctx, dbc, insert{Name: "insertMe"})
+	jtest.RequireNil(t, err)
+
+	err = fsm.Update(ctx, dbc, StatusInit, StatusUpdate, update{ID: id + 1})
+	jtest.Require(t, shift.ErrRowNotFound, err)
+	jtest.Require(t, shift.ErrRowCount, err)
+
+	err = fsm.Update(ctx, dbc, StatusUpdate, StatusComplete, complete{ID: id})
+	jtest.Require(t, shift.ErrStatusMismatch, err)
+	jtest.Require(t, shift.ErrRowCount, err)
+	jtest.AssertKeyValues(t, j.MKS{"status": fmt.Sprint(StatusInit.ShiftStatus())}, err)
+}
+
+func TestDelete(t *testing.T) {
+	dbc := setup(t)
+	ctx := context.Background()
+	t0 := time.Now().Truncate(time.Second)
+	statusDeleted := TestStatus(4)
+
+	fsm := shift.NewFSM(events).
+		Insert(StatusInit, insert{}, StatusUpdate).
+		Update(StatusUpdate, update{}, StatusComplete).
+		Update(StatusComplete, complete{}).
+		Delete(StatusComplete, remove{}, statusDeleted).
+		Build()
+
+	id, err := fsm.Insert(ctx, dbc, insert{Name: "deleteMe", DateOfBirth: t0})
+	jtest.RequireNil(t, err)
+
+	err = fsm.Delete(ctx, dbc, StatusInit, remove{ID: id})
+	jtest.Require(t, shift.ErrInvalidStateTransition, err)
+
+	err = fsm.Update(ctx, dbc, StatusInit, StatusUpdate, update{ID: id})
+	jtest.RequireNil(t, err)
+	err = fsm.Update(ctx, dbc, StatusUpdate, StatusComplete, complete{ID: id})
+	jtest.RequireNil(t, err)
+
+	err = fsm.Delete(ctx, dbc, StatusComplete, remove{ID: id})
+	jtest.RequireNil(t, err)
+
+	var n int
+	err = dbc.QueryRow("select count(*) from users where id=?", id).Scan(&n)
+	jtest.RequireNil(t, err)
+	require.Zero(t, n)
+
+	shift.AssertTransitions(t, dbc, events, id, []shift.Status{StatusInit, StatusUpdate, StatusComplete, statusDeleted})
+}
+
+//go:generate go run github.com/luno/shift/shiftgen -updaters=touch -deleters=archive -table=users -soft_delete=deleted_at -out=gen_soft_delete_test.go
+
+type touch struct {
+	ID   int64
+	Name string
+}
+
+type archive struct {
+	ID int64
+}
+
+func TestSoftDelete(t *testing.T) {
+	dbc := setup(t)
+	ctx := context.Background()
+	t0 := time.Now().Truncate(time.Second)
+	statusDeleted := TestStatus(4)
+
+	fsm := shift.NewFSM(events).
+		Insert(StatusInit, insert{}, StatusUpdate).
+		Update(StatusUpdate, touch{}, StatusUpdate).
+		Delete(StatusUpdate, archive{}, statusDeleted).
+		Build()
+
+	id, err := fsm.Insert(ctx, dbc, insert{Name: "deleteMe", DateOfBirth: t0})
+	jtest.RequireNil(t, err)
+
+	err = fsm.Update(ctx, dbc, StatusInit, StatusUpdate, touch{ID: id, Name: "touched"})
+	jtest.RequireNil(t, err)
+
+	err = fsm.Delete(ctx, dbc, StatusUpdate, archive{ID: id})
+	jtest.RequireNil(t, err)
+
+	// The row is kept, but marked as deleted.
+	var deletedAt sql.NullTime
+	err = dbc.QueryRow("select deleted_at from users where id=?", id).Scan(&deletedAt)
+	jtest.RequireNil(t, err)
+	require.True(t, deletedAt.Valid)
+
+	// Soft deleted rows can't transition anymore.
+	err = fsm.Update(ctx, dbc, StatusUpdate, StatusUpdate, touch{ID: id, Name: "zombie"})
+	jtest.Require(t, shift.ErrRowCount, err)
+
+	err = fsm.Delete(ctx, dbc, StatusUpdate, archive{ID: id})
+	jtest.Require(t, shift.ErrRowCount, err)
+
+	assertUser(t, dbc, events, usersTable, id, "touched", t0, Currency{}, StatusInit, StatusUpdate, statusDeleted)
+}
+
+//go:generate go run github.com/luno/shift/shiftgen -inserter=insertOnce -table=usersStr -idempotent_insert -out=gen_idempotent_test.go
+
+// insertOnce is an inserter that is a no-op if the row already exists.
+type insertOnce struct {
+	ID          string
+	Name        string
+	DateOfBirth time.Time `shift:"dob"`
+}
+
+func TestInsert_Idempotent(t *testing.T) {
+	dbc := setup(t)
+	ctx := context.Background()
+	t0 := time.Now().Truncate(time.Second)
+
+	fsm := shift.NewGenFSM[string](eventsStr).
+		Insert(StatusInit, insertOnce{}).
+		Build()
+
+	id, err := fsm.Insert(ctx, dbc, insertOnce{ID: "abc", Name: "first", DateOfBirth: t0})
+	jtest.RequireNil(t, err)
+	require.Equal(t, "abc", id)
+
+	// Replaying the insert succeeds without changing the row.
+	id, err = fsm.Insert(ctx, dbc, insertOnce{ID: "abc", Name: "second", DateOfBirth: t0})
+	jtest.RequireNil(t, err)
+	require.Equal(t, "abc", id)
+
+	var name string
+	err = dbc.QueryRow("select name from usersStr where id=?", id).Scan(&name)
+	jtest.RequireNil(t, err)
+	require.Equal(t, "first", name)
+
+	// The replay doesn't insert another event.
+	shift.AssertTransitions(t, dbc, eventsStr, id, []shift.Status{StatusInit})
+
+	// Calling the generated method directly returns the replay error with the id.
+	tx, err := dbc.Begin()
+	jtest.RequireNil(t, err)
+	defer tx.Rollback()
+
+	id, err = insertOnce{ID: "abc", Name: "third", DateOfBirth: t0}.Insert(ctx, tx, StatusInit)
+	jtest.Require(t, shift.ErrAlreadyInserted, err)
+	require.True(t, errors.Is(err, shift.ErrDuplicate))
+	require.Equal(t, "abc", id)
+}
+
+func TestBuildE_DeleteUnknownStatus(t *testing.T) {
+	_, err := shift.NewFSM(events).
+		Insert(StatusInit, insert{}, StatusUpdate).
+		Delete(StatusUpdate, remove{}, nil).
+		Update(StatusUpdate, update{}).
+		BuildE()
+	jtest.Require(t, shift.ErrInvalidFSM, err)
+}
+
+func TestInsertBatch(t *testing.T) {
+	dbc := setup(t)
+
+	fsm := shift.NewFSM(events).
+		Insert(StatusInit, seed{}, StatusUpdate).
+		Update(StatusUpdate, update{}).
+		Build()
+
+	t0 := time.Now().Truncate(time.Second)
+	ctx := context.Background()
+
+	var batch seedBatch
+	for i := 0; i < 100; i++ {
+		batch = append(batch, seed{ID: int64(i + 1), Name: fmt.Sprintf("insert%d", i), DateOfBirth: t0})
+	}
+
+	ids, err := fsm.InsertBatch(ctx, dbc, batch)
+	jtest.RequireNil(t, err)
+	require.Len(t, ids, 100)
+
+	for i, id := range ids {
+		require.Equal(t, int64(i+1), id)
+		assertUser(t, dbc, events, usersTable, id, fmt.Sprintf("insert%d", i), t0, Currency{}, StatusInit)
+	}
+
+	var n int
+	err = dbc.QueryRow("select count(*) from events").Scan(&n)
+	jtest.RequireNil(t, err)
+	require.Equal(t, 100, n)
+}
+
+func TestInsertBatch_InvalidType(t *testing.T) {
+	dbc := setup(t)
+
+	_, err := fsm.InsertBatch(context.Background(), dbc, completeBatch{})
+	jtest.Require(t, shift.ErrInvalidType, err)
+}
+
+// completeBatch is a batch of a type other than the insert type.
+type completeBatch []complete
+
+func (completeBatch) InsertBatch(context.Context, *sql.Tx, shift.Status) ([]int64, error) {
+	return nil, nil
+}
+
+func TestUpdateMany(t *testing.T) {
+	dbc := setup(t)
+
+	fsm := shift.NewFSM(events, shift.WithTable(usersTable)).
+		Insert(StatusInit, insert{}, StatusUpdate).
+		Update(StatusUpdate, update{}, StatusComplete).
+		Update(StatusComplete, complete{}).
+		Build()
+
+	t0 := time.Now().Truncate(time.Second)
+	ctx := context.Background()
+
+	var ids []int64
+	for i := 0; i < 3; i++ {
+		id, err := fsm.Insert(ctx, dbc, insert{Name: "insertMe", DateOfBirth: t0})
+		jtest.RequireNil(t, err)
+		ids = append(ids, id)
+	}
+
+	err := fsm.Update(ctx, dbc, StatusInit, StatusUpdate, update{ID: ids[1], Name: "insertMe"})
+	jtest.RequireNil(t, err)
+
+	n, skipped, err := fsm.UpdateMany(ctx, dbc, StatusInit, StatusUpdate, append(ids, 99))
+	jtest.RequireNil(t, err)
+	require.Equal(t, 2, n)
+	require.Equal(t, []int64{ids[1], 99}, skipped)
+
+	for _, id := range ids {
+		assertUser(t, dbc, events, usersTable, id, "insertMe", t0, Currency{}, StatusInit, StatusUpdate)
+	}
+
+	_, _, err = fsm.UpdateMany(ctx, dbc, StatusInit, StatusComplete, ids)
+	jtest.Require(t, shift.ErrInvalidStateTransition, err)
+}
+
+func TestUpdateMany_Large(t *testing.T) {
+	dbc := setup(t)
+
+	fsm := shift.NewFSM(events, shift.WithTable(usersTable)).
+		Insert(StatusInit, seed{}, StatusUpdate).
+		Update(StatusUpdate, update{}).
+		Build()
+
+	ctx := context.Background()
+	t0 := time.Now().Truncate(time.Second)
+
+	// More ids than fit in a single query chunk.
+	var batch seedBatch
+	for i := 0; i < 2500; i++ {
+		batch = append(batch, seed{ID: int64(i + 1), Name: "insertMe", DateOfBirth: t0})
+	}
+	ids, err := fsm.InsertBatch(ctx, dbc, batch)
+	jtest.RequireNil(t, err)
+
+	n, skipped, err := fsm.UpdateMany(ctx, dbc, StatusInit, StatusUpdate, append(ids, 9999))
+	jtest.RequireNil(t, err)
+	require.Equal(t, 2500, n)
+	require.Equal(t, []int64{9999}, skipped)
+
+	var count int
+	err = dbc.QueryRow("select count(*) from users where status=?", StatusUpdate).Scan(&count)
+	jtest.RequireNil(t, err)
+	require.Equal(t, 2500, count)
+}
+
+func TestWithEventTableSharder(t *testing.T) {
+	dbc := setup(t)
+
+	eventsShard := rsql.NewEventsTableInt("eventsShard", rsql.WithoutEventsCache())
+	sharder := func(id int64) shift.EventInserter[int64] {
+		if id%2 == 0 {
+			return eventsShard
+		}
+		return events
+	}
+
+	fsm := shift.NewFSM(events, shift.WithEventTableSharder(sharder)).
+		Insert(StatusInit, insert{}, StatusUpdate).
+		Update(StatusUpdate, update{}, StatusComplete).
+		Update(StatusComplete, complete{}).
+		Build()
+
+	t0 := time.Now().Truncate(time.Second)
+	ctx := context.Background()
+
+	id1, err := fsm.Insert(ctx, dbc, insert{Name: "odd", DateOfBirth: t0})
+	jtest.RequireNil(t, err)
+	require.Equal(t, int64(1), id1)
+
+	id2, err := fsm.Insert(ctx, dbc, insert{Name: "even", DateOfBirth: t0})
+	jtest.RequireNil(t, err)
+	require.Equal(t, int64(2), id2)
+
+	err = fsm.Update(ctx, dbc, StatusInit, StatusUpdate, update{ID: id2, Name: "even"})
+	jtest.RequireNil(t, err)
+
+	assertUser(t, dbc, events, usersTable, id1, "odd", t0, Currency{}, 1)
+	assertUser(t, dbc, eventsShard, usersTable, id2, "even", t0, Currency{}, 1, 2)
+}
+
+func TestWithEventTableSharder_TypeMismatch(t *testing.T) {
+	sharder := shift.WithEventTableSharder(func(id string) shift.EventInserter[string] {
+		return eventsStr
+	})
+	_, err := shift.NewFSM(events, sharder).
+		Insert(StatusInit, insert{}).
+		BuildE()
+	jtest.Require(t, shift.ErrInvalidFSM, err)
+}
+
+type otherStatus int
+
+func (s otherStatus) ShiftStatus() int {
+	return int(s)
+}
+
+func (s otherStatus) ReflexType() int {
+	return int(s) + 100
+}
+
+func TestWithTxOptions(t *testing.T) {
+	dbc := setup(t)
+	ctx := context.Background()
+
+	fsm := shift.NewFSM(events, shift.WithTxOptions(&sql.TxOptions{ReadOnly: true})).
+		Insert(StatusInit, insert{}, StatusUpdate).
+		Update(StatusUpdate, update{}, StatusComplete).
+		Update(StatusComplete, complete{}).
+		Build()
+
+	_, err := fsm.Insert(ctx, dbc, insert{Name: "readonly"})
+	require.Error(t, err)
+
+	var n int
+	err = dbc.QueryRow("select count(*) from users").Scan(&n)
+	jtest.RequireNil(t, err)
+	require.Zero(t, n)
+}
+
+func TestConn(t *testing.T) {
+	dbc := setup(t)
+	ctx := context.Background()
+
+	t0 := time.Now().Truncate(time.Second)
+
+	conn, err := dbc.Conn(ctx)
+	jtest.RequireNil(t, err)
+	defer conn.Close()
+
+	id, err := fsm.Insert(ctx, conn, insert{Name: "pinned", DateOfBirth: t0})
+	jtest.RequireNil(t, err)
+
+	err = fsm.Update(ctx, conn, StatusInit, StatusUpdate, update{ID: id, Name: "pinned"})
+	jtest.RequireNil(t, err)
+
+	assertUser(t, dbc, events, usersTable, id, "pinned", t0, Currency{}, 1, 2)
+}
+
+func TestForceUpdate(t *testing.T) {
+	dbc := setup(t)
+	ctx := context.Background()
+	t0 := time.Now().Truncate(time.Second)
+
+	id, err := fsm.Insert(ctx, dbc, insert{Name: "forced", DateOfBirth: t0})
+	jtest.RequireNil(t, err)
+
+	// Init to complete isn't a valid transition.
+	err = fsm.Update(ctx, dbc, StatusInit, StatusComplete, complete{ID: id})
+	jtest.Require(t, shift.ErrInvalidStateTransition, err)
+
+	err = fsm.ForceUpdate(ctx, dbc, StatusComplete, complete{ID: id})
+	jtest.RequireNil(t, err)
+
+	assertUser(t, dbc, events, usersTable, id, "forced", t0, Currency{}, 1, 3)
+
+	err = fsm.ForceUpdate(ctx, dbc, StatusComplete, complete{ID: id + 1})
+	jtest.Require(t, sql.ErrNoRows, err)
+}
+
+func TestInsertTx_InvalidType(t *testing.T) {
+	_, _, err := fsm.InsertTx(context.Background(), nil, insert2{})
+	jtest.Require(t, shift.ErrInvalidType, err)
+	jtest.AssertKeyValues(t, j.MKS{
+		"expected": "shift_test.insert",
+		"provided": "shift_test.insert2",
+	}, err)
+}
+
+func TestUpdateTx_AlreadyInState(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := fsm.UpdateTx(ctx, nil, StatusUpdate, StatusUpdate, update{ID: 1})
+	jtest.Require(t, shift.ErrAlreadyInState, err)
+	jtest.Require(t, shift.ErrInvalidStateTransition, err)
+
+	_, err = fsm.UpdateTx(ctx, nil, StatusComplete, StatusUpdate, update{ID: 1})
+	jtest.Require(t, shift.ErrInvalidStateTransition, err)
+	require.False(t, errors.Is(err, shift.ErrAlreadyInState))
+}
+
+func TestForceUpdate_InvalidType(t *testing.T) {
+	_, err := fsm.ForceUpdateTx(context.Background(), nil, StatusUpdate, complete{ID: 1})
+	jtest.Require(t, shift.ErrInvalidType, err)
+	jtest.AssertKeyValues(t, j.MKS{
+		"to":       fmt.Sprintf("%v", StatusUpdate),
+		"expected": "shift_test.update",
+		"provided": "shift_test.complete",
+	}, err)
+
+	_, err = fsm.ForceUpdateTx(context.Background(), nil, TestStatus(99), complete{ID: 1})
+	jtest.Require(t, shift.ErrUnknownStatus, err)
+}
+
+func TestWithEventsDB(t *testing.T) {
+	dbc := setup(t)
+	edb := setup(t) // Separate connection with its own temporary tables.
+	ctx := context.Background()
+
+	fsm := shift.NewFSM(events, shift.WithEventsDB(edb)).
+		Insert(StatusInit, insert{}, StatusUpdate).
+		Update(StatusUpdate, update{}, StatusComplete).
+		Update(StatusComplete, complete{}).
+		Build()
+
+	id, err := fsm.Insert(ctx, dbc, insert{Name: "events", DateOfBirth: time.Now()})
+	jtest.RequireNil(t, err)
+
+	err = fsm.Update(ctx, dbc, StatusInit, StatusUpdate, update{ID: id, Name: "events"})
+	jtest.RequireNil(t, err)
+
+	count := func(dbc *sql.DB, table string) int {
+		var n int
+		err := dbc.QueryRow("select count(*) from " + table).Scan(&n)
+		jtest.RequireNil(t, err)
+		return n
+	}
+	require.Equal(t, 1, count(dbc, "users"))
+	require.Zero(t, count(dbc, "events"))
+	shift.AssertTransitions(t, edb, events, id, []shift.Status{StatusInit, StatusUpdate})
+
+	// Failing to insert the events rolls back the entity change.
+	_, err = edb.Exec("drop temporary table events")
+	jtest.RequireNil(t, err)
+
+	_, err = fsm.Insert(ctx, dbc, insert{Name: "no events", DateOfBirth: time.Now()})
+	require.Error(t, err)
+	require.False(t, errors.Is(err, shift.ErrEventsNotCommitted))
+	require.Equal(t, 1, count(dbc, "users"))
+}
+
+func TestBuild_StatusConflicts(t *testing.T) {
+	require.Panics(t, func() {
+		shift.NewFSM(events).
+			Insert(StatusInit, insert{}, otherStatus(StatusUpdate)).
+			Update(StatusUpdate, update{}).
+			Build()
+	})
+
+	require.Panics(t, func() {
+		shift.NewFSM(events, shift.WithUniqueEventTypes()).
+			Insert(StatusInit, insert{}, otherStatus(5)).
+			Update(otherStatus(5), update{}, TestStatus(105)).
+			Update(TestStatus(105), complete{}).
+			Build()
+	})
+
+	require.NotPanics(t, func() {
+		shift.NewFSM(events, shift.WithUniqueEventTypes()).
+			Insert(StatusInit, insert{}, otherStatus(5)).
+			Update(otherStatus(5), update{}, StatusUpdate).
+			Update(StatusUpdate, complete{}).
+			Build()
+	})
+}
+
+func TestBuild_DanglingTransition(t *testing.T) {
+	require.PanicsWithValue(t, "next statuses not registered(transitions=2->3): invalid fsm", func() {
+		shift.NewFSM(events).
+			Insert(StatusInit, insert{}, StatusUpdate).
+			Update(StatusUpdate, update{}, StatusComplete).
+			Build()
+	})
+}
+
+func TestBuild_UnreachableStatus(t *testing.T) {
+	require.PanicsWithValue(t, "statuses not reachable from insert statuses(statuses=[2 3]): invalid fsm", func() {
+		shift.NewFSM(events).
+			Insert(StatusInit, insert{}).
+			Update(StatusUpdate, update{}, StatusComplete).
+			Update(StatusComplete, complete{}).
+			Build()
+	})
+}
+
+func TestBuildE(t *testing.T) {
+	_, err := shift.NewFSM(events).
+		Insert(StatusInit, insert{}, StatusUpdate).
+		Update(StatusUpdate, update{}, StatusComplete).
+		Update(StatusUpdate, update{}).
+		Update(StatusComplete, complete{}).
+		BuildE()
+	jtest.Require(t, shift.ErrInvalidFSM, err)
+	require.Equal(t, "state already added: invalid fsm", err.Error())
+
+	_, err = shift.NewFSM(events).
+		Insert(StatusInit, insert{}, StatusUpdate).
+		Update(StatusUpdate, update{}, StatusComplete).
+		BuildE()
+	jtest.Require(t, shift.ErrInvalidFSM, err)
+
+	f, err := shift.NewFSM(events).
+		Insert(StatusInit, insert{}, StatusUpdate).
+		Update(StatusUpdate, update{}, StatusComplete).
+		Update(StatusComplete, complete{}).
+		BuildE()
+	jtest.RequireNil(t, err)
+	require.Equal(t, fsm.Transitions(), f.Transitions())
+}
+
+func TestMultipleInsertStatuses(t *testing.T) {
+	dbc := setup(t)
+	ctx := context.Background()
+	t0 := time.Now().Truncate(time.Second)
+	statusImported := TestStatus(4)
+
+	fsm := shift.NewFSM(events).
+		Insert(StatusInit, insert{}, StatusUpdate).
+		Insert(statusImported, insert2{}, StatusUpdate).
+		Update(StatusUpdate, update{}, StatusComplete).
+		Update(StatusComplete, complete{}).
+		Build()
+
+	require.Equal(t, []shift.Status{StatusInit, statusImported}, fsm.InsertStatuses())
+
+	id, err := fsm.Insert(ctx, dbc, insert2{Name: "imported", DateOfBirth: t0})
+	jtest.RequireNil(t, err)
+	err = fsm.Update(ctx, dbc, statusImported, StatusUpdate, update{ID: id, Name: "imported"})
+	jtest.RequireNil(t, err)
+
+	assertUser(t, dbc, events, usersTable, id, "imported", t0, Currency{}, statusImported, StatusUpdate)
+
+	jtest.RequireNil(t, shift.TestFSM(t, dbc, fsm))
+}
+
+func TestBuildE_Acyclic(t *testing.T) {
+	_, err := shift.NewFSM(events, shift.WithAcyclic()).
+		Insert(StatusInit, insert{}, StatusUpdate).
+		Update(StatusUpdate, update{}, StatusUpdate, StatusComplete).
+		Update(StatusComplete, complete{}).
+		BuildE()
+	jtest.Require(t, shift.ErrInvalidFSM, err)
+	jtest.AssertKeyValues(t, j.MKS{"cycle": "2->2"}, err)
+
+	_, err = shift.NewFSM(events, shift.WithAcyclic()).
+		Insert(StatusInit, insert{}, StatusUpdate).
+		Update(StatusUpdate, update{}, StatusComplete).
+		Update(StatusComplete, complete{}, StatusUpdate).
+		BuildE()
+	jtest.Require(t, shift.ErrInvalidFSM, err)
+	jtest.AssertKeyValues(t, j.MKS{"cycle": "2->3->2"}, err)
+
+	_, err = shift.NewFSM(events).
+		Insert(StatusInit, insert{}, StatusUpdate).
+		Update(StatusUpdate, update{}, StatusComplete).
+		Update(StatusComplete, complete{}, StatusUpdate).
+		BuildE()
+	jtest.RequireNil(t, err)
+
+	_, err = shift.NewFSM(events, shift.WithAcyclic()).
+		Insert(StatusInit, insert{}, StatusUpdate, StatusComplete).
+		Update(StatusUpdate, update{}, StatusComplete).
+		Update(StatusComplete, complete{}).
+		BuildE()
+	jtest.RequireNil(t, err)
+}
+
+func TestBuildE_DuplicateInserter(t *testing.T) {
+	_, err := shift.NewFSM(events).
+		Insert(StatusInit, insert{}, StatusUpdate).
+		Insert(TestStatus(4), insert{}, StatusUpdate).
+		Update(StatusUpdate, update{}).
+		BuildE()
+	jtest.Require(t, shift.ErrInvalidFSM, err)
+	require.Equal(t, "inserter type already added: invalid fsm", err.Error())
+}
+
+func TestBuild_SharedBase(t *testing.T) {
+	base := shift.NewFSM(events).
+		Insert(StatusInit, insert{}, StatusUpdate)
+
+	long := base.
+		Update(StatusUpdate, update{}, StatusComplete).
+		Update(StatusComplete, complete{}).
+		Build()
+	short := base.
+		Update(StatusUpdate, update{}).
+		Build()
+
+	require.Equal(t, []shift.Status{StatusInit, StatusUpdate, StatusComplete}, long.States())
+	require.Equal(t, []shift.Status{StatusInit, StatusUpdate}, short.States())
+	require.True(t, long.IsValidTransition(StatusUpdate, StatusComplete))
+	require.False(t, short.IsValidTransition(StatusUpdate, StatusComplete))
+}
+
+func TestGenFSM_Graph(t *testing.T) {
+	require.Equal(t, shift.Graph{
+		InsertStatus: StatusInit,
+		Inserts: []shift.Edge{
+			{To: StatusInit, ReqType: "shift_test.insert"},
+		},
+		Transitions: []shift.Edge{
+			{From: StatusInit, To: StatusUpdate, ReqType: "shift_test.update"},
+			{From: StatusUpdate, To: StatusComplete, ReqType: "shift_test.complete"},
+		},
+	}, fsm.Graph())
+}
+
+func TestGenFSM_Introspection(t *testing.T) {
+	require.Equal(t, StatusInit, fsm.InsertStatus())
+	require.Equal(t, []shift.Status{StatusInit, StatusUpdate, StatusComplete}, fsm.States())
+	require.Equal(t, []shift.Transition{
+		{From: StatusInit, To: StatusUpdate},
+		{From: StatusUpdate, To: StatusComplete},
+	}, fsm.Transitions())
+}
+
+func TestGenFSM_Categories(t *testing.T) {
+	fsm := shift.NewFSM(events).
+		Insert(StatusInit, insert{}, shift.Category(StatusUpdate, "user")).
+		Update(StatusUpdate, update{}, StatusComplete).
+		Update(StatusComplete, complete{}).
+		Build()
+
+	require.Equal(t, []shift.Transition{
+		{From: StatusInit, To: StatusUpdate, Category: "user"},
+		{From: StatusUpdate, To: StatusComplete},
+	}, fsm.Transitions())
+	require.Equal(t, []shift.Edge{
+		{From: StatusInit, To: StatusUpdate, ReqType: "shift_test.update", Category: "user"},
+		{From: StatusUpdate, To: StatusComplete, ReqType: "shift_test.complete"},
+	}, fsm.Graph().Transitions)
+}
+
+func TestGenFSM_IsValidTransition(t *testing.T) {
+	require.True(t, fsm.IsValidTransition(StatusInit, StatusUpdate))
+	require.True(t, fsm.IsValidTransition(StatusUpdate, StatusComplete))
+	require.False(t, fsm.IsValidTransition(StatusInit, StatusComplete))
+	require.False(t, fsm.IsValidTransition(StatusComplete, StatusInit))
+	require.False(t, fsm.IsValidTransition(StatusInit, TestStatus(99)))
+	require.False(t, fsm.IsValidTransition(TestStatus(99), StatusUpdate))
+}
+
+func TestWithCategoryMetadata(t *testing.T) {
+	dbc := setup(t)
+
+	events := events.Clone(rsql.WithEventMetadataField("metadata"))
+	fsm := shift.NewFSM(events, shift.WithCategoryMetadata()).
+		Insert(StatusInit, insert{}, shift.Category(StatusUpdate, "user")).
+		Update(StatusUpdate, update{}, StatusComplete).
+		Update(StatusComplete, complete{}).
+		Build()
+
+	require.Equal(t, "user", fsm.TransitionCategory(StatusInit, StatusUpdate))
+	require.Equal(t, "", fsm.TransitionCategory(StatusUpdate, StatusComplete))
+
+	ctx := context.Background()
+	id, err := fsm.Insert(ctx, dbc, insert{Name: "insertMe", DateOfBirth: time.Now()})
+	jtest.RequireNil(t, err)
+
+	err = fsm.Update(ctx, dbc, StatusInit, StatusUpdate, update{ID: id})
+	jtest.RequireNil(t, err)
+
+	err = fsm.Update(ctx, dbc, StatusUpdate, StatusComplete, complete{ID: id})
+	jtest.RequireNil(t, err)
+
+	sc, err := events.ToStream(dbc)(ctx, "")
+	jtest.RequireNil(t, err)
+
+	for _, exp := range []string{"", "user", ""} {
+		e, err := sc.Recv()
+		jtest.RequireNil(t, err)
+		require.Equal(t, exp, string(e.MetaData))
+	}
+}
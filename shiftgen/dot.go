@@ -0,0 +1,30 @@
+package main
+
+import (
+	"bytes"
+	"text/template"
+)
+
+// generateDotDiagram returns a Graphviz DOT diagram of the FSMs built in the
+// package, with the same statuses and transitions as the mermaid diagram.
+func generateDotDiagram(pkgPath string) (string, error) {
+	diagram, err := parseDiagram(pkgPath)
+	if err != nil {
+		return "", err
+	}
+
+	return renderDotTpl(diagram)
+}
+
+func renderDotTpl(diagram *mermaidFormat) (string, error) {
+	t, err := template.New("").Parse(dotTemplate)
+	if err != nil {
+		return "", err
+	}
+
+	buf := new(bytes.Buffer)
+
+	err = t.Execute(buf, diagram)
+
+	return buf.String(), err
+}
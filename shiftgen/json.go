@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/constant"
+	"go/parser"
+	"go/token"
+)
+
+// fsmDescription is a machine-readable description of the FSMs built in a
+// package, ex. for frontends rendering the state graph.
+type fsmDescription struct {
+	Statuses    []statusDescription     `json:"statuses"`
+	Inserts     []transitionDescription `json:"inserts"`
+	Transitions []transitionDescription `json:"transitions"`
+}
+
+type statusDescription struct {
+	Name string `json:"name"`
+	// Value is the constant value of the status, nil if it couldn't be resolved.
+	Value    *int64 `json:"value"`
+	Insert   bool   `json:"insert,omitempty"`
+	Terminal bool   `json:"terminal,omitempty"`
+}
+
+type transitionDescription struct {
+	From    string `json:"from,omitempty"`
+	To      string `json:"to"`
+	Request string `json:"request,omitempty"`
+}
+
+// generateJSONDescription returns a JSON description of the statuses and
+// transitions of the FSMs built in the package, with the same statuses and
+// transitions as the mermaid diagram. Status values are resolved from simple
+// constant declarations in the package.
+func generateJSONDescription(pkgPath string) (string, error) {
+	diagram, err := parseDiagram(pkgPath)
+	if err != nil {
+		return "", err
+	}
+
+	values, err := parseConstValues(pkgPath)
+	if err != nil {
+		return "", err
+	}
+
+	var (
+		desc  fsmDescription
+		index = make(map[string]int)
+	)
+	addStatus := func(name string) *statusDescription {
+		i, ok := index[name]
+		if !ok {
+			i = len(desc.Statuses)
+			index[name] = i
+			sd := statusDescription{Name: name}
+			if v, ok := values[name]; ok {
+				sd.Value = &v
+			}
+			desc.Statuses = append(desc.Statuses, sd)
+		}
+		return &desc.Statuses[i]
+	}
+
+	for _, t := range diagram.StartingPoints {
+		addStatus(t.To).Insert = true
+		desc.Inserts = append(desc.Inserts, transitionDescription{To: t.To, Request: t.Label})
+	}
+	for _, t := range diagram.Transitions {
+		addStatus(t.From)
+		addStatus(t.To)
+		desc.Transitions = append(desc.Transitions, transitionDescription{From: t.From, To: t.To, Request: t.Label})
+	}
+	for _, p := range diagram.TerminalPoints {
+		addStatus(p).Terminal = true
+	}
+
+	b, err := json.MarshalIndent(desc, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b) + "\n", nil
+}
+
+// parseConstValues returns the integer values of the constants declared in
+// the package that are simple constant expressions, including iota.
+func parseConstValues(pkgPath string) (map[string]int64, error) {
+	fs := token.NewFileSet()
+	asts, err := parser.ParseDir(fs, pkgPath, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	consts := make(map[string]constant.Value)
+	for _, pkg := range asts {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				gen, ok := decl.(*ast.GenDecl)
+				if !ok || gen.Tok != token.CONST {
+					continue
+				}
+
+				var last []ast.Expr
+				for iota, spec := range gen.Specs {
+					vs := spec.(*ast.ValueSpec)
+					if len(vs.Values) > 0 {
+						last = vs.Values
+					}
+					for i, name := range vs.Names {
+						if i >= len(last) {
+							break
+						}
+						v := evalConst(last[i], int64(iota), consts)
+						if v.Kind() != constant.Unknown {
+							consts[name.Name] = v
+						}
+					}
+				}
+			}
+		}
+	}
+
+	values := make(map[string]int64)
+	for name, v := range consts {
+		if i, ok := constant.Int64Val(v); ok {
+			values[name] = i
+		}
+	}
+	return values, nil
+}
+
+// evalConst returns the value of a simple constant expression or an unknown
+// value if it isn't supported.
+func evalConst(expr ast.Expr, iota int64, consts map[string]constant.Value) constant.Value {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		if e.Kind == token.INT {
+			return constant.MakeFromLiteral(e.Value, e.Kind, 0)
+		}
+	case *ast.Ident:
+		if e.Name == "iota" {
+			return constant.MakeInt64(iota)
+		}
+		if v, ok := consts[e.Name]; ok {
+			return v
+		}
+	case *ast.ParenExpr:
+		return evalConst(e.X, iota, consts)
+	case *ast.CallExpr:
+		// Typed conversions, ex. status(1).
+		if len(e.Args) == 1 {
+			return evalConst(e.Args[0], iota, consts)
+		}
+	case *ast.UnaryExpr:
+		x := evalConst(e.X, iota, consts)
+		if x.Kind() == constant.Int && (e.Op == token.SUB || e.Op == token.ADD) {
+			return constant.UnaryOp(e.Op, x, 0)
+		}
+	case *ast.BinaryExpr:
+		x := evalConst(e.X, iota, consts)
+		y := evalConst(e.Y, iota, consts)
+		if x.Kind() != constant.Int || y.Kind() != constant.Int {
+			break
+		}
+		switch e.Op {
+		case token.ADD, token.SUB, token.MUL, token.OR, token.AND:
+			return constant.BinaryOp(x, e.Op, y)
+		case token.SHL, token.SHR:
+			s, ok := constant.Uint64Val(y)
+			if ok {
+				return constant.Shift(x, e.Op, uint(s))
+			}
+		}
+	}
+	return constant.MakeUnknown()
+}
@@ -8,7 +8,11 @@ import (
 	"go/token"
 	"os"
 	"slices"
+	"strings"
 	"text/template"
+
+	"github.com/luno/jettison/errors"
+	"github.com/luno/jettison/j"
 )
 
 type mermaidDirection string
@@ -21,9 +25,23 @@ const (
 	bottomToTopDirection mermaidDirection = "BT"
 )
 
+// parseMermaidDirection returns the direction or ErrUnknownDirection if it
+// isn't TB, LR, RL or BT.
+func parseMermaidDirection(s string) (mermaidDirection, error) {
+	d := mermaidDirection(s)
+	switch d {
+	case topToBottomDirection, leftToRightDirection, rightToLeftDirection, bottomToTopDirection:
+		return d, nil
+	default:
+		return unknownDirection, errors.Wrap(ErrUnknownDirection, "", j.MKV{"direction": s})
+	}
+}
+
 type mermaidTransition struct {
 	From string
 	To   string
+	// Label is the request type driving the transition, ex. "update".
+	Label string
 }
 
 type (
@@ -33,10 +51,14 @@ type (
 
 type mermaidFormat struct {
 	Direction      mermaidDirection
-	StartingPoints points
+	StartingPoints transitions
 	TerminalPoints points
 	Transitions    transitions
 	GenSource      string
+
+	// requests are the FSM request types by status, used to label the
+	// transitions to the status.
+	requests map[string]string
 }
 
 func (t *points) add(point string) {
@@ -48,10 +70,25 @@ func (t *points) add(point string) {
 	*t = append(*t, point)
 }
 
+// Join returns the comma separated points, ex. for mermaid class statements.
+func (t points) Join() string {
+	return strings.Join(t, ",")
+}
+
+// JoinTo returns the comma separated distinct to statuses of the transitions.
+func (t transitions) JoinTo() string {
+	var to points
+	for _, trans := range t {
+		to.add(trans.To)
+	}
+	return to.Join()
+}
+
 func (t *transitions) add(trans mermaidTransition) {
 	// Check if transition already exists
-	for _, val := range *t {
+	for i, val := range *t {
 		if val.From == trans.From && val.To == trans.To {
+			(*t)[i].Label = joinLabels(val.Label, trans.Label)
 			return
 		}
 	}
@@ -59,17 +96,43 @@ func (t *transitions) add(trans mermaidTransition) {
 	*t = append(*t, trans)
 }
 
-func generateMermaidDiagram(pkgPath string) (string, error) {
+// joinLabels returns the comma separated labels of a transition driven by
+// multiple request types, ex. two inserters of an ArcFSM.
+func joinLabels(a, b string) string {
+	switch {
+	case a == "":
+		return b
+	case b == "" || slices.Contains(strings.Split(a, ", "), b):
+		return a
+	default:
+		return a + ", " + b
+	}
+}
+
+func generateMermaidDiagram(pkgPath string, direction mermaidDirection) (string, error) {
+	diagram, err := parseDiagram(pkgPath)
+	if err != nil {
+		return "", err
+	}
+	diagram.Direction = direction
+
+	return renderMermaidTpl(diagram)
+}
+
+// parseDiagram returns the statuses and transitions of the FSMs built in the
+// package, shared by the mermaid and DOT diagrams.
+func parseDiagram(pkgPath string) (*mermaidFormat, error) {
 	fs := token.NewFileSet()
 	asts, err := parser.ParseDir(fs, pkgPath, nil, 0)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	genSource := os.Getenv("GOFILE") + ":" + os.Getenv("GOLINE")
 	diagram := &mermaidFormat{
 		Direction: leftToRightDirection,
 		GenSource: genSource,
+		requests:  make(map[string]string),
 	}
 
 	for _, node := range asts {
@@ -85,7 +148,21 @@ func generateMermaidDiagram(pkgPath string) (string, error) {
 		})
 	}
 
-	return renderMermaidTpl(diagram)
+	diagram.labelTransitions()
+
+	return diagram, nil
+}
+
+// labelTransitions labels the FSM transitions without a label with the
+// request type of their to status.
+func (d *mermaidFormat) labelTransitions() {
+	for _, tt := range []transitions{d.StartingPoints, d.Transitions} {
+		for i, t := range tt {
+			if t.Label == "" {
+				tt[i].Label = d.requests[t.To]
+			}
+		}
+	}
 }
 
 func renderMermaidTpl(diagram *mermaidFormat) (string, error) {
@@ -131,17 +208,17 @@ func buildMermaidDiagram(expr *ast.CallExpr, diagram *mermaidFormat, shiftAlias
 	// Check for the NewArcFSM at the beginning of the chain
 	if isShiftCall(expr, "NewArcFSM", shiftAlias) {
 		if selectorExpr.Sel.Name == "Insert" {
-			if len(expr.Args) > 0 {
+			if len(expr.Args) >= 2 {
 				firstArg := formatArg(expr.Args[0])
-				diagram.StartingPoints.add(firstArg)
+				diagram.StartingPoints.add(mermaidTransition{From: "[*]", To: firstArg, Label: formatArg(expr.Args[1])})
 			}
 		}
 
 		if selectorExpr.Sel.Name == "Update" {
-			if len(expr.Args) >= 2 {
+			if len(expr.Args) >= 3 {
 				firstArg := formatArg(expr.Args[0])
 				secondArg := formatArg(expr.Args[1])
-				diagram.Transitions.add(mermaidTransition{From: firstArg, To: secondArg})
+				diagram.Transitions.add(mermaidTransition{From: firstArg, To: secondArg, Label: formatArg(expr.Args[2])})
 			}
 		}
 	}
@@ -149,12 +226,10 @@ func buildMermaidDiagram(expr *ast.CallExpr, diagram *mermaidFormat, shiftAlias
 	// Check for the NewFSM at the beginning of the chain
 	if isShiftCall(expr, "NewFSM", shiftAlias) {
 		if selectorExpr.Sel.Name == "Insert" {
-			if len(expr.Args) == 2 {
-				firstArg := formatArg(expr.Args[0])
-				diagram.StartingPoints.add(firstArg)
-			} else if len(expr.Args) > 2 {
+			if len(expr.Args) >= 2 {
 				firstArg := formatArg(expr.Args[0])
-				diagram.StartingPoints.add(firstArg)
+				diagram.StartingPoints.add(mermaidTransition{From: "[*]", To: firstArg})
+				diagram.requests[firstArg] = formatArg(expr.Args[1])
 
 				for _, arg := range expr.Args[2:] {
 					diagram.Transitions.add(mermaidTransition{From: firstArg, To: formatArg(arg)})
@@ -163,6 +238,9 @@ func buildMermaidDiagram(expr *ast.CallExpr, diagram *mermaidFormat, shiftAlias
 		}
 
 		if selectorExpr.Sel.Name == "Update" {
+			if len(expr.Args) >= 2 {
+				diagram.requests[formatArg(expr.Args[0])] = formatArg(expr.Args[1])
+			}
 			if len(expr.Args) == 2 {
 				diagram.TerminalPoints.add(formatArg(expr.Args[0]))
 			} else if len(expr.Args) > 2 {
@@ -210,6 +288,16 @@ func formatArg(arg ast.Expr) string {
 		if _, ok := a.X.(*ast.Ident); ok {
 			return a.Sel.Name
 		}
+	case *ast.CompositeLit:
+		// Request types, ex. update{} or &update{}
+		return formatArg(a.Type)
+	case *ast.UnaryExpr:
+		return formatArg(a.X)
+	case *ast.CallExpr:
+		// Unwrap categorised next statuses, ex. shift.Category(COMPLETED, "system")
+		if sel, ok := a.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "Category" && len(a.Args) > 0 {
+			return formatArg(a.Args[0])
+		}
 	}
 
 	return fmt.Sprintf("%s", arg)
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/luno/jettison/errors"
+	"github.com/luno/jettison/j"
+)
+
+// schemaKeywords are the first words of table definitions that aren't columns.
+var schemaKeywords = []string{"primary", "key", "unique", "index", "constraint", "foreign", "fulltext", "spatial", "check"}
+
+// parseSchema returns the columns of the tables created by the
+// "create table" statements of the sql schema.
+func parseSchema(schema string) map[string][]string {
+	tables := make(map[string][]string)
+	lower := strings.ToLower(schema)
+	for {
+		i := strings.Index(lower, "create ")
+		if i < 0 {
+			return tables
+		}
+		lower, schema = lower[i+len("create "):], schema[i+len("create "):]
+
+		words := strings.Fields(lower)
+		if len(words) > 0 && words[0] == "temporary" {
+			words = words[1:]
+		}
+		if len(words) == 0 || words[0] != "table" {
+			continue
+		}
+
+		open := strings.Index(schema, "(")
+		if open < 0 {
+			return tables
+		}
+		name := strings.Fields(schema[:open])
+		table := unquote(name[len(name)-1])
+
+		var defs []string
+		depth, start := 0, open+1
+	scan:
+		for k := open; k < len(schema); k++ {
+			switch schema[k] {
+			case '(':
+				depth++
+			case ')':
+				depth--
+				if depth == 0 {
+					defs = append(defs, schema[start:k])
+					lower, schema = lower[k:], schema[k:]
+					break scan
+				}
+			case ',':
+				if depth == 1 {
+					defs = append(defs, schema[start:k])
+					start = k + 1
+				}
+			}
+		}
+
+		var cols []string
+		for _, def := range defs {
+			f := strings.Fields(def)
+			if len(f) == 0 || slices.Contains(schemaKeywords, strings.ToLower(f[0])) {
+				continue
+			}
+			cols = append(cols, unquote(f[0]))
+		}
+		tables[table] = cols
+	}
+}
+
+// unquote returns the sql identifier without quotes and schema qualifier.
+func unquote(ident string) string {
+	if i := strings.LastIndex(ident, "."); i >= 0 {
+		ident = ident[i+1:]
+	}
+	return strings.TrimFunc(ident, func(r rune) bool {
+		return r == '`' || r == '"' || r == '[' || r == ']' || unicode.IsSpace(r)
+	})
+}
+
+// structCols returns the columns of the table mapped by the struct,
+// including the managed timestamp columns.
+func structCols(s Struct, timestamps []string) []string {
+	cols := []string{s.IDCol, s.StatusField}
+	for _, f := range s.KeyFields {
+		cols = append(cols, f.Col)
+	}
+	for _, f := range s.Fields {
+		cols = append(cols, f.Col)
+	}
+	for _, f := range s.CASFields {
+		cols = append(cols, f.Col)
+	}
+	if s.VersionField != nil {
+		cols = append(cols, s.VersionField.Col)
+	}
+	return append(cols, timestamps...)
+}
+
+// insertTimestamps returns the timestamp columns set by inserts of the struct.
+func insertTimestamps(s Struct) []string {
+	if s.AutoCreatedAt() {
+		return []string{*createdCol, *updatedCol}
+	}
+	return nil
+}
+
+// updateTimestamps returns the timestamp columns set by updates of the struct.
+func updateTimestamps(s Struct) []string {
+	if s.SetUpdatedAt() {
+		return []string{*updatedCol}
+	}
+	return nil
+}
+
+// validateSchema returns ErrUnknownColumns if the structs map to columns
+// missing from the tables of the -schema file, listing them diff style.
+// Columns of the tables not mapped by any struct are logged as warnings.
+func validateSchema(data Data) error {
+	b, err := os.ReadFile(*schemaFile)
+	if err != nil {
+		return errors.Wrap(err, "Failed reading schema", j.MKV{"schema": *schemaFile})
+	}
+	tables := parseSchema(string(b))
+
+	mapped := make(map[string][]string) // Table to mapped columns.
+	var missing []string
+	addMissing := func(line string) {
+		if !slices.Contains(missing, line) {
+			missing = append(missing, line)
+		}
+	}
+	check := func(ss []Struct, timestamps func(Struct) []string) {
+		for _, s := range ss {
+			cols, ok := tables[s.Table]
+			if !ok {
+				addMissing("- " + s.Table + " (table of " + s.Type + ")")
+				continue
+			}
+			for _, col := range structCols(s, timestamps(s)) {
+				if !slices.Contains(cols, col) {
+					addMissing("- " + s.Table + "." + col + " (" + s.Type + ")")
+				}
+				mapped[s.Table] = append(mapped[s.Table], col)
+			}
+		}
+	}
+	none := func(Struct) []string { return nil }
+	check(data.Inserters, insertTimestamps)
+	check(data.Upserters, insertTimestamps)
+	check(data.Updaters, updateTimestamps)
+	check(data.Getters, none)
+	check(data.Loaders, none)
+	check(data.Deleters, none)
+	if *softDelete != "" {
+		for _, s := range append(data.Updaters, data.Deleters...) {
+			if cols, ok := tables[s.Table]; ok && !slices.Contains(cols, *softDelete) {
+				addMissing("- " + s.Table + "." + *softDelete + " (soft delete)")
+			}
+			mapped[s.Table] = append(mapped[s.Table], *softDelete)
+		}
+	}
+
+	var extra []string
+	for table, cols := range tables {
+		if _, ok := mapped[table]; !ok {
+			continue
+		}
+		for _, col := range cols {
+			if !slices.Contains(mapped[table], col) {
+				extra = append(extra, "+ "+table+"."+col)
+			}
+		}
+	}
+	slices.Sort(extra)
+
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		return errors.Wrap(ErrUnknownColumns, "schema diff:\n"+strings.Join(append(missing, extra...), "\n"),
+			j.MKV{"schema": *schemaFile})
+	}
+	if len(extra) > 0 {
+		log.Printf("Columns not mapped by any struct:\n%s", strings.Join(extra, "\n"))
+	}
+	return nil
+}
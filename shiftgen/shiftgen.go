@@ -6,24 +6,43 @@
 // MetadataInserter or MetadataUpdater since it is orthogonal to inserting
 // and updating domain entity rows.
 //
+// Getters are read-side structs mapping the columns of a table row, shiftgen
+// generates GetMany methods for them to load multiple rows by id.
+//
+// Upserters are inserters that update the existing row if the insert conflicts
+// with a primary or unique key, shiftgen generates Upsert methods for them
+// (mysql dialect only).
+//
+// Loaders are read-side structs mapping the columns of a table row including
+// the status, shiftgen generates Get methods for them to load a row by id.
+//
+// Deleters are structs containing the ID field (and optionally compare-and-swap
+// fields), shiftgen generates Delete methods for them to delete rows in a status.
+//
 //	Usage:
-//	  //go:generate shiftgen -table=model_table -inserter=InsertReq -updaters=UpdateReq,CompleteReq
+//	  //go:generate shiftgen -table=model_table -inserter=InsertReq -updaters=UpdateReq,CompleteReq -getters=Model
 package main
 
 import (
 	"bytes"
 	"flag"
 	"go/ast"
+	"go/build/constraint"
 	"go/parser"
 	"go/token"
+	"go/types"
 	"io"
 	"log"
 	"os"
 	"path"
 	"reflect"
-	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"text/template"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/luno/jettison/errors"
 	"github.com/luno/jettison/j"
@@ -34,69 +53,360 @@ import (
 // for struct fields (the default is snake case of the field name).
 //
 //	Ex `shift:"custom_col_name"`.
+//
+// Comma separated options may follow the column name:
+//
+//	primary: The field is the table's primary key, instead of the ID field.
+//	     Ex `shift:"ksuid,primary"`. Multiple primary fields define a composite
+//	     primary key, which must include the ID field.
+//	omitempty: Updater fields only. The column is only updated if the field
+//	     isn't its zero value. Ex `shift:"amount,omitempty"`.
+//	json: The field is stored JSON encoded in the column. Ex `shift:"attrs,json"`.
+//	encoding=json|gob: The field is stored encoded with encoding/json or
+//	     encoding/gob in the column. Ex `shift:"ids,encoding=gob"`.
+//	     Slice (except []byte) and map fields are JSON encoded by default.
+//	unix: time.Time fields only. The field is stored as unix seconds in an
+//	     integer column. Ex `shift:"expires_at,unix"`.
+//	cas: Updater fields only. The field is not set, instead the update only
+//	     succeeds if the column matches the field value (compare-and-swap).
+//	     A NULL field value matches a NULL column. Ex `shift:"worker_id,cas"`.
+//	version: Updater fields only. The column is an optimistic lock counter: the
+//	     update only succeeds if the column matches the field value and
+//	     increments it, else shift.ErrVersionConflict is returned if the
+//	     row is in the from status with a different version.
+//	     Ex `shift:"version,version"`.
 const Tag = "shift"
 
+// tagOptPrimary is the tag option marking a field as the primary key.
+const tagOptPrimary = "primary"
+
+// tagOptOmitEmpty is the tag option marking an updater field as only updated
+// if it isn't its zero value.
+const tagOptOmitEmpty = "omitempty"
+
+// tagOptJSON is the tag option marking a field as stored JSON encoded.
+const tagOptJSON = "json"
+
+// tagOptEncoding is the prefix of the tag option specifying the encoding of
+// a field stored encoded.
+const tagOptEncoding = "encoding="
+
+const (
+	encodingJSON = "json"
+	encodingGob  = "gob"
+)
+
+// tagOptUnix is the tag option marking a time.Time field as stored as
+// unix seconds.
+const tagOptUnix = "unix"
+
+// tagOptCAS is the tag option marking an updater field as a compare-and-swap
+// predicate.
+const tagOptCAS = "cas"
+
+// tagOptVersion is the tag option marking an updater field as the expected
+// value of the optimistic lock counter column.
+const tagOptVersion = "version"
+
 const tagPrefix = "`" + Tag + ":"
 
-// idFieldName is the name of the field in the Go struct used for the table's ID
-// TODO: Support custom ID field name.
-const idFieldName = "ID"
+// tableDirective is the prefix of a struct doc comment line overriding the
+// table of the struct, ex. "// shift:table=user_history".
+const tableDirective = Tag + ":table="
+
+const (
+	dialectMySQL    = "mysql"
+	dialectPostgres = "postgres"
+	dialectSQLite   = "sqlite"
+)
+
+// defaultIDField is the default name of the field in the Go struct used for the table's ID.
+const defaultIDField = "ID"
 
 var (
+	idField = flag.String("id_field", defaultIDField,
+		"The name of the field in the Go structs used for the table's ID")
 	updaters = flag.String("updaters", "",
 		"The struct types (comma seperated) to generate Update methods for")
 	inserter = flag.String("inserter", "",
 		"The struct type to generate a Insert method for")
 	inserters = flag.String("inserters", "",
 		"The ArcFSM struct types (comma seperated) to generate Insert methods for")
+	batchInserters = flag.String("batch_inserters", "",
+		"The inserter struct types (comma seperated) to also generate InsertBatch methods for")
+	forceUpdaters = flag.String("force_updaters", "",
+		"The updater struct types (comma seperated) to also generate ForceUpdate methods for")
+	upserters = flag.String("upserters", "",
+		"The struct types (comma seperated) to generate Upsert methods for")
+	deleters = flag.String("deleters", "",
+		"The struct types (comma seperated) to generate Delete methods for")
+	getters = flag.String("getters", "",
+		"The struct types (comma seperated) to generate GetMany methods for")
+	loaders = flag.String("loaders", "",
+		"The struct types (comma seperated) to generate Get methods for")
 	table = flag.String("table", "",
-		"The sql table name to insert and update")
+		"The sql table name to insert and update, overridden per struct by a \"// shift:table=name\" doc comment")
+	primaryType = flag.String("primary_type", "int64",
+		"The Go type of the table's primary key (int64, uint64 or string) for structs without an ID field")
+	autoIncrement = flag.Bool("auto_increment", true,
+		"Whether the table's integer primary key is auto incremented, otherwise inserters must contain the ID field")
 	statusField = flag.String("status_field", "status",
 		"The sql column in the table containing the status")
 	outFile = flag.String("out", "shift_gen.go",
 		"output filename")
 	quoteChar = flag.String("quote_char", "`",
-		"Character to use when quoting column names")
+		"Character to use when quoting column names, defaults to \" for postgres and sqlite")
+	dialect = flag.String("dialect", dialectMySQL,
+		"The SQL dialect of the generated queries (mysql, postgres or sqlite)")
+	nowFunc = flag.String("now_func", "time.Now",
+		"The func() time.Time called for created_at and updated_at timestamps, ex. myclock.Now")
+	createdCol = flag.String("created_col", "created_at",
+		"The sql column in the table containing the created timestamp")
+	updatedCol = flag.String("updated_col", "updated_at",
+		"The sql column in the table containing the updated timestamp")
+	unixTimestamps = flag.Bool("unix_timestamps", false,
+		"Store the created_at and updated_at timestamps as unix seconds in integer columns")
+	noTimestamps = flag.Bool("no_timestamps", false,
+		"Don't set created_at and updated_at columns, fields with those columns are treated as ordinary columns")
+	updatedAtOnChange = flag.Bool("updated_at_on_change", false,
+		"Only set updated_at in updaters that modify columns other than status")
+	softDelete = flag.String("soft_delete", "",
+		"The sql datetime column marking soft deleted rows (ex. deleted_at), updaters, getters and loaders skip soft deleted rows and deleters soft delete instead, disabled if empty")
+	idempotentInsert = flag.Bool("idempotent_insert", false,
+		"Generate inserts that are a no-op returning the existing id with shift.ErrAlreadyInserted if the row already exists (mysql only), "+
+			"ex. for replayed events. The FSM doesn't insert an event for replayed inserts")
+	queryTimeout = flag.Duration("query_timeout", 0,
+		"The timeout of the context used by generated methods for their queries (ex. 5s), disabled if zero")
+	classifyRowCount = flag.Bool("classify_row_count", false,
+		"Query the status of rows not updated by updaters to return shift.ErrRowNotFound or shift.ErrStatusMismatch")
+	dryRun = flag.Bool("dry_run", false,
+		"Write the generated code to stdout instead of the output file, without generating the mermaid diagram")
 	mermaid = flag.Bool("mermaid", true,
 		"Generate mermaid state machine diagram")
 	mermaidOut = flag.String("mermaid_out", "shift_gen.mmd",
 		"Output filename for mermaid state machine diagram")
+	dot = flag.Bool("dot", false,
+		"Generate Graphviz DOT state machine diagram")
+	dotOut = flag.String("dot_out", "shift_gen.dot",
+		"Output filename for Graphviz DOT state machine diagram")
+	schemaFile = flag.String("schema", "",
+		"A .sql file with the create table statements to validate the mapped columns against, disabled if empty")
+	headerFile = flag.String("header", "",
+		"A file whose contents (ex. a copyright comment) are prepended to the generated code before the package clause")
+	buildTags = flag.String("build_tags", "",
+		"A build constraint expression added as a //go:build line to the generated code, ex. \"integration && !race\"")
+	templateFile = flag.String("template", "",
+		"A Go text/template file used instead of the built-in template of the generated code, executed with the same data and funcs")
+	pkgName = flag.String("package", "",
+		"The package name of the generated file, defaults to the package of the structs")
+	jsonOut = flag.String("json_out", "",
+		"Output filename for a JSON description of the state machine (statuses, inserts and transitions), disabled if empty")
+	mermaidDir = flag.String("mermaid_direction", string(leftToRightDirection),
+		"Direction of the mermaid state machine diagram (TB, LR, RL or BT)")
 )
 
 var ErrIDTypeMismatch = errors.New("Inserters and updaters' ID fields should have matching types", j.C("ERR_3db87b866daeda57"))
 
+var ErrInvalidPrimaryType = errors.New("Primary type should be int64, uint64 or string", j.C("ERR_6f0c2b8e4a1d7953"))
+
+var ErrIDFieldNotFound = errors.New("ID field not found in any struct", j.C("ERR_08d5a3e17f6b92c4"))
+
+var ErrUnknownDialect = errors.New("Unknown SQL dialect", j.C("ERR_5a2e7c90b14fd836"))
+
+var ErrUnknownDirection = errors.New("Unknown mermaid diagram direction", j.C("ERR_2b6f8d41c07e93a5"))
+
+var ErrKeyMismatch = errors.New("Updaters and deleters' composite primary keys should match", j.C("ERR_91b7e02c5f3da468"))
+
+var ErrUnknownColumns = errors.New("Columns missing from schema", j.C("ERR_d25b8f0e7a4c6193"))
+
+var ErrNoTable = errors.New("No table specified", j.C("ERR_1f7c4b9e62a0d358"))
+
+var ErrDuplicateColumn = errors.New("Multiple fields map to the same column", j.C("ERR_8e3a5d27b90c4f61"))
+
+var ErrInsertMissingID = errors.New("Inserter must contain ID field for string or non auto increment primary keys", j.C("ERR_c4e91a07d2b35f68"))
+
+var ErrBatchInsertMissingID = errors.New("Batch inserter must contain ID field unless the dialect returns the inserted ids", j.C("ERR_7d3f0b58e21ac946"))
+
+var ErrInsertRoleOverlap = errors.New("Inserters and upserters can't also be updaters, getters, loaders or deleters", j.C("ERR_0e9b6c3f58a27d14"))
+
 type Field struct {
 	Name string
 	Col  string
+	// Type is the Go type of the field, only set for primary and version fields
+	Type string
+	// OmitEmpty is true if the column is only updated if the field isn't
+	// its zero value, checked by the NonZero expression.
+	OmitEmpty bool
+	NonZero   string
+	// Encoding is the encoding (json or gob) of fields stored encoded,
+	// empty otherwise.
+	Encoding string
+	// Pointer is true if the field is a pointer mapping to a nullable column.
+	Pointer bool
+	// Duration is true if the field is a time.Duration stored as an integer.
+	Duration bool
+	// Unix is true if the field is a time.Time stored as unix seconds.
+	Unix bool
 }
 
 type Struct struct {
-	Table           string
-	Type            string
-	StatusField     string
-	Fields          []Field
+	Table       string
+	Type        string
+	StatusField string
+	Fields      []Field
+	// CASFields are the updater fields that must match the current row
+	// for the update to succeed.
+	CASFields []Field
+	// VersionField is the updater field holding the expected value of the
+	// optimistic lock counter column, or nil.
+	VersionField    *Field
 	CustomCreatedAt bool
 	CustomUpdatedAt bool
-	HasID           bool
+	// CreatedAtField and UpdatedAtField are the names of the custom
+	// timestamp fields.
+	CreatedAtField string
+	UpdatedAtField string
+	// NoTimestamps is true if created_at and updated_at aren't managed.
+	NoTimestamps bool
+	HasID        bool
 	// IDType is the type of the ID field
 	IDType string
+	// IDField is the name of the ID field
+	IDField string
+	// IDCol is the primary key column of the ID field
+	IDCol string
+	// KeyFields are the primary fields other than the ID field
+	// of a composite primary key.
+	KeyFields []Field
 }
 
 func (s Struct) IDZeroValue() string {
 	switch s.IDType {
 	case "string":
 		return `""`
-	case "int64":
+	case "int64", "uint64":
 		return `0`
 	}
 	return ``
 }
 
+// LastInsertID returns the expression converting the int64 last insert id
+// expression v to the ID type.
+func (s Struct) LastInsertID(v string) string {
+	if s.IDType == "uint64" {
+		return "uint64(" + v + ")"
+	}
+	return v
+}
+
+// AutoCreatedAt returns true if created_at is set to the current time.
+func (s Struct) AutoCreatedAt() bool {
+	return !s.NoTimestamps && !s.CustomCreatedAt
+}
+
+// AutoUpdatedAt returns true if updated_at is set to the current time.
+func (s Struct) AutoUpdatedAt() bool {
+	return !s.NoTimestamps && !s.CustomUpdatedAt
+}
+
+// InsertArgCount returns the number of arguments of the generated insert query.
+func (s Struct) InsertArgCount() int {
+	n := 1 + len(s.Fields) // Status and fields
+	if s.HasID {
+		n++
+	}
+	if s.AutoCreatedAt() {
+		n += 2 // created_at and updated_at
+	}
+	return n
+}
+
+// UpdateArgCount returns the number of arguments of the generated static update query.
+func (s Struct) UpdateArgCount() int {
+	n := 3 + len(s.Fields) + len(s.KeyFields) + len(s.CASFields) // Status, ID, from status, fields and keys
+	if s.VersionField != nil {
+		n++
+	}
+	if s.SetUpdatedAt() {
+		n++
+	}
+	return n
+}
+
+// UpsertArgCount returns the number of arguments of the generated upsert query.
+func (s Struct) UpsertArgCount() int {
+	n := 2 // Insert and update status
+	if s.HasID {
+		n++
+	}
+	if s.AutoCreatedAt() {
+		n++
+	}
+	if s.AutoUpdatedAt() {
+		n += 2
+	}
+	for _, f := range s.Fields {
+		n++
+		if f.Col != *createdCol {
+			n++
+		}
+	}
+	return n
+}
+
+// Sparse returns true if the generated update query depends on the updater's
+// field values and is built at runtime, otherwise it is a static query.
+func (s Struct) Sparse() bool {
+	for _, f := range s.Fields {
+		if f.OmitEmpty {
+			return true
+		}
+	}
+	return s.UpdatedAtIfChanged()
+}
+
+// SetUpdatedAt returns true if the generated update should set updated_at to
+// the current time. This is the case unless the updater provides a custom
+// updated_at or if -updated_at_on_change is set and the updater only moves
+// the status, leaving updated_at untouched.
+func (s Struct) SetUpdatedAt() bool {
+	if !s.AutoUpdatedAt() {
+		return false
+	}
+	if !*updatedAtOnChange {
+		return true
+	}
+	return len(s.Fields) > 0 && !s.UpdatedAtIfChanged()
+}
+
+// UpdatedAtIfChanged returns true if the generated update should only set
+// updated_at at runtime if any fields are updated. This is the case if
+// -updated_at_on_change is set and all the updater's fields are omitempty.
+func (s Struct) UpdatedAtIfChanged() bool {
+	if !s.AutoUpdatedAt() || !*updatedAtOnChange || len(s.Fields) == 0 {
+		return false
+	}
+	for _, f := range s.Fields {
+		if !f.OmitEmpty {
+			return false
+		}
+	}
+	return true
+}
+
 type Data struct {
 	Package   string
 	GenSource string
 	Updaters  []Struct
 	Inserters []Struct
+	Getters   []Struct
+	Loaders   []Struct
+	Deleters  []Struct
+	Upserters []Struct
+	// BatchInserters are the inserters that also have InsertBatch methods.
+	BatchInserters []Struct
+	// ForceUpdaters are the updaters that also have ForceUpdate methods.
+	ForceUpdaters []Struct
 }
 
 func main() {
@@ -106,7 +416,17 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	uu := parseUpdaters()
+	uu := parseList(*updaters)
+	gg := parseList(*getters)
+	dd := parseList(*deleters)
+	pp := parseList(*upserters)
+	bb := parseList(*batchInserters)
+	ll := parseList(*loaders)
+
+	direction, err := parseMermaidDirection(*mermaidDir)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	pwd, err := os.Getwd()
 	if err != nil {
@@ -114,11 +434,18 @@ func main() {
 	}
 	filePath := path.Join(pwd, *outFile)
 
-	src, err := generateSrc(pwd, *table, ii, uu, *statusField, filePath)
+	src, err := generateSrc(pwd, *table, ii, uu, gg, dd, pp, bb, ll, *statusField, filePath)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	if *dryRun {
+		if _, err = os.Stdout.Write(src); err != nil {
+			log.Fatal(errors.Wrap(err, "Error writing to stdout"))
+		}
+		return
+	}
+
 	if err = os.WriteFile(filePath, src, 0o644); err != nil {
 		log.Fatal(errors.Wrap(err, "Error writing file"))
 	}
@@ -126,7 +453,7 @@ func main() {
 	if *mermaid {
 		mermaidFilePath := path.Join(pwd, *mermaidOut)
 
-		mmd, err := generateMermaidDiagram(pwd)
+		mmd, err := generateMermaidDiagram(pwd, direction)
 
 		if err != nil {
 			log.Fatal(err)
@@ -136,6 +463,32 @@ func main() {
 			log.Fatal(errors.Wrap(err, "Error writing file"))
 		}
 	}
+
+	if *dot {
+		dotFilePath := path.Join(pwd, *dotOut)
+
+		gv, err := generateDotDiagram(pwd)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err = os.WriteFile(dotFilePath, []byte(gv), 0o644); err != nil {
+			log.Fatal(errors.Wrap(err, "Error writing file"))
+		}
+	}
+
+	if *jsonOut != "" {
+		jsonFilePath := path.Join(pwd, *jsonOut)
+
+		desc, err := generateJSONDescription(pwd)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err = os.WriteFile(jsonFilePath, []byte(desc), 0o644); err != nil {
+			log.Fatal(errors.Wrap(err, "Error writing file"))
+		}
+	}
 }
 
 func parseInserters() ([]string, error) {
@@ -154,26 +507,47 @@ func parseInserters() ([]string, error) {
 	return ii, nil
 }
 
-func parseUpdaters() []string {
-	var uu []string
-	if strings.TrimSpace(*updaters) != "" {
-		for _, u := range strings.Split(*updaters, ",") {
-			uu = append(uu, strings.TrimSpace(u))
+// parseList returns the struct types of a comma separated flag value.
+func parseList(val string) []string {
+	var res []string
+	if strings.TrimSpace(val) != "" {
+		for _, v := range strings.Split(val, ",") {
+			res = append(res, strings.TrimSpace(v))
 		}
 	}
-	return uu
+	return res
 }
 
-func generateSrc(pkgPath, table string, inserters, updaters []string, statusField, filePath string) ([]byte, error) {
-	if table == "" {
-		return nil, errors.New("No table specified")
+func generateSrc(pkgPath, table string, inserters, updaters, getters, deleters, upserters, batchInserters, loaders []string, statusField, filePath string) ([]byte, error) {
+	if len(inserters) == 0 && len(updaters) == 0 && len(getters) == 0 && len(deleters) == 0 && len(upserters) == 0 && len(loaders) == 0 {
+		return nil, errors.New("No inserter, updaters, getters, loaders, deleters or upserters specified")
+	}
+	for _, b := range batchInserters {
+		if !slices.Contains(inserters, b) {
+			return nil, errors.New("Batch inserter must also be an inserter", j.MKV{"name": b})
+		}
 	}
-	if len(inserters) == 0 && len(updaters) == 0 {
-		return nil, errors.New("No inserter or updaters specified")
+	forces := parseList(*forceUpdaters)
+	for _, f := range forces {
+		if !slices.Contains(updaters, f) {
+			return nil, errors.New("Force updater must also be an updater", j.MKV{"name": f})
+		}
+	}
+	if len(upserters) > 0 && *dialect != dialectMySQL {
+		return nil, errors.New("Upserters only supported by the mysql dialect", j.MKV{"dialect": *dialect})
+	}
+	if *idempotentInsert && *dialect != dialectMySQL {
+		return nil, errors.New("Idempotent inserts only supported by the mysql dialect", j.MKV{"dialect": *dialect})
+	}
+	if !slices.Contains([]string{dialectMySQL, dialectPostgres, dialectSQLite}, *dialect) {
+		return nil, errors.Wrap(ErrUnknownDialect, "", j.MKV{"dialect": *dialect})
+	}
+	if !slices.Contains([]string{"int64", "uint64", "string"}, *primaryType) {
+		return nil, errors.Wrap(ErrInvalidPrimaryType, "", j.MKV{"primary_type": *primaryType})
 	}
 
 	fs := token.NewFileSet()
-	asts, err := parser.ParseDir(fs, pkgPath, nil, 0)
+	asts, err := parser.ParseDir(fs, pkgPath, nil, parser.ParseComments)
 	if err != nil {
 		return nil, err
 	}
@@ -190,13 +564,42 @@ func generateSrc(pkgPath, table string, inserters, updaters []string, statusFiel
 	for _, u := range updaters {
 		ups[u] = true
 	}
+	gets := make(map[string]bool, len(getters))
+	for _, g := range getters {
+		gets[g] = true
+	}
+	lds := make(map[string]bool, len(loaders))
+	for _, l := range loaders {
+		lds[l] = true
+	}
+	dels := make(map[string]bool, len(deleters))
+	for _, d := range deleters {
+		dels[d] = true
+	}
+	upss := make(map[string]bool, len(upserters))
+	for _, u := range upserters {
+		upss[u] = true
+	}
 	for p, a := range asts {
 		var inspectErr error
+		docs := make(map[*ast.TypeSpec]*ast.CommentGroup)
 		ast.Inspect(a, func(n ast.Node) bool {
 			if inspectErr != nil {
 				return false
 			}
 
+			// The doc comment of a single type declaration belongs to the GenDecl.
+			if gd, ok := n.(*ast.GenDecl); ok && gd.Tok == token.TYPE {
+				for _, spec := range gd.Specs {
+					ts := spec.(*ast.TypeSpec)
+					docs[ts] = ts.Doc
+					if ts.Doc == nil && len(gd.Specs) == 1 {
+						docs[ts] = gd.Doc
+					}
+				}
+				return true
+			}
+
 			t, ok := n.(*ast.TypeSpec)
 			if !ok {
 				return true
@@ -204,7 +607,11 @@ func generateSrc(pkgPath, table string, inserters, updaters []string, statusFiel
 			typ := t.Name.Name
 			isU, firstU := ups[typ]
 			isI, firstI := ins[typ]
-			if !isU && !isI {
+			isG, firstG := gets[typ]
+			isL, firstL := lds[typ]
+			isD, firstD := dels[typ]
+			isP, firstP := upss[typ]
+			if !isU && !isI && !isG && !isL && !isD && !isP {
 				return true
 			}
 
@@ -214,6 +621,24 @@ func generateSrc(pkgPath, table string, inserters, updaters []string, statusFiel
 			if isI && !firstI {
 				log.Fatalf("Found multiple inserter struct definitions: %s", typ)
 			}
+			if isG && !firstG {
+				log.Fatalf("Found multiple getter struct definitions: %s", typ)
+			}
+			if isL && !firstL {
+				log.Fatalf("Found multiple loader struct definitions: %s", typ)
+			}
+			if isD && !firstD {
+				log.Fatalf("Found multiple deleter struct definitions: %s", typ)
+			}
+			if isP && !firstP {
+				log.Fatalf("Found multiple upserter struct definitions: %s", typ)
+			}
+
+			// Inserters carry composite key fields as columns, while the other
+			// roles match on them, so the two can't share a struct.
+			if (isI || isP) && (isU || isG || isL || isD) {
+				inspectErr = errors.Wrap(ErrInsertRoleOverlap, "", j.MKV{"name": typ})
+			}
 
 			if data.Package != "" && data.Package != p {
 				inspectErr = errors.New("Struct types defined in separate packages")
@@ -224,7 +649,20 @@ func generateSrc(pkgPath, table string, inserters, updaters []string, statusFiel
 			if !ok {
 				inspectErr = errors.New("Inserter/updater must be a struct type", j.MKV{"name": typ})
 			}
-			st := Struct{Type: typ, Table: table, StatusField: statusField, IDType: "int64"}
+			st := Struct{
+				Type:         typ,
+				Table:        structTable(docs[t], table),
+				StatusField:  statusField,
+				IDType:       *primaryType,
+				IDField:      *idField,
+				IDCol:        toSnakeCase(*idField),
+				NoTimestamps: *noTimestamps,
+			}
+			if st.Table == "" {
+				inspectErr = errors.Wrap(ErrNoTable, "", j.MKV{"name": typ})
+			}
+			var primaries []Field
+			cols := make(map[string]string) // Column to field name, excluding compare-and-swap fields.
 			for _, f := range s.Fields.List {
 				if len(f.Names) == 0 {
 					inspectErr = errors.New("Inserter/updater, but has anonymous field (maybe shift.Reflect)", j.MKV{"name": typ})
@@ -233,44 +671,171 @@ func generateSrc(pkgPath, table string, inserters, updaters []string, statusFiel
 					inspectErr = errors.New("Inserter/updaters, but one field multiple names: %v", j.MKV{"name": typ, "field_names": f.Names})
 				}
 				name := f.Names[0].Name
-				if name == idFieldName {
-					st.HasID = true
-					if ti, ok := f.Type.(*ast.Ident); !ok {
-						inspectErr = errors.New("ID field should be of type int64 or string")
-					} else {
-						st.IDType = ti.Name
+				col := toSnakeCase(name)
+				var opts []string
+				if f.Tag != nil && strings.HasPrefix(f.Tag.Value, tagPrefix) {
+					tag := reflect.StructTag(f.Tag.Value[1 : len(f.Tag.Value)-1]).Get(Tag) // Delete first and last quotation
+					col, opts = parseTag(tag, col)
+				}
+
+				// Compare-and-swap fields are predicates, so they may match a set column.
+				if !slices.Contains(opts, tagOptCAS) {
+					if prev, ok := cols[col]; ok {
+						inspectErr = errors.Wrap(ErrDuplicateColumn, "", j.MKV{"name": typ, "col": col, "fields": prev + ", " + name})
 					}
-					// Skip ID fields for updaters (since they are hardcoded)
+					cols[col] = name
+				}
+
+				if name == *idField || slices.Contains(opts, tagOptPrimary) {
+					// Skip primary fields (since they are hardcoded)
+					primaries = append(primaries, Field{Name: name, Col: col, Type: types.ExprString(f.Type)})
 					continue
 				}
 
-				col := toSnakeCase(name)
-				if f.Tag != nil && strings.HasPrefix(f.Tag.Value, tagPrefix) {
-					col = reflect.StructTag(f.Tag.Value[1 : len(f.Tag.Value)-1]).Get(Tag) // Delete first and last quotation
+				_, isPtr := f.Type.(*ast.StarExpr)
+				fieldType := strings.TrimPrefix(types.ExprString(f.Type), "*")
+				field := Field{
+					Col:      col,
+					Name:     name,
+					Pointer:  isPtr,
+					Duration: fieldType == "time.Duration",
+				}
+
+				isTimestamp := !*noTimestamps && (col == *createdCol || col == *updatedCol)
+				if slices.Contains(opts, tagOptUnix) || (*unixTimestamps && isTimestamp) {
+					if fieldType != "time.Time" {
+						inspectErr = errors.New("Unix fields must be time.Time", j.MKV{"name": typ, "field": name})
+					}
+					field.Unix = true
+				}
+
+				if isCollection(f.Type) {
+					field.Encoding = encodingJSON
+				}
+				if slices.Contains(opts, tagOptJSON) {
+					field.Encoding = encodingJSON
+				}
+				for _, o := range opts {
+					enc, ok := strings.CutPrefix(o, tagOptEncoding)
+					if !ok {
+						continue
+					}
+					if enc != encodingJSON && enc != encodingGob {
+						inspectErr = errors.New("Unknown field encoding", j.MKV{"name": typ, "field": name, "encoding": enc})
+					}
+					field.Encoding = enc
+				}
+
+				if slices.Contains(opts, tagOptOmitEmpty) {
+					if !isU {
+						inspectErr = errors.New("Omitempty fields only supported by updaters", j.MKV{"name": typ, "field": name})
+					}
+					if *dialect == dialectPostgres {
+						inspectErr = errors.New("Omitempty fields not supported by the postgres dialect", j.MKV{"name": typ, "field": name})
+					}
+					field.OmitEmpty = true
+					field.NonZero = nonZeroExpr("一."+name, f.Type)
+				}
+
+				if slices.Contains(opts, tagOptCAS) {
+					if !isU && !isD {
+						inspectErr = errors.New("Compare-and-swap fields only supported by updaters and deleters", j.MKV{"name": typ, "field": name})
+					}
+					st.CASFields = append(st.CASFields, field)
+					continue
 				}
 
-				if col == "created_at" {
+				if slices.Contains(opts, tagOptVersion) {
+					if !isU {
+						inspectErr = errors.New("Version fields only supported by updaters", j.MKV{"name": typ, "field": name})
+					}
+					if st.VersionField != nil {
+						inspectErr = errors.New("Multiple version fields", j.MKV{"name": typ, "field": name})
+					}
+					field.Type = types.ExprString(f.Type)
+					st.VersionField = &field
+					continue
+				}
+
+				if col == *createdCol && !*noTimestamps {
 					st.CustomCreatedAt = true
+					st.CreatedAtField = name
 				}
 
-				if col == "updated_at" {
+				if col == *updatedCol && !*noTimestamps {
 					st.CustomUpdatedAt = true
+					st.UpdatedAtField = name
 				}
 
-				field := Field{
-					Col:  col,
-					Name: name,
-				}
 				st.Fields = append(st.Fields, field)
 			}
+			if err := setPrimary(&st, primaries, isI || isP); err != nil {
+				inspectErr = errors.Wrap(err, "", j.MKV{"name": typ})
+			}
 			if isU {
 				if !st.HasID {
-					inspectErr = errors.New("Updater must contain ID field", j.MKV{"field": typ})
+					inspectErr = errors.New("Updater must contain ID field", j.MKV{"field": typ, "id_field": *idField})
 				}
 				data.Updaters = append(data.Updaters, st)
+				if slices.Contains(forces, typ) {
+					data.ForceUpdaters = append(data.ForceUpdaters, st)
+				}
 				ups[typ] = false
-			} else {
+			}
+			if isG {
+				if !st.HasID {
+					inspectErr = errors.New("Getter must contain ID field", j.MKV{"field": typ, "id_field": *idField})
+				}
+				if len(st.KeyFields) > 0 {
+					inspectErr = errors.New("Getter doesn't support composite primary keys", j.MKV{"field": typ})
+				}
+				data.Getters = append(data.Getters, st)
+				gets[typ] = false
+			}
+			if isL {
+				if !st.HasID {
+					inspectErr = errors.New("Loader must contain ID field", j.MKV{"field": typ, "id_field": *idField})
+				}
+				if len(st.KeyFields) > 0 {
+					inspectErr = errors.New("Loader doesn't support composite primary keys", j.MKV{"field": typ})
+				}
+				if !slices.ContainsFunc(st.Fields, func(f Field) bool { return f.Col == statusField }) {
+					inspectErr = errors.New("Loader must contain status field", j.MKV{"field": typ, "status_field": statusField})
+				}
+				data.Loaders = append(data.Loaders, st)
+				lds[typ] = false
+			}
+			if isD {
+				if !st.HasID {
+					inspectErr = errors.New("Deleter must contain ID field", j.MKV{"field": typ, "id_field": *idField})
+				}
+				if len(st.Fields) > 0 {
+					inspectErr = errors.New("Deleter may only contain ID and compare-and-swap fields", j.MKV{"field": typ})
+				}
+				data.Deleters = append(data.Deleters, st)
+				dels[typ] = false
+			}
+			if isP {
+				if !st.HasID && (st.IDType == "string" || !*autoIncrement) {
+					inspectErr = errors.Wrap(ErrInsertMissingID, "", j.MKV{"name": typ})
+				}
+				data.Upserters = append(data.Upserters, st)
+				upss[typ] = false
+			}
+			if isI {
+				if !st.HasID && (st.IDType == "string" || !*autoIncrement) {
+					inspectErr = errors.Wrap(ErrInsertMissingID, "", j.MKV{"name": typ})
+				}
 				data.Inserters = append(data.Inserters, st)
+				if slices.Contains(batchInserters, typ) {
+					// Auto increment ids of a multi-row insert are only consecutive
+					// with specific server settings, so only postgres' returning
+					// clause reliably provides the inserted ids.
+					if !st.HasID && *dialect != dialectPostgres {
+						inspectErr = errors.Wrap(ErrBatchInsertMissingID, "", j.MKV{"name": typ, "dialect": *dialect})
+					}
+					data.BatchInserters = append(data.BatchInserters, st)
+				}
 				ins[typ] = false
 			}
 
@@ -291,21 +856,125 @@ func generateSrc(pkgPath, table string, inserters, updaters []string, statusFiel
 			return nil, errors.New("Couldn't find updater", j.MKV{"name": up})
 		}
 	}
+	for g, missing := range gets {
+		if missing {
+			return nil, errors.New("Couldn't find getter", j.MKV{"name": g})
+		}
+	}
+	for l, missing := range lds {
+		if missing {
+			return nil, errors.New("Couldn't find loader", j.MKV{"name": l})
+		}
+	}
+	for d, missing := range dels {
+		if missing {
+			return nil, errors.New("Couldn't find deleter", j.MKV{"name": d})
+		}
+	}
+	for u, missing := range upss {
+		if missing {
+			return nil, errors.New("Couldn't find upserter", j.MKV{"name": u})
+		}
+	}
 
-	if err = ensureMatchingIDType(data.Inserters, data.Updaters); err != nil {
+	// Catch typos in a custom ID field name, it is otherwise treated as a normal column.
+	if *idField != defaultIDField && !hasID(data) {
+		return nil, errors.Wrap(ErrIDFieldNotFound, "", j.MKV{"id_field": *idField})
+	}
+
+	if err = ensureMatchingKeys(append(data.Updaters, data.Deleters...)); err != nil {
+		return nil, err
+	}
+	if err = ensureMatchingIDType(append(data.Inserters, data.Upserters...), append(append(append(data.Updaters, data.Getters...), data.Loaders...), data.Deleters...)); err != nil {
 		return nil, err
 	}
 
+	if *pkgName != "" {
+		data.Package = *pkgName
+	}
+
+	if *schemaFile != "" {
+		if err = validateSchema(data); err != nil {
+			return nil, err
+		}
+	}
+
+	t := tpl
+	if *templateFile != "" {
+		b, err := os.ReadFile(*templateFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed reading template", j.MKV{"template": *templateFile})
+		}
+		t = string(b)
+	}
+
 	var out bytes.Buffer
-	if err = execTpl(&out, tpl, data); err != nil {
+	if err = writeHeader(&out); err != nil {
+		return nil, err
+	}
+	if err = execTpl(&out, t, data); err != nil {
 		return nil, errors.Wrap(err, "Failed executing template")
 	}
 	return imports.Process(filePath, out.Bytes(), nil)
 }
 
+// writeHeader writes the -header file contents and the -build_tags constraint
+// that precede the package clause of the generated code, if any.
+func writeHeader(out io.Writer) error {
+	if *headerFile != "" {
+		b, err := os.ReadFile(*headerFile)
+		if err != nil {
+			return errors.Wrap(err, "Failed reading header", j.MKV{"header": *headerFile})
+		}
+		out.Write(bytes.TrimRight(b, "\n"))
+		io.WriteString(out, "\n\n")
+	}
+	if *buildTags != "" {
+		expr, err := constraint.Parse("//go:build " + *buildTags)
+		if err != nil {
+			return errors.Wrap(err, "Invalid build tags", j.MKV{"build_tags": *buildTags})
+		}
+		io.WriteString(out, "//go:build "+expr.String()+"\n\n")
+	}
+	return nil
+}
+
 func execTpl(out io.Writer, tpl string, data Data) error {
+	var n int // Positional placeholder counter, reset per query
 	t := template.New("").Funcs(map[string]interface{}{
-		"col": quoteCol,
+		"col":              quoteCol,
+		"mysql":            func() bool { return *dialect == dialectMySQL },
+		"postgres":         func() bool { return *dialect == dialectPostgres },
+		"sqlite":           func() bool { return *dialect == dialectSQLite },
+		"classifyRowCount": func() bool { return *classifyRowCount },
+		"queryTimeout":     queryTimeoutStmt,
+		"idempotentInsert": func() bool { return *idempotentInsert },
+		"resetPh": func() string {
+			n = 0
+			return ""
+		},
+		"ph": func() string {
+			n++
+			return placeholder(n)
+		},
+		"placeholders": func(count int) string {
+			ph := make([]string, count)
+			for i := range ph {
+				ph[i] = placeholder(i + 1)
+			}
+			return strings.Join(ph, ", ")
+		},
+		"now":         now,
+		"nowTime":     nowTime,
+		"createdCol":  func() string { return *createdCol },
+		"updatedCol":  func() string { return *updatedCol },
+		"softDelete":  func() string { return *softDelete },
+		"nullSafeEq":  nullSafeEq,
+		"sqlConst":    sqlConst,
+		"appendArg":   appendArg,
+		"scanVar":     scanVar,
+		"scanDest":    scanDest,
+		"decodeField": decodeField,
 	})
 
 	tp, err := t.Parse(tpl)
@@ -316,8 +985,284 @@ func execTpl(out io.Writer, tpl string, data Data) error {
 	return tp.Execute(out, data)
 }
 
+// queryTimeoutStmt returns the statements wrapping the context of a generated
+// method with the -query_timeout, or an empty string if it is disabled.
+func queryTimeoutStmt() string {
+	if *queryTimeout <= 0 {
+		return ""
+	}
+	return "\tctx, cancel := context.WithTimeout(ctx, " + durationExpr(*queryTimeout) + ")\n\tdefer cancel()\n\n"
+}
+
+// durationExpr returns the Go expression of the duration in its largest whole
+// unit, ex. 5*time.Second.
+func durationExpr(d time.Duration) string {
+	units := []struct {
+		d    time.Duration
+		name string
+	}{
+		{time.Hour, "time.Hour"},
+		{time.Minute, "time.Minute"},
+		{time.Second, "time.Second"},
+		{time.Millisecond, "time.Millisecond"},
+		{time.Microsecond, "time.Microsecond"},
+	}
+	for _, u := range units {
+		if d%u.d == 0 {
+			return strconv.FormatInt(int64(d/u.d), 10) + "*" + u.name
+		}
+	}
+	return strconv.FormatInt(int64(d), 10) + "*time.Nanosecond"
+}
+
+// quoteCol returns the quoted column name, escaped for use in a Go string literal.
 func quoteCol(colName string) string {
-	return *quoteChar + colName + *quoteChar
+	q := *quoteChar
+	if *dialect != dialectMySQL && q == "`" {
+		q = `"`
+	}
+	return strings.ReplaceAll(q+colName+q, `"`, `\"`)
+}
+
+// appendArg returns the code appending the receiver's field value to the query args.
+// Encoded fields are encoded first in a block scoping the error, returning the
+// zero ID and error on failure. Pointer fields are dereferenced or NULL if nil,
+// omitempty pointer fields are only appended if not nil. Duration fields are
+// converted to int64 nanoseconds.
+func appendArg(recv string, f Field, zero string) string {
+	val := recv + "." + f.Name
+	switch f.Encoding {
+	case encodingJSON:
+		return "{\n" +
+			"b, err := json.Marshal(" + val + ")\n" +
+			"if err != nil {\n" +
+			"return " + zero + ", errors.Wrap(err, \"marshal " + f.Col + "\")\n" +
+			"}\n" +
+			"args = append(args, b)\n" +
+			"}"
+	case encodingGob:
+		return "{\n" +
+			"var b bytes.Buffer\n" +
+			"if err := gob.NewEncoder(&b).Encode(" + val + "); err != nil {\n" +
+			"return " + zero + ", errors.Wrap(err, \"encode " + f.Col + "\")\n" +
+			"}\n" +
+			"args = append(args, b.Bytes())\n" +
+			"}"
+	}
+	if f.Unix {
+		val += ".Unix()" // Also valid for pointers.
+	} else if f.Pointer {
+		val = "*" + val
+	}
+	if f.Duration {
+		val = "int64(" + val + ")"
+	}
+	if f.Pointer && !f.OmitEmpty {
+		return "if " + recv + "." + f.Name + " != nil {\n" +
+			"args = append(args, " + val + ")\n" +
+			"} else {\n" +
+			"args = append(args, nil)\n" +
+			"}"
+	}
+	return "args = append(args, " + val + ")"
+}
+
+// now returns the expression of the current time of generated timestamps.
+func now() string {
+	if *unixTimestamps {
+		return *nowFunc + "().Unix()"
+	}
+	return nowTime()
+}
+
+// nowTime returns the expression of the current time as a time.Time, ex. for
+// the soft delete column which isn't affected by -unix_timestamps.
+func nowTime() string {
+	return *nowFunc + "()"
+}
+
+// scanVar returns the declaration of the local variable scanDest scans the
+// field into, or an empty string if it is scanned into the loaded row directly.
+func scanVar(f Field) string {
+	switch {
+	case f.Encoding != "":
+		return "var enc" + f.Name + " []byte"
+	case f.Unix && f.Pointer:
+		return "var unix" + f.Name + " sql.NullInt64"
+	case f.Unix:
+		return "var unix" + f.Name + " int64"
+	}
+	return ""
+}
+
+// scanDest returns the scan destination of the field of the loaded row r,
+// encoded and unix fields are scanned into a local variable decoded by decodeField.
+func scanDest(f Field) string {
+	if f.Encoding != "" {
+		return "&enc" + f.Name
+	}
+	if f.Unix {
+		return "&unix" + f.Name
+	}
+	return "&r." + f.Name
+}
+
+// decodeField returns the code decoding an encoded or unix field scanned by
+// scanDest into the loaded row r, returning the zero values and error on failure.
+// NULL columns leave the field as its zero value.
+func decodeField(f Field, zero string) string {
+	if f.Unix && f.Pointer {
+		return "if unix" + f.Name + ".Valid {\n" +
+			"t := time.Unix(unix" + f.Name + ".Int64, 0)\n" +
+			"r." + f.Name + " = &t\n" +
+			"}\n"
+	} else if f.Unix {
+		return "r." + f.Name + " = time.Unix(unix" + f.Name + ", 0)\n"
+	}
+
+	var decode, msg string
+	switch f.Encoding {
+	case encodingJSON:
+		decode, msg = "json.Unmarshal(enc"+f.Name+", &r."+f.Name+")", "unmarshal "
+	case encodingGob:
+		decode, msg = "gob.NewDecoder(bytes.NewReader(enc"+f.Name+")).Decode(&r."+f.Name+")", "decode "
+	default:
+		return ""
+	}
+	return "if len(enc" + f.Name + ") > 0 {\n" +
+		"if err := " + decode + "; err != nil {\n" +
+		"return " + zero + ", errors.Wrap(err, \"" + msg + f.Col + "\")\n" +
+		"}\n" +
+		"}\n"
+}
+
+// isCollection returns true if the field type is a slice (except []byte) or map.
+func isCollection(typ ast.Expr) bool {
+	switch t := typ.(type) {
+	case *ast.MapType:
+		return true
+	case *ast.ArrayType:
+		elt, ok := t.Elt.(*ast.Ident)
+		return t.Len == nil && !(ok && (elt.Name == "byte" || elt.Name == "uint8"))
+	}
+	return false
+}
+
+// sqlConst returns the name of the generated query constant of the type's method.
+func sqlConst(method, typ string) string {
+	r, n := utf8.DecodeRuneInString(typ)
+	return "sql" + method + string(unicode.ToUpper(r)) + typ[n:]
+}
+
+// placeholder returns the dialect's query placeholder for the nth argument.
+func placeholder(n int) string {
+	if *dialect == dialectPostgres {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+// nullSafeEq returns the dialect's equality operator that treats NULLs as equal.
+func nullSafeEq() string {
+	switch *dialect {
+	case dialectPostgres:
+		return " is not distinct from "
+	case dialectSQLite:
+		return " is "
+	}
+	return "<=>"
+}
+
+// nonZeroExpr returns a Go expression evaluating to true if the field isn't
+// the zero value of its type.
+func nonZeroExpr(field string, typ ast.Expr) string {
+	switch t := typ.(type) {
+	case *ast.Ident:
+		switch t.Name {
+		case "string":
+			return field + ` != ""`
+		case "bool":
+			return field
+		case "int", "int8", "int16", "int32", "int64",
+			"uint", "uint8", "uint16", "uint32", "uint64",
+			"float32", "float64", "byte", "rune":
+			return field + " != 0"
+		}
+	case *ast.SelectorExpr:
+		switch types.ExprString(t) {
+		case "time.Time":
+			return "!" + field + ".IsZero()"
+		case "time.Duration":
+			return field + " != 0"
+		}
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return "len(" + field + ") > 0"
+		}
+	case *ast.MapType:
+		return "len(" + field + ") > 0"
+	case *ast.StarExpr, *ast.InterfaceType, *ast.FuncType, *ast.ChanType:
+		return field + " != nil"
+	}
+	return field + " != *new(" + types.ExprString(typ) + ")"
+}
+
+// setPrimary sets the ID field of the struct from its primary fields. The ID field
+// of a composite primary key is the one named -id_field, the other primary fields
+// are set by inserters and added to the where clause of updaters and deleters.
+func setPrimary(st *Struct, primaries []Field, isInserter bool) error {
+	if len(primaries) == 0 {
+		return nil
+	}
+
+	var id int
+	if len(primaries) > 1 {
+		id = slices.IndexFunc(primaries, func(f Field) bool { return f.Name == *idField })
+		if id < 0 {
+			return errors.New("Composite primary key must contain the ID field", j.MKV{"id_field": *idField})
+		}
+	}
+
+	if !slices.Contains([]string{"int64", "uint64", "string"}, primaries[id].Type) {
+		return errors.Wrap(ErrInvalidPrimaryType, "", j.MKV{"field": primaries[id].Name, "type": primaries[id].Type})
+	}
+	st.HasID = true
+	st.IDField = primaries[id].Name
+	st.IDCol = primaries[id].Col
+	st.IDType = primaries[id].Type
+
+	keys := append(primaries[:id:id], primaries[id+1:]...)
+	if isInserter {
+		st.Fields = append(keys, st.Fields...)
+	} else {
+		st.KeyFields = keys
+	}
+	return nil
+}
+
+// ensureMatchingKeys returns an error if any of the structs have a different
+// composite primary key.
+func ensureMatchingKeys(structs []Struct) error {
+	for _, s := range structs {
+		if !slices.EqualFunc(s.KeyFields, structs[0].KeyFields, func(a, b Field) bool {
+			return a.Col == b.Col && a.Type == b.Type
+		}) {
+			return ErrKeyMismatch
+		}
+	}
+	return nil
+}
+
+// hasID returns true if any of the structs contain an ID field.
+func hasID(data Data) bool {
+	for _, ss := range [][]Struct{data.Inserters, data.Updaters, data.Getters, data.Loaders, data.Deleters, data.Upserters} {
+		for _, s := range ss {
+			if s.HasID {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // ensureMatchingIDType returns an error if any of the inserters or updates have
@@ -334,13 +1279,70 @@ func ensureMatchingIDType(inserters, updaters []Struct) error {
 	return nil
 }
 
-var (
-	matchFirstCap = regexp.MustCompile("(.)([A-Z][a-z]+)")
-	matchAllCap   = regexp.MustCompile("([a-z0-9])([A-Z])")
-)
+// structTable returns the table of a struct type overridden by a
+// "shift:table=name" line in its doc comment, or the default table.
+func structTable(doc *ast.CommentGroup, table string) string {
+	if doc == nil {
+		return table
+	}
+	for _, c := range doc.List {
+		t, ok := strings.CutPrefix(strings.TrimSpace(strings.TrimPrefix(c.Text, "//")), tableDirective)
+		if ok && strings.TrimSpace(t) != "" {
+			return strings.TrimSpace(t)
+		}
+	}
+	return table
+}
+
+// parseTag splits a shift struct tag into the column name and its options.
+// The default column name is returned if the tag doesn't specify one.
+func parseTag(tag, defCol string) (string, []string) {
+	parts := strings.Split(tag, ",")
+	col := strings.TrimSpace(parts[0])
+	if col == "" {
+		col = defCol
+	}
+	var opts []string
+	for _, o := range parts[1:] {
+		opts = append(opts, strings.TrimSpace(o))
+	}
+	return col, opts
+}
 
+// toSnakeCase returns the snake case column name of a Go field name, keeping
+// acronyms together, ex. UserID -> user_id, HTTPPort -> http_port and
+// UserIDs -> user_ids. A single capital prefixing a word isn't split, so
+// OAuthToken -> oauth_token. Digits don't start a new word, ex. Address2 -> address2.
 func toSnakeCase(col string) string {
-	snake := matchFirstCap.ReplaceAllString(col, "${1}_${2}")
-	snake = matchAllCap.ReplaceAllString(snake, "${1}_${2}")
-	return strings.ToLower(snake)
+	rs := []rune(col)
+	isUpper := func(i int) bool { return i >= 0 && i < len(rs) && unicode.IsUpper(rs[i]) }
+	isLower := func(i int) bool { return i >= 0 && i < len(rs) && unicode.IsLower(rs[i]) }
+
+	var b strings.Builder
+	for i, r := range rs {
+		if i > 0 && isUpper(i) && wordStart(rs, i, isUpper, isLower) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// wordStart returns true if the capital at i starts a new word.
+func wordStart(rs []rune, i int, isUpper, isLower func(int) bool) bool {
+	if !isUpper(i - 1) {
+		// After a lowercase letter or digit, ex. userID.
+		return true
+	}
+	if !isLower(i + 1) {
+		// Within an acronym, ex. HTTP.
+		return false
+	}
+	if rs[i+1] == 's' && !isLower(i+2) {
+		// Plural acronym, ex. IDs.
+		return false
+	}
+	// The last capital of an acronym followed by a word, ex. HTTPPort,
+	// unless the acronym is a single capital, ex. OAuth.
+	return isUpper(i - 2)
 }
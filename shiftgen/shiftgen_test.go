@@ -1,6 +1,9 @@
 package main
 
 import (
+	"flag"
+	"go/constant"
+	"go/parser"
 	"os"
 	"path/filepath"
 	"testing"
@@ -16,8 +19,14 @@ func TestGen(t *testing.T) {
 		table     string
 		inserters []string
 		updaters  []string
+		getters   []string
+		loaders   []string
+		deleters  []string
+		upserters []string
+		batches   []string
 		stringID  bool
 		outFile   string
+		flags     map[string]string
 	}{
 		{
 			dir:       "case_basic",
@@ -26,6 +35,31 @@ func TestGen(t *testing.T) {
 			updaters:  []string{"update", "complete"},
 			outFile:   "shift_gen.go",
 		},
+		{
+			dir:       "case_uint64",
+			table:     "users",
+			inserters: []string{"insert"},
+			updaters:  []string{"update"},
+			loaders:   []string{"user"},
+			outFile:   "shift_gen.go",
+			flags:     map[string]string{"primary_type": "uint64"},
+		},
+		{
+			dir:       "case_uint64_postgres",
+			table:     "users",
+			inserters: []string{"insert"},
+			updaters:  []string{"update"},
+			outFile:   "shift_gen.go",
+			flags:     map[string]string{"primary_type": "uint64", "dialect": "postgres"},
+		},
+		{
+			dir:       "case_force_updaters",
+			table:     "users",
+			inserters: []string{"insert"},
+			updaters:  []string{"update", "repair"},
+			outFile:   "shift_gen.go",
+			flags:     map[string]string{"force_updaters": "repair"},
+		},
 		{
 			dir:       "case_specify_times",
 			table:     "foo",
@@ -48,6 +82,304 @@ func TestGen(t *testing.T) {
 			stringID:  true,
 			outFile:   "shift_gen.go",
 		},
+		{
+			dir:       "case_cas",
+			table:     "jobs",
+			inserters: []string{"insert"},
+			updaters:  []string{"claim", "release"},
+			outFile:   "shift_gen.go",
+		},
+		{
+			dir:       "case_version",
+			table:     "users",
+			inserters: []string{"insert"},
+			updaters:  []string{"update", "rename"},
+			outFile:   "shift_gen.go",
+			flags:     map[string]string{"classify_row_count": "true"},
+		},
+		{
+			dir:      "case_version_status",
+			table:    "users",
+			updaters: []string{"update", "rename"},
+			outFile:  "shift_gen.go",
+		},
+		{
+			dir:       "case_updated_at_on_change",
+			table:     "users",
+			inserters: []string{"insert"},
+			updaters:  []string{"update", "complete", "rename"},
+			outFile:   "shift_gen.go",
+			flags:     map[string]string{"updated_at_on_change": "true"},
+		},
+		{
+			dir:       "case_omitempty",
+			table:     "users",
+			inserters: []string{"insert"},
+			updaters:  []string{"update"},
+			outFile:   "shift_gen.go",
+		},
+		{
+			dir:       "case_no_auto_increment",
+			table:     "users",
+			inserters: []string{"insert"},
+			updaters:  []string{"complete"},
+			outFile:   "shift_gen.go",
+			flags:     map[string]string{"auto_increment": "false"},
+		},
+		{
+			dir:       "case_primary_tag",
+			table:     "accounts",
+			inserters: []string{"insert"},
+			updaters:  []string{"update"},
+			getters:   []string{"account"},
+			outFile:   "shift_gen.go",
+		},
+		{
+			dir:       "case_id_field",
+			table:     "users",
+			inserters: []string{"insert"},
+			updaters:  []string{"update", "complete"},
+			outFile:   "shift_gen.go",
+			flags:     map[string]string{"id_field": "UserID"},
+		},
+		{
+			dir:       "case_postgres",
+			table:     "users",
+			inserters: []string{"insert"},
+			updaters:  []string{"update", "complete"},
+			getters:   []string{"user"},
+			outFile:   "shift_gen.go",
+			flags:     map[string]string{"dialect": "postgres"},
+		},
+		{
+			dir:       "case_sqlite",
+			table:     "users",
+			inserters: []string{"insert"},
+			updaters:  []string{"update", "complete", "claim"},
+			outFile:   "shift_gen.go",
+			flags:     map[string]string{"dialect": "sqlite"},
+		},
+		{
+			dir:       "case_deleters",
+			table:     "jobs",
+			inserters: []string{"insert"},
+			deleters:  []string{"remove", "release"},
+			outFile:   "shift_gen.go",
+		},
+		{
+			dir:       "case_shared_roles",
+			table:     "users",
+			inserters: []string{"insert"},
+			updaters:  []string{"release"},
+			getters:   []string{"user"},
+			loaders:   []string{"user"},
+			deleters:  []string{"release"},
+			outFile:   "shift_gen.go",
+		},
+		{
+			dir:       "case_soft_delete",
+			table:     "users",
+			inserters: []string{"insert"},
+			updaters:  []string{"update", "rename"},
+			getters:   []string{"user"},
+			loaders:   []string{"user"},
+			deleters:  []string{"remove"},
+			outFile:   "shift_gen.go",
+			flags:     map[string]string{"soft_delete": "deleted_at"},
+		},
+		{
+			dir:       "case_soft_delete_unix",
+			table:     "users",
+			inserters: []string{"insert"},
+			deleters:  []string{"remove"},
+			outFile:   "shift_gen.go",
+			flags:     map[string]string{"soft_delete": "deleted_at", "unix_timestamps": "true"},
+		},
+		{
+			dir:       "case_template",
+			table:     "users",
+			inserters: []string{"insert"},
+			updaters:  []string{"update"},
+			outFile:   "shift_gen.go",
+			flags:     map[string]string{"template": "testdata/case_template/shift_gen.tmpl"},
+		},
+		{
+			dir:       "case_query_timeout",
+			table:     "users",
+			inserters: []string{"insert"},
+			updaters:  []string{"update", "rename"},
+			getters:   []string{"user"},
+			deleters:  []string{"remove"},
+			upserters: []string{"upsert"},
+			outFile:   "shift_gen.go",
+			flags:     map[string]string{"query_timeout": "5s", "force_updaters": "update"},
+		},
+		{
+			dir:       "case_table_override",
+			table:     "users",
+			inserters: []string{"insert"},
+			updaters:  []string{"update", "complete"},
+			outFile:   "shift_gen.go",
+		},
+		{
+			dir:       "case_idempotent_insert",
+			table:     "users",
+			inserters: []string{"insert", "insertWithID"},
+			updaters:  []string{"update"},
+			outFile:   "shift_gen.go",
+			flags:     map[string]string{"idempotent_insert": "true"},
+		},
+		{
+			dir:       "case_unix_timestamps",
+			table:     "users",
+			inserters: []string{"insert"},
+			updaters:  []string{"update"},
+			loaders:   []string{"user"},
+			outFile:   "shift_gen.go",
+			flags:     map[string]string{"unix_timestamps": "true"},
+		},
+		{
+			dir:       "case_header",
+			table:     "users",
+			inserters: []string{"insert"},
+			updaters:  []string{"update"},
+			outFile:   "shift_gen.go",
+			flags: map[string]string{
+				"header":     "testdata/case_header/header.txt",
+				"build_tags": "integration && !race",
+			},
+		},
+		{
+			dir:       "case_schema",
+			table:     "users",
+			inserters: []string{"insert"},
+			updaters:  []string{"update"},
+			outFile:   "shift_gen.go",
+			flags:     map[string]string{"schema": "testdata/case_schema/schema.sql"},
+		},
+		{
+			dir:       "case_composite_key",
+			table:     "accounts",
+			inserters: []string{"insert"},
+			updaters:  []string{"update"},
+			deleters:  []string{"remove"},
+			outFile:   "shift_gen.go",
+		},
+		{
+			dir:       "case_upserters",
+			table:     "users",
+			upserters: []string{"upsert", "upsertByEmail"},
+			outFile:   "shift_gen.go",
+		},
+		{
+			dir:       "case_batch_insert",
+			table:     "users",
+			inserters: []string{"insert", "insertWithID"},
+			batches:   []string{"insertWithID"},
+			outFile:   "shift_gen.go",
+		},
+		{
+			dir:       "case_batch_insert_postgres",
+			table:     "users",
+			inserters: []string{"insert"},
+			batches:   []string{"insert"},
+			outFile:   "shift_gen.go",
+			flags:     map[string]string{"dialect": "postgres"},
+		},
+		{
+			dir:       "case_now_func",
+			table:     "users",
+			inserters: []string{"insert"},
+			updaters:  []string{"update"},
+			outFile:   "shift_gen.go",
+			flags:     map[string]string{"now_func": "clockNow"},
+		},
+		{
+			dir:       "case_no_timestamps",
+			table:     "users",
+			inserters: []string{"insert"},
+			updaters:  []string{"update", "complete"},
+			upserters: []string{"upsert"},
+			outFile:   "shift_gen.go",
+			flags:     map[string]string{"no_timestamps": "true"},
+		},
+		{
+			dir:       "case_timestamp_cols",
+			table:     "users",
+			inserters: []string{"insert"},
+			updaters:  []string{"update", "complete"},
+			outFile:   "shift_gen.go",
+			flags:     map[string]string{"created_col": "inserted_at", "updated_col": "modified_at"},
+		},
+		{
+			dir:      "case_loaders",
+			table:    "users",
+			loaders:  []string{"user"},
+			updaters: []string{"complete"},
+			outFile:  "shift_gen.go",
+		},
+		{
+			dir:      "case_loaders_string",
+			table:    "users",
+			loaders:  []string{"user"},
+			updaters: []string{"complete"},
+			outFile:  "shift_gen.go",
+		},
+		{
+			dir:       "case_pointers",
+			table:     "users",
+			inserters: []string{"insert"},
+			updaters:  []string{"update"},
+			loaders:   []string{"user"},
+			outFile:   "shift_gen.go",
+		},
+		{
+			dir:       "case_json",
+			table:     "users",
+			inserters: []string{"insert"},
+			updaters:  []string{"update"},
+			outFile:   "shift_gen.go",
+		},
+		{
+			dir:       "case_encoding",
+			table:     "users",
+			inserters: []string{"insert"},
+			updaters:  []string{"update"},
+			getters:   []string{"account"},
+			loaders:   []string{"user"},
+			outFile:   "shift_gen.go",
+		},
+		{
+			dir:       "case_package",
+			table:     "users",
+			inserters: []string{"insert"},
+			updaters:  []string{"update"},
+			outFile:   "shift_gen.go",
+			flags:     map[string]string{"package": "gen"},
+		},
+		{
+			dir:       "case_classify_row_count",
+			table:     "users",
+			inserters: []string{"insert"},
+			updaters:  []string{"update", "rename"},
+			outFile:   "shift_gen.go",
+			flags:     map[string]string{"classify_row_count": "true"},
+		},
+		{
+			dir:       "case_duration",
+			table:     "users",
+			inserters: []string{"insert"},
+			updaters:  []string{"update", "extend"},
+			loaders:   []string{"user"},
+			outFile:   "shift_gen.go",
+		},
+		{
+			dir:      "case_getters",
+			table:    "users",
+			getters:  []string{"user"},
+			updaters: []string{"complete"},
+			outFile:  "shift_gen.go",
+		},
 	}
 
 	for _, c := range cc {
@@ -56,10 +388,11 @@ func TestGen(t *testing.T) {
 			jtest.RequireNil(t, err)
 			err = os.Setenv("GOLINE", "123")
 			jtest.RequireNil(t, err)
+			setFlags(t, c.flags)
 
 			bb, err := generateSrc(
 				filepath.Join("testdata", c.dir),
-				c.table, c.inserters, c.updaters, "status",
+				c.table, c.inserters, c.updaters, c.getters, c.deleters, c.upserters, c.batches, c.loaders, "status",
 				filepath.Join("testdata", c.dir, c.outFile))
 
 			jtest.RequireNil(t, err)
@@ -71,8 +404,9 @@ func TestGen(t *testing.T) {
 
 func TestMermaid(t *testing.T) {
 	cc := []struct {
-		dir     string
-		outFile string
+		dir       string
+		outFile   string
+		direction mermaidDirection
 	}{
 		{
 			dir:     "case_mermaid",
@@ -82,16 +416,24 @@ func TestMermaid(t *testing.T) {
 			dir:     "case_mermaid_arcfsm",
 			outFile: "shift_gen.mmd",
 		},
+		{
+			dir:       "case_mermaid",
+			outFile:   "shift_gen_tb.mmd",
+			direction: topToBottomDirection,
+		},
 	}
 
 	for _, c := range cc {
-		t.Run(c.dir, func(t *testing.T) {
+		t.Run(c.dir+"/"+c.outFile, func(t *testing.T) {
+			if c.direction == unknownDirection {
+				c.direction = leftToRightDirection
+			}
 			err := os.Setenv("GOFILE", "shiftgen_test.go")
 			jtest.RequireNil(t, err)
 			err = os.Setenv("GOLINE", "123")
 			jtest.RequireNil(t, err)
 
-			bb, err := generateMermaidDiagram(filepath.Join("testdata", c.dir))
+			bb, err := generateMermaidDiagram(filepath.Join("testdata", c.dir), c.direction)
 
 			jtest.RequireNil(t, err)
 			g := goldie.New(t)
@@ -100,14 +442,132 @@ func TestMermaid(t *testing.T) {
 	}
 }
 
+func TestDot(t *testing.T) {
+	for _, dir := range []string{"case_mermaid", "case_mermaid_arcfsm"} {
+		t.Run(dir, func(t *testing.T) {
+			t.Setenv("GOFILE", "shiftgen_test.go")
+			t.Setenv("GOLINE", "123")
+
+			gv, err := generateDotDiagram(filepath.Join("testdata", dir))
+			jtest.RequireNil(t, err)
+
+			g := goldie.New(t)
+			g.Assert(t, filepath.Join(dir, "shift_gen.dot"), []byte(gv))
+		})
+	}
+}
+
+func TestJSON(t *testing.T) {
+	for _, dir := range []string{"case_mermaid", "case_mermaid_arcfsm"} {
+		t.Run(dir, func(t *testing.T) {
+			desc, err := generateJSONDescription(filepath.Join("testdata", dir))
+			jtest.RequireNil(t, err)
+
+			g := goldie.New(t)
+			g.Assert(t, filepath.Join(dir, "shift_gen.json"), []byte(desc))
+		})
+	}
+}
+
+func TestEvalConst(t *testing.T) {
+	consts := map[string]constant.Value{"base": constant.MakeInt64(10)}
+	cases := map[string]int64{
+		"1":             1,
+		"iota":          2,
+		"iota + 1":      3,
+		"base + iota*2": 14,
+		"status(5)":     5,
+		"-(base - 3)":   -7,
+		"1 << iota":     4,
+		"base | 1":      11,
+	}
+	for src, exp := range cases {
+		expr, err := parser.ParseExpr(src)
+		jtest.RequireNil(t, err)
+		v, ok := constant.Int64Val(evalConst(expr, 2, consts))
+		require.True(t, ok, src)
+		require.Equal(t, exp, v, src)
+	}
+
+	expr, err := parser.ParseExpr(`"a"`)
+	jtest.RequireNil(t, err)
+	require.Equal(t, constant.Unknown, evalConst(expr, 0, consts).Kind())
+}
+
+func TestParseMermaidDirection(t *testing.T) {
+	for _, s := range []string{"TB", "LR", "RL", "BT"} {
+		d, err := parseMermaidDirection(s)
+		jtest.RequireNil(t, err)
+		require.Equal(t, mermaidDirection(s), d)
+	}
+
+	_, err := parseMermaidDirection("lr")
+	jtest.Require(t, ErrUnknownDirection, err)
+}
+
+func TestToSnakeCase(t *testing.T) {
+	cases := map[string]string{
+		"ID":          "id",
+		"Name":        "name",
+		"DateOfBirth": "date_of_birth",
+		"UserID":      "user_id",
+		"UserIDs":     "user_ids",
+		"IDsCount":    "ids_count",
+		"HTTPPort":    "http_port",
+		"XMLHTTPURL":  "xmlhttpurl",
+		"OAuthToken":  "oauth_token",
+		"UserOAuth":   "user_oauth",
+		"APIKey":      "api_key",
+		"IsOK":        "is_ok",
+		"Address2":    "address2",
+		"Address2Zip": "address2_zip",
+		"U5":          "u5",
+		"SHA256Sum":   "sha256_sum",
+		"lowerCase":   "lower_case",
+	}
+	for in, exp := range cases {
+		require.Equal(t, exp, toSnakeCase(in), in)
+	}
+}
+
+func TestParseSchema(t *testing.T) {
+	schema := `
+create table if not exists ` + "`users`" + ` (
+  ` + "`id`" + ` bigint not null auto_increment,
+  name varchar(255) not null,
+  amount decimal(10, 2),
+  primary key (id),
+  key by_name (name)
+);
+
+CREATE TEMPORARY TABLE db.events (
+  id bigint not null,
+  foreign_id bigint not null,
+  PRIMARY KEY (id)
+);
+
+create index by_name on users (name);
+`
+	require.Equal(t, map[string][]string{
+		"users":  {"id", "name", "amount"},
+		"events": {"id", "foreign_id"},
+	}, parseSchema(schema))
+}
+
 func TestGenFailure(t *testing.T) {
 	cc := []struct {
 		dir       string
 		table     string
 		inserters []string
 		updaters  []string
+		getters   []string
+		loaders   []string
+		deleters  []string
+		upserters []string
+		batches   []string
 		stringID  bool
 		outFile   string
+		flags     map[string]string
 		outErr    error
 	}{
 		{
@@ -118,6 +578,35 @@ func TestGenFailure(t *testing.T) {
 			outFile:   "shift_gen.go",
 			outErr:    ErrIDTypeMismatch,
 		},
+		{
+			dir:       "case_duplicate_column",
+			table:     "users",
+			inserters: []string{"insert"},
+			outFile:   "shift_gen.go",
+			outErr:    ErrDuplicateColumn,
+		},
+		{
+			dir:      "case_duplicate_column",
+			table:    "users",
+			updaters: []string{"update"},
+			outFile:  "shift_gen.go",
+			outErr:   ErrDuplicateColumn,
+		},
+		{
+			dir:       "case_no_table",
+			inserters: []string{"insert"},
+			updaters:  []string{"update"},
+			outFile:   "shift_gen.go",
+			outErr:    ErrNoTable,
+		},
+		{
+			dir:       "case_schema_mismatch",
+			table:     "users",
+			inserters: []string{"insert"},
+			outFile:   "shift_gen.go",
+			flags:     map[string]string{"schema": "testdata/failure/case_schema_mismatch/schema.sql"},
+			outErr:    ErrUnknownColumns,
+		},
 		{
 			dir:       "case_id_update_mismatch",
 			table:     "users",
@@ -126,16 +615,111 @@ func TestGenFailure(t *testing.T) {
 			outFile:   "shift_gen.go",
 			outErr:    ErrIDTypeMismatch,
 		},
+		{
+			dir:       "case_primary_type_string",
+			table:     "users",
+			inserters: []string{"insert"},
+			updaters:  []string{"complete"},
+			outFile:   "shift_gen.go",
+			flags:     map[string]string{"primary_type": "string"},
+			outErr:    ErrInsertMissingID,
+		},
+		{
+			dir:       "case_primary_type_string",
+			table:     "users",
+			inserters: []string{"insert"},
+			updaters:  []string{"complete"},
+			outFile:   "shift_gen.go",
+			flags:     map[string]string{"primary_type": "uint"},
+			outErr:    ErrInvalidPrimaryType,
+		},
+		{
+			dir:       "case_no_auto_increment",
+			table:     "users",
+			inserters: []string{"insert"},
+			updaters:  []string{"complete"},
+			outFile:   "shift_gen.go",
+			flags:     map[string]string{"auto_increment": "false"},
+			outErr:    ErrInsertMissingID,
+		},
+		{
+			dir:       "case_batch_insert_missing_id",
+			table:     "users",
+			inserters: []string{"insert"},
+			batches:   []string{"insert"},
+			outFile:   "shift_gen.go",
+			outErr:    ErrBatchInsertMissingID,
+		},
+		{
+			dir:       "case_batch_insert_missing_id",
+			table:     "users",
+			inserters: []string{"insert"},
+			batches:   []string{"insert"},
+			outFile:   "shift_gen.go",
+			flags:     map[string]string{"dialect": "sqlite"},
+			outErr:    ErrBatchInsertMissingID,
+		},
+		{
+			dir:       "case_insert_role_overlap",
+			table:     "users",
+			inserters: []string{"user"},
+			updaters:  []string{"user"},
+			outFile:   "shift_gen.go",
+			outErr:    ErrInsertRoleOverlap,
+		},
+		{
+			dir:       "case_id_int32",
+			table:     "users",
+			inserters: []string{"insert"},
+			outFile:   "shift_gen.go",
+			outErr:    ErrInvalidPrimaryType,
+		},
+		{
+			dir:       "case_id_field_typo",
+			table:     "users",
+			inserters: []string{"insert"},
+			outFile:   "shift_gen.go",
+			flags:     map[string]string{"id_field": "UserId"},
+			outErr:    ErrIDFieldNotFound,
+		},
+		{
+			dir:       "case_primary_type_string",
+			table:     "users",
+			inserters: []string{"insert"},
+			updaters:  []string{"complete"},
+			outFile:   "shift_gen.go",
+			flags:     map[string]string{"dialect": "oracle"},
+			outErr:    ErrUnknownDialect,
+		},
+		{
+			dir:      "case_composite_key_mismatch",
+			table:    "accounts",
+			updaters: []string{"update", "complete"},
+			outFile:  "shift_gen.go",
+			outErr:   ErrKeyMismatch,
+		},
 	}
 
 	for _, c := range cc {
 		t.Run(c.dir, func(t *testing.T) {
+			setFlags(t, c.flags)
 			_, err := generateSrc(
 				filepath.Join("testdata", "failure", c.dir),
-				c.table, c.inserters, c.updaters, "status",
+				c.table, c.inserters, c.updaters, c.getters, c.deleters, c.upserters, c.batches, c.loaders, "status",
 				filepath.Join("testdata", "failure", c.dir, c.outFile))
 
-			require.EqualError(t, err, c.outErr.Error())
+			jtest.Require(t, c.outErr, err)
 		})
 	}
 }
+
+// setFlags sets the command line flags for the duration of the test.
+func setFlags(t *testing.T, flags map[string]string) {
+	for name, val := range flags {
+		name := name
+		f := flag.Lookup(name)
+		require.NotNil(t, f, "unknown flag %s", name)
+		jtest.RequireNil(t, flag.Set(name, val))
+		t.Cleanup(func() { jtest.RequireNil(t, flag.Set(name, f.DefValue)) })
+	}
+}
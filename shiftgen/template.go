@@ -1,117 +1,53 @@
 package main
 
-var tpl = `package {{.Package}}
+import _ "embed"
 
-// Code generated by shiftgen at {{.GenSource}}. DO NOT EDIT.
-
-import (
-	"context"
-	"database/sql"
-	"strings"
-	"time"
-	"github.com/luno/jettison/errors"
-	"github.com/luno/jettison/j"
-	"github.com/luno/shift"
-)
-
-{{ range .Inserters }}
-
-// Insert inserts a new {{.Table}} table entity. All the fields of the 
-// {{.Type}} receiver are set, as well as status, created_at and updated_at. 
-// The newly created entity id is returned on success or an error.
-func (一 {{.Type}}) Insert(
-	ctx context.Context, tx *sql.Tx, st shift.Status,
-) ({{.IDType}}, error) {
-	var (
-		q    strings.Builder
-		args []interface{}
-	)
-
-	{{if .CustomCreatedAt -}}
-	if 一.CreatedAt.IsZero() {
-		return {{.IDZeroValue}}, errors.New("created_at is required")
-	}
-	{{end -}}
-	{{if .CustomUpdatedAt}}
-	if 一.UpdatedAt.IsZero() {
-		return {{.IDZeroValue}}, errors.New("updated_at is required")
-	}
-
-	{{end -}}
-
-	q.WriteString("insert into {{.Table}} set {{if .HasID}}` + "`id`=?" + `, {{end}}{{col .StatusField}}=?{{if not .CustomCreatedAt}}, {{col "created_at"}}=?{{end}}{{if not .CustomCreatedAt}}, {{col "updated_at"}}=?{{end}} ")
-	args = append(args, {{if .HasID}}一.ID, {{end}}st.ShiftStatus(){{if not .CustomCreatedAt}}, time.Now(){{end}}{{if not .CustomCreatedAt}}, time.Now(){{end}})
-{{range .Fields}}
-	q.WriteString(", {{col .Col}}=?")
-	args = append(args, 一.{{.Name}})
-{{end}}
-	{{if .HasID}}_{{else}}res{{end}}, err := tx.ExecContext(ctx, q.String(), args...)
-	if err != nil {
-		return {{.IDZeroValue}}, err
-	}
-{{if not .HasID}}
-	id, err := res.LastInsertId()
-	if err != nil {
-		return 0, err
-	}
-{{end}}
-	return {{if .HasID}}一.ID{{else}}id{{end}}, nil
-}
-{{end}}{{ range .Updaters }}
-// Update updates the status of a {{.Table}} table entity. All the fields of the
-// {{.Type}} receiver are updated, as well as status and updated_at. 
-// The entity id is returned on success or an error.
-func (一 {{.Type}}) Update(
-	ctx context.Context, tx *sql.Tx, from shift.Status, to shift.Status,
-) ({{.IDType}}, error) {
-	var (
-		q    strings.Builder
-		args []interface{}
-	)
-
-	{{if .CustomUpdatedAt -}}
-	if 一.UpdatedAt.IsZero() {
-		return {{.IDZeroValue}}, errors.New("updated_at is required")
-	}
-
-	{{end -}}
-
-	q.WriteString("update {{.Table}} set {{col .StatusField}}=?{{if not .CustomUpdatedAt}}, {{col "updated_at"}}=?{{end}} ")
-	args = append(args, to.ShiftStatus(){{if not .CustomUpdatedAt}}, time.Now(){{end}})
-{{range .Fields}}
-	q.WriteString(", {{col .Col}}=?")
-	args = append(args, 一.{{.Name}})
-{{end}}
-	q.WriteString(" where {{col "id"}}=? and {{col .StatusField}}=?")
-	args = append(args, 一.ID, from.ShiftStatus())
-
-	res, err := tx.ExecContext(ctx, q.String(), args...)
-	if err != nil {
-		return {{.IDZeroValue}}, err
-	}
-	n, err := res.RowsAffected()
-	if err != nil {
-		return {{.IDZeroValue}}, err
-	}
-	if n != 1 {
-		return {{.IDZeroValue}}, errors.Wrap(shift.ErrRowCount, "{{.Type}}", j.KV("count", n))
-	}
-
-	return 一.ID, nil
-}{{ end }}
-`
+// tpl is the default template of the generated code, overridden by -template.
+//
+//go:embed shift_gen.go.tmpl
+var tpl string
 
 var mermaidTemplate = `%% Code generated by shiftgen at {{.GenSource}}. DO NOT EDIT.
 
 stateDiagram-v2
 	direction {{.Direction}}
 	{{range $key, $value := .StartingPoints }}
-	[*]-->{{$value}}
+	[*]-->{{$value.To}}{{if $value.Label}}: {{$value.Label}}{{end}}
 	{{- end }}
 	{{range $key, $value := .Transitions }}
-	{{$value.From}}-->{{$value.To}}
+	{{$value.From}}-->{{$value.To}}{{if $value.Label}}: {{$value.Label}}{{end}}
 	{{- end }}
 	{{range $key, $value := .TerminalPoints }}
 	{{$value}}-->[*]
 	{{- end }}
+	{{- if .StartingPoints }}
+
+	classDef starting fill:#d4edda,stroke:#28a745,stroke-width:2px
+	class {{.StartingPoints.JoinTo}} starting
+	{{- end }}
+	{{- if .TerminalPoints }}
+
+	classDef terminal fill:#f8d7da,stroke:#dc3545,stroke-width:4px
+	class {{.TerminalPoints.Join}} terminal
+	{{- end }}
+`
+
+var dotTemplate = `// Code generated by shiftgen at {{.GenSource}}. DO NOT EDIT.
+
+digraph {
+	rankdir={{.Direction}}
+	start [shape=point width=0.2]
+	{{range $key, $value := .StartingPoints }}
+	"{{$value.To}}" [style=filled fillcolor="#d4edda" color="#28a745"]
+	{{- end }}
+	{{- range $key, $value := .TerminalPoints }}
+	"{{$value}}" [style=filled fillcolor="#f8d7da" color="#dc3545" peripheries=2]
+	{{- end }}
+	{{range $key, $value := .StartingPoints }}
+	start -> "{{$value.To}}"{{if $value.Label}} [label="{{$value.Label}}"]{{end}}
+	{{- end }}
+	{{- range $key, $value := .Transitions }}
+	"{{$value.From}}" -> "{{$value.To}}"{{if $value.Label}} [label="{{$value.Label}}"]{{end}}
+	{{- end }}
+}
 `
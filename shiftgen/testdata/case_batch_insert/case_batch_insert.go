@@ -0,0 +1,14 @@
+package case_batch_insert
+
+import "time"
+
+type insert struct {
+	Name        string
+	DateOfBirth time.Time         `shift:"dob"`
+	Attrs       map[string]string `shift:"attrs,json"`
+}
+
+type insertWithID struct {
+	ID   int64
+	Name string
+}
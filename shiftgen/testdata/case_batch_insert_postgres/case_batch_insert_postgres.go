@@ -0,0 +1,8 @@
+package case_batch_insert_postgres
+
+import "time"
+
+type insert struct {
+	Name        string
+	DateOfBirth time.Time `shift:"dob"`
+}
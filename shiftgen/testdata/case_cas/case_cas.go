@@ -0,0 +1,18 @@
+package case_cas
+
+import "database/sql"
+
+type insert struct {
+	Name string
+}
+
+type claim struct {
+	ID             int64
+	WorkerID       sql.NullString
+	ExpectedWorker sql.NullString `shift:"worker_id,cas"` // Only claim if unassigned.
+}
+
+type release struct {
+	ID       int64
+	WorkerID string `shift:",cas"`
+}
@@ -0,0 +1,15 @@
+package case_classify_row_count
+
+type insert struct {
+	Name string
+}
+
+type update struct {
+	ID   int64
+	Name string
+}
+
+type rename struct {
+	ID   int64
+	Name string `shift:"name,omitempty"`
+}
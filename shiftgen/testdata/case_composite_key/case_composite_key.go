@@ -0,0 +1,18 @@
+package case_composite_key
+
+type insert struct {
+	TenantID int64 `shift:",primary"`
+	ID       int64
+	Name     string
+}
+
+type update struct {
+	TenantID int64 `shift:",primary"`
+	ID       int64
+	Name     string
+}
+
+type remove struct {
+	ID          int64
+	TenantGroup int64 `shift:"tenant_id,primary"`
+}
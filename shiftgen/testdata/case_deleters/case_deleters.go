@@ -0,0 +1,14 @@
+package case_deleters
+
+type insert struct {
+	Name string
+}
+
+type remove struct {
+	ID int64
+}
+
+type release struct {
+	ID       int64
+	WorkerID string `shift:"worker_id,cas"`
+}
@@ -0,0 +1,25 @@
+package case_duration
+
+import "time"
+
+type insert struct {
+	Name    string
+	Timeout time.Duration
+}
+
+type update struct {
+	ID      int64
+	Timeout *time.Duration
+}
+
+type extend struct {
+	ID      int64
+	Timeout time.Duration `shift:"timeout,omitempty"`
+}
+
+type user struct {
+	ID      int64
+	Status  int
+	Name    string
+	Timeout time.Duration
+}
@@ -0,0 +1,28 @@
+package case_encoding
+
+type insert struct {
+	Name   string
+	Refs   []int64
+	Counts map[string]int `shift:",encoding=gob"`
+}
+
+type update struct {
+	ID     int64
+	Refs   []int64        `shift:"refs,encoding=json"`
+	Counts map[string]int `shift:",encoding=gob"`
+	Data   []byte
+}
+
+type user struct {
+	ID     int64
+	Name   string
+	Refs   []int64
+	Counts map[string]int `shift:",encoding=gob"`
+	Status int
+}
+
+type account struct {
+	ID     int64
+	Refs   []int64
+	Counts map[string]int `shift:",encoding=gob"`
+}
@@ -0,0 +1,18 @@
+package case_force_updaters
+
+type insert struct {
+	Region string
+	Name   string
+}
+
+type update struct {
+	ID     int64
+	Region string `shift:",primary"`
+	Name   string
+}
+
+type repair struct {
+	ID     int64
+	Region string `shift:",primary"`
+	Name   string `shift:",omitempty"`
+}
@@ -0,0 +1,20 @@
+package case_getters
+
+import (
+	"database/sql"
+	"time"
+)
+
+type complete struct {
+	ID int64
+}
+
+type user struct {
+	ID          int64
+	Name        string
+	DateOfBirth time.Time `shift:"dob"`
+	Amount      sql.NullInt64
+	Status      int
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
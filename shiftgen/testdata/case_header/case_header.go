@@ -0,0 +1,10 @@
+package case_header
+
+type insert struct {
+	Name string
+}
+
+type update struct {
+	ID   int64
+	Name string
+}
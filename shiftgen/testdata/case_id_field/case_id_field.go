@@ -0,0 +1,14 @@
+package case_id_field
+
+type insert struct {
+	Name string
+}
+
+type update struct {
+	UserID int64 `shift:"id"`
+	Name   string
+}
+
+type complete struct {
+	UserID int64 `shift:"id"`
+}
@@ -0,0 +1,15 @@
+package case_idempotent_insert
+
+type insert struct {
+	Name string
+}
+
+type insertWithID struct {
+	ID   int64
+	Name string
+}
+
+type update struct {
+	ID   int64
+	Name string
+}
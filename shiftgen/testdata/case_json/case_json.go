@@ -0,0 +1,13 @@
+package case_json
+
+type insert struct {
+	ID    int64
+	Name  string
+	Attrs map[string]string `shift:"attrs,json"`
+}
+
+type update struct {
+	ID    int64
+	Attrs map[string]string `shift:"attrs,json"`
+	Tags  []string          `shift:",json,omitempty"`
+}
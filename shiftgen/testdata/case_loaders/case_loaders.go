@@ -0,0 +1,18 @@
+package case_loaders
+
+import (
+	"database/sql"
+	"time"
+)
+
+type user struct {
+	ID          int64
+	Name        string
+	DateOfBirth time.Time `shift:"dob"`
+	Amount      sql.NullInt64
+	Status      int
+}
+
+type complete struct {
+	ID int64
+}
@@ -0,0 +1,11 @@
+package case_loaders_string
+
+type user struct {
+	ID    string `shift:"uid,primary"`
+	Name  string
+	State int `shift:"status"`
+}
+
+type complete struct {
+	ID string `shift:"uid,primary"`
+}
@@ -21,9 +21,9 @@ const (
 
 var fsm = shift.NewFSM(events).
 	Insert(CREATED, insert{}, PENDING, FAILED).
-	Update(PENDING, update{}, FAILED, COMPLETED).
+	Update(PENDING, update{}, FAILED, shift.Category(COMPLETED, "system")).
 	Update(FAILED, update{}).
-	Update(COMPLETED, update{}).
+	Update(COMPLETED, &complete{}).
 	Build()
 
 func (v status) ShiftStatus() int {
@@ -36,6 +36,7 @@ func (v status) ReflexType() int {
 
 type insert struct{}
 type update struct{}
+type complete struct{}
 
 func (v insert) Insert(ctx context.Context, tx *sql.Tx, status shift.Status) (int64, error) {
 	return 0, nil
@@ -44,3 +45,7 @@ func (v insert) Insert(ctx context.Context, tx *sql.Tx, status shift.Status) (in
 func (v update) Update(ctx context.Context, tx *sql.Tx, from shift.Status, to shift.Status) (int64, error) {
 	return 0, nil
 }
+
+func (v *complete) Update(ctx context.Context, tx *sql.Tx, from shift.Status, to shift.Status) (int64, error) {
+	return 0, nil
+}
@@ -20,10 +20,11 @@ const (
 
 var fsm = shift.NewArcFSM(events).
 	Insert(CREATED, insert{}).
+	Insert(CREATED, insert2{}).
 	Update(CREATED, FAILED, update{}).
 	Update(CREATED, PENDING, update{}).
 	Update(PENDING, FAILED, update{}).
-	Update(PENDING, COMPLETED, update{}).
+	Update(PENDING, COMPLETED, complete{}).
 	Build()
 
 func (v status) ShiftStatus() int {
@@ -35,6 +36,8 @@ func (v status) ReflexType() int {
 }
 
 type insert struct{}
+type insert2 struct{}
+type complete struct{}
 type update struct{}
 
 func (v insert) Insert(ctx context.Context, tx *sql.Tx, status shift.Status) (int64, error) {
@@ -44,3 +47,11 @@ func (v insert) Insert(ctx context.Context, tx *sql.Tx, status shift.Status) (in
 func (v update) Update(ctx context.Context, tx *sql.Tx, from shift.Status, to shift.Status) (int64, error) {
 	return 0, nil
 }
+
+func (v insert2) Insert(ctx context.Context, tx *sql.Tx, status shift.Status) (int64, error) {
+	return 0, nil
+}
+
+func (v complete) Update(ctx context.Context, tx *sql.Tx, from shift.Status, to shift.Status) (int64, error) {
+	return 0, nil
+}
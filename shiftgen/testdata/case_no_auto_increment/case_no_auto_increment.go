@@ -0,0 +1,10 @@
+package case_no_auto_increment
+
+type insert struct {
+	ID   int64 // Snowflake id generated by the application.
+	Name string
+}
+
+type complete struct {
+	ID int64
+}
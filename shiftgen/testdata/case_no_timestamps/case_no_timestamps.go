@@ -0,0 +1,23 @@
+package case_no_timestamps
+
+import "time"
+
+type insert struct {
+	Name      string
+	CreatedAt time.Time
+}
+
+type update struct {
+	ID        int64
+	Name      string
+	UpdatedAt time.Time
+}
+
+type complete struct {
+	ID int64
+}
+
+type upsert struct {
+	ID   int64
+	Name string
+}
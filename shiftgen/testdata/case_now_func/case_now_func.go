@@ -0,0 +1,14 @@
+package case_now_func
+
+import "time"
+
+var clockNow = time.Now
+
+type insert struct {
+	Name string
+}
+
+type update struct {
+	ID   int64
+	Name string
+}
@@ -0,0 +1,28 @@
+package case_omitempty
+
+import (
+	"database/sql"
+	"time"
+)
+
+type insert struct {
+	Name string
+}
+
+type update struct {
+	ID       int64
+	Name     string         `shift:",omitempty"`
+	Amount   Currency       `shift:"amount,omitempty"`
+	Email    sql.NullString `shift:"email,omitempty"`
+	Verified bool           `shift:",omitempty"`
+	Score    *int64         `shift:",omitempty"`
+	Data     []byte         `shift:",omitempty"`
+	DOB      time.Time      `shift:"dob,omitempty"`
+	Note     string
+}
+
+// Currency is a custom "currency" type stored a string in the DB.
+type Currency struct {
+	Valid  bool
+	Amount int64
+}
@@ -0,0 +1,23 @@
+package case_pointers
+
+import "time"
+
+type insert struct {
+	Name     string
+	Nickname *string
+	Age      *int64
+	DOB      *time.Time `shift:"dob"`
+}
+
+type update struct {
+	ID       int64
+	Nickname *string
+	Age      *int64 `shift:",omitempty"`
+}
+
+type user struct {
+	ID       int64
+	Name     string
+	Nickname *string
+	Status   int
+}
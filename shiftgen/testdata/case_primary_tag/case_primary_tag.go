@@ -0,0 +1,16 @@
+package case_primary_tag
+
+type insert struct {
+	Key  string `shift:"ksuid,primary"`
+	Name string
+}
+
+type update struct {
+	Key  string `shift:"ksuid,primary"`
+	Name string
+}
+
+type account struct {
+	Key  string `shift:"ksuid,primary"`
+	Name string
+}
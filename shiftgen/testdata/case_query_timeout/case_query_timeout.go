@@ -0,0 +1,29 @@
+package case_query_timeout
+
+type insert struct {
+	Name string
+}
+
+type update struct {
+	ID   int64
+	Name string
+}
+
+type rename struct {
+	ID   int64
+	Name string `shift:",omitempty"`
+}
+
+type user struct {
+	ID   int64
+	Name string
+}
+
+type remove struct {
+	ID int64
+}
+
+type upsert struct {
+	ID   int64
+	Name string
+}
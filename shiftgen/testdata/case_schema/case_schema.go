@@ -0,0 +1,13 @@
+package case_schema
+
+import "time"
+
+type insert struct {
+	Name        string
+	DateOfBirth time.Time `shift:"dob"`
+}
+
+type update struct {
+	ID     int64
+	Amount int64
+}
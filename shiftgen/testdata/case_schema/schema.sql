@@ -0,0 +1,15 @@
+create table if not exists `users` (
+  `id` bigint not null auto_increment,
+  `name` varchar(255) not null,
+  `dob` datetime not null,
+  `amount` decimal(10, 2),
+
+  `status` tinyint not null,
+  `created_at` datetime not null,
+  `updated_at` datetime not null,
+
+  primary key (`id`),
+  unique key `by_name` (`name`)
+);
+
+create index by_dob on users (dob);
@@ -0,0 +1,18 @@
+package case_shared_roles
+
+type insert struct {
+	Name string
+}
+
+// release is both an updater and a deleter.
+type release struct {
+	ID       int64
+	WorkerID string `shift:"worker_id,cas"`
+}
+
+// user is both a getter and a loader.
+type user struct {
+	ID     int64
+	Name   string
+	Status int
+}
@@ -0,0 +1,25 @@
+package case_soft_delete
+
+type insert struct {
+	Name string
+}
+
+type update struct {
+	ID   int64
+	Name string
+}
+
+type rename struct {
+	ID   int64
+	Name string `shift:",omitempty"`
+}
+
+type remove struct {
+	ID int64
+}
+
+type user struct {
+	ID     int64
+	Name   string
+	Status int
+}
@@ -0,0 +1,9 @@
+package case_soft_delete_unix
+
+type insert struct {
+	Name string
+}
+
+type remove struct {
+	ID int64
+}
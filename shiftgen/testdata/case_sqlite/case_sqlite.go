@@ -0,0 +1,24 @@
+package case_sqlite
+
+import "time"
+
+type insert struct {
+	Name        string
+	DateOfBirth time.Time `shift:"dob"` // Override column name.
+}
+
+type update struct {
+	ID     int64
+	Name   string
+	Amount Currency
+}
+
+type complete struct {
+	ID int64
+}
+
+type claim struct {
+	ID       int64
+	WorkerID string
+	Worker   *string `shift:"worker_id,cas"`
+}
@@ -0,0 +1,20 @@
+package case_table_override
+
+type insert struct {
+	Name string
+}
+
+// update archives the user in the history table.
+//
+// shift:table=user_history
+type update struct {
+	ID   int64
+	Name string
+}
+
+type (
+	// shift:table=user_audit
+	complete struct {
+		ID int64
+	}
+)
@@ -0,0 +1,17 @@
+package case_timestamp_cols
+
+import "time"
+
+type insert struct {
+	Name string
+}
+
+type update struct {
+	ID         int64
+	Name       string
+	ModifiedAt time.Time
+}
+
+type complete struct {
+	ID int64
+}
@@ -0,0 +1,16 @@
+package case_uint64
+
+type insert struct {
+	Name string
+}
+
+type update struct {
+	ID   uint64
+	Name string
+}
+
+type user struct {
+	ID     uint64
+	Name   string
+	Status int
+}
@@ -0,0 +1,10 @@
+package case_uint64_postgres
+
+type insert struct {
+	Name string
+}
+
+type update struct {
+	ID   uint64
+	Name string
+}
@@ -0,0 +1,22 @@
+package case_unix_timestamps
+
+import "time"
+
+type insert struct {
+	Name      string
+	ExpiresAt time.Time `shift:",unix"`
+}
+
+type update struct {
+	ID        int64
+	ExpiresAt time.Time  `shift:",unix"`
+	RenewedAt *time.Time `shift:",unix"`
+}
+
+type user struct {
+	ID        int64
+	Status    int
+	ExpiresAt time.Time  `shift:",unix"`
+	RenewedAt *time.Time `shift:",unix"`
+	CreatedAt time.Time
+}
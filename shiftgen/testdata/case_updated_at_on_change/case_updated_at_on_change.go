@@ -0,0 +1,22 @@
+package case_updated_at_on_change
+
+import "time"
+
+type insert struct {
+	Name        string
+	DateOfBirth time.Time `shift:"dob"`
+}
+
+type update struct {
+	ID   int64
+	Name string
+}
+
+type complete struct {
+	ID int64
+}
+
+type rename struct {
+	ID   int64
+	Name string `shift:",omitempty"`
+}
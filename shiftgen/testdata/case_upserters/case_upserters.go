@@ -0,0 +1,15 @@
+package case_upserters
+
+import "time"
+
+type upsert struct {
+	ID     int64
+	Name   string
+	Amount int64
+}
+
+type upsertByEmail struct {
+	Email     string
+	Name      string
+	CreatedAt time.Time
+}
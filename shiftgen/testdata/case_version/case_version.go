@@ -0,0 +1,17 @@
+package case_version
+
+type insert struct {
+	Name string
+}
+
+type update struct {
+	ID      int64
+	Name    string
+	Version int64 `shift:"version,version"` // Expected version, incremented on update.
+}
+
+type rename struct {
+	ID       int64
+	Name     string `shift:",omitempty"`
+	Revision int64  `shift:"version,version"`
+}
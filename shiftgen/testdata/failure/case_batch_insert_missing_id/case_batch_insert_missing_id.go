@@ -0,0 +1,5 @@
+package testcase
+
+type insert struct {
+	Name string
+}
@@ -0,0 +1,11 @@
+package testcase
+
+type update struct {
+	TenantID int64 `shift:",primary"`
+	ID       int64
+	Name     string
+}
+
+type complete struct {
+	ID int64
+}
@@ -0,0 +1,12 @@
+package case_duplicate_column
+
+type insert struct {
+	Name     string
+	Nickname string `shift:"name"` // Duplicates Name's column.
+}
+
+type update struct {
+	ID     int64
+	UserID int64
+	UserId int64 // Same snake case column as UserID.
+}
@@ -0,0 +1,6 @@
+package testcase
+
+type insert struct {
+	UserID int64
+	Name   string
+}
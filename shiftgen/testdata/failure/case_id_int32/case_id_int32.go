@@ -0,0 +1,6 @@
+package testcase
+
+type insert struct {
+	ID   int32
+	Name string
+}
@@ -0,0 +1,6 @@
+package testcase
+
+type user struct {
+	ID   int64
+	Name string
+}
@@ -0,0 +1,9 @@
+package testcase
+
+type insert struct {
+	Name string
+}
+
+type complete struct {
+	ID int64
+}
@@ -0,0 +1,11 @@
+package case_no_table
+
+// shift:table=users
+type insert struct {
+	Name string
+}
+
+type update struct {
+	ID   int64
+	Name string
+}
@@ -0,0 +1,6 @@
+package case_schema_mismatch
+
+type insert struct {
+	Name     string
+	NickName string // Maps to nick_name, but the column is nickname.
+}
@@ -0,0 +1,9 @@
+create table users (
+  id bigint not null auto_increment,
+  name varchar(255) not null,
+  nickname varchar(255) not null,
+  status tinyint not null,
+  created_at datetime not null,
+  updated_at datetime not null,
+  primary key (id)
+);
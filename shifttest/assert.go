@@ -0,0 +1,48 @@
+package shifttest
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/luno/reflex"
+	"github.com/luno/shift"
+)
+
+// ExpectedEvent is an expected reflex event of AssertEventsWithMetadata.
+type ExpectedEvent struct {
+	Status shift.Status
+	// Metadata is the expected metadata of the event, not compared if nil.
+	Metadata []byte
+}
+
+// AssertEvents asserts that the reflex events of the entity with the foreign id
+// match the expected statuses in order, like shift.AssertTransitions but for a
+// stream. All events up to the current head of the stream are read, events of
+// other entities are ignored.
+// The stream is ex. rsql.EventsTable's ToStream(dbc) or Events' Stream.
+func AssertEvents[T int64 | uint64 | string](t testing.TB, stream reflex.StreamFunc, foreignID T, expected ...shift.Status) {
+	t.Helper()
+
+	shift.AssertTransitions(t, nil, streamer(stream), foreignID, expected)
+}
+
+// AssertEventsWithMetadata is like AssertEvents, but also asserts the metadata
+// of the expected events with non-nil metadata.
+func AssertEventsWithMetadata[T int64 | uint64 | string](t testing.TB, stream reflex.StreamFunc, foreignID T, expected ...ExpectedEvent) {
+	t.Helper()
+
+	statuses := make([]shift.Status, 0, len(expected))
+	metadata := make([][]byte, 0, len(expected))
+	for _, e := range expected {
+		statuses = append(statuses, e.Status)
+		metadata = append(metadata, e.Metadata)
+	}
+	shift.AssertTransitionsWithMetadata(t, nil, streamer(stream), foreignID, statuses, metadata)
+}
+
+// streamer is a shift.EventStreamer returning the stream.
+type streamer reflex.StreamFunc
+
+func (s streamer) ToStream(*sql.DB, ...reflex.StreamOption) reflex.StreamFunc {
+	return reflex.StreamFunc(s)
+}
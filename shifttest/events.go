@@ -0,0 +1,131 @@
+// Package shifttest provides helpers for testing shift FSMs without a database
+// for the reflex events.
+package shifttest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/luno/reflex"
+	"github.com/luno/reflex/rsql"
+	"github.com/luno/shift"
+)
+
+// Event is a reflex event inserted into Events.
+type Event[T int64 | uint64 | string] struct {
+	ForeignID T
+	Type      reflex.EventType
+	Metadata  []byte
+	Timestamp time.Time
+}
+
+// Events is an in-memory shift.EventInserter recording the inserted events,
+// ex. for unit testing FSM logic with a stub or SQLite database. Note that
+// events are recorded when inserted, even if the transaction is rolled back.
+type Events[T int64 | uint64 | string] struct {
+	mu     sync.Mutex
+	events []Event[T]
+}
+
+var (
+	_ shift.EventInserter[int64] = (*Events[int64])(nil)
+	_ shift.EventStreamer        = (*Events[int64])(nil)
+)
+
+// NewEvents returns new in-memory events for FSMs with primary key type T.
+func NewEvents[T int64 | uint64 | string]() *Events[T] {
+	return new(Events[T])
+}
+
+// InsertWithMetadata records the event and returns a no-op notify func.
+func (e *Events[T]) InsertWithMetadata(_ context.Context, _ rsql.DBC, foreignID T,
+	typ reflex.EventType, metadata []byte,
+) (rsql.NotifyFunc, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.events = append(e.events, Event[T]{ForeignID: foreignID, Type: typ, Metadata: metadata, Timestamp: time.Now()})
+	return func() {}, nil
+}
+
+// Events returns the recorded events in order of insertion.
+func (e *Events[T]) Events() []Event[T] {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return append([]Event[T](nil), e.events...)
+}
+
+// EventsFor returns the recorded events of the foreign id in order of insertion.
+func (e *Events[T]) EventsFor(foreignID T) []Event[T] {
+	var res []Event[T]
+	for _, ev := range e.Events() {
+		if ev.ForeignID == foreignID {
+			res = append(res, ev)
+		}
+	}
+	return res
+}
+
+// Types returns the types of the recorded events of the foreign id in order
+// of insertion, ex. to compare with the expected statuses.
+func (e *Events[T]) Types(foreignID T) []int {
+	var res []int
+	for _, ev := range e.EventsFor(foreignID) {
+		res = append(res, ev.Type.ReflexType())
+	}
+	return res
+}
+
+// Stream is a reflex.StreamFunc streaming the recorded events after the
+// event id (its 1-based position), ex. for AssertEvents. It always stops
+// at the current head, returning reflex.ErrHeadReached.
+func (e *Events[T]) Stream(_ context.Context, after string, _ ...reflex.StreamOption) (reflex.StreamClient, error) {
+	var next int
+	if after != "" {
+		n, err := strconv.Atoi(after)
+		if err != nil {
+			return nil, err
+		}
+		next = n
+	}
+	return &streamClient[T]{events: e.Events(), next: next}, nil
+}
+
+// ToStream returns Stream, ignoring the database, so that the events can be
+// used with shift.AssertTransitions and GenFSM.History like rsql.EventsTable.
+func (e *Events[T]) ToStream(*sql.DB, ...reflex.StreamOption) reflex.StreamFunc {
+	return e.Stream
+}
+
+type streamClient[T int64 | uint64 | string] struct {
+	events []Event[T]
+	next   int
+}
+
+func (c *streamClient[T]) Recv() (*reflex.Event, error) {
+	if c.next >= len(c.events) {
+		return nil, reflex.ErrHeadReached
+	}
+	e := c.events[c.next]
+	c.next++
+	return &reflex.Event{
+		ID:        strconv.Itoa(c.next),
+		Type:      e.Type,
+		ForeignID: fmt.Sprint(e.ForeignID),
+		Timestamp: e.Timestamp,
+		MetaData:  e.Metadata,
+	}, nil
+}
+
+// Reset removes all recorded events.
+func (e *Events[T]) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.events = nil
+}
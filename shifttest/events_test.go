@@ -0,0 +1,223 @@
+package shifttest_test
+
+import (
+	"context"
+	"database/sql"
+	"runtime"
+	"testing"
+
+	"github.com/luno/jettison/jtest"
+	"github.com/luno/reflex"
+	"github.com/luno/reflex/rsql"
+	"github.com/luno/shift"
+	"github.com/luno/shift/shifttest"
+	"github.com/stretchr/testify/require"
+)
+
+type status int
+
+func (s status) ShiftStatus() int { return int(s) }
+func (s status) ReflexType() int  { return int(s) }
+
+type insert struct{}
+
+func (insert) Insert(context.Context, *sql.Tx, shift.Status) (int64, error) {
+	return 1, nil
+}
+
+type update struct {
+	ID int64
+}
+
+func (u update) Update(context.Context, *sql.Tx, shift.Status, shift.Status) (int64, error) {
+	return u.ID, nil
+}
+
+func TestEvents(t *testing.T) {
+	ctx := context.Background()
+	events := shifttest.NewEvents[int64]()
+
+	fsm := shift.NewFSM(events).
+		Insert(status(1), insert{}, status(2)).
+		Update(status(2), update{}).
+		Build()
+
+	// The stub inserter and updater don't use the transaction.
+	tx := new(sql.Tx)
+
+	id, notify, err := fsm.InsertTx(ctx, tx, insert{})
+	jtest.RequireNil(t, err)
+	notify()
+
+	_, err = fsm.UpdateTx(ctx, tx, status(1), status(2), update{ID: id})
+	jtest.RequireNil(t, err)
+
+	_, err = events.InsertWithMetadata(ctx, nil, 2, status(1), []byte("meta"))
+	jtest.RequireNil(t, err)
+
+	require.Equal(t, []int{1, 2}, events.Types(id))
+	require.Len(t, events.Events(), 3)
+	other := events.EventsFor(2)
+	require.Len(t, other, 1)
+	require.Equal(t, status(1), other[0].Type)
+	require.Equal(t, []byte("meta"), other[0].Metadata)
+
+	shifttest.AssertEvents(t, events.Stream, id, status(1), status(2))
+	shifttest.AssertEventsWithMetadata(t, events.Stream, int64(2), shifttest.ExpectedEvent{Status: status(1), Metadata: []byte("meta")})
+
+	events.Reset()
+	require.Empty(t, events.Events())
+}
+
+// fatalTB records the failure of a test helper.
+type fatalTB struct {
+	testing.TB
+	failed bool
+}
+
+func (t *fatalTB) Helper() {}
+
+func (t *fatalTB) Fatalf(string, ...any) {
+	t.failed = true
+	runtime.Goexit()
+}
+
+func TestAssertEvents_Fails(t *testing.T) {
+	events := shifttest.NewEvents[string]()
+	_, err := events.InsertWithMetadata(context.Background(), nil, "a", status(1), []byte("meta"))
+	jtest.RequireNil(t, err)
+
+	cases := map[string]func(tb testing.TB){
+		"missing": func(tb testing.TB) {
+			shifttest.AssertEvents(tb, events.Stream, "a", status(1), status(2))
+		},
+		"type": func(tb testing.TB) {
+			shifttest.AssertEvents(tb, events.Stream, "a", status(2))
+		},
+		"other id": func(tb testing.TB) {
+			shifttest.AssertEvents(tb, events.Stream, "b", status(1))
+		},
+		"metadata": func(tb testing.TB) {
+			shifttest.AssertEventsWithMetadata(tb, events.Stream, "a", shifttest.ExpectedEvent{Status: status(1), Metadata: []byte("other")})
+		},
+	}
+	for name, fn := range cases {
+		t.Run(name, func(t *testing.T) {
+			tb := &fatalTB{TB: t}
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				fn(tb)
+			}()
+			<-done
+			require.True(t, tb.failed)
+		})
+	}
+}
+
+func TestEvents_WithNotifier(t *testing.T) {
+	ctx := context.Background()
+	events := shifttest.NewEvents[int64]()
+
+	var deferred []rsql.NotifyFunc
+	fsm := shift.NewFSM(events, shift.WithNotifier(func(next rsql.NotifyFunc) {
+		deferred = append(deferred, next)
+	})).
+		Insert(status(1), insert{}, status(2)).
+		Update(status(2), update{}).
+		Build()
+
+	tx := new(sql.Tx)
+
+	id, notify, err := fsm.InsertTx(ctx, tx, insert{})
+	jtest.RequireNil(t, err)
+	notify()
+
+	notify, err = fsm.UpdateTx(ctx, tx, status(1), status(2), update{ID: id})
+	jtest.RequireNil(t, err)
+	notify()
+
+	require.Len(t, deferred, 2)
+}
+
+type insertStr struct {
+	ID string
+}
+
+func (i insertStr) Insert(context.Context, *sql.Tx, shift.Status) (string, error) {
+	return i.ID, nil
+}
+
+func TestHistory(t *testing.T) {
+	ctx := context.Background()
+	events := shifttest.NewEvents[int64]()
+
+	fsm := shift.NewFSM(events).
+		Insert(status(1), insert{}, status(2)).
+		Update(status(2), update{}).
+		Build()
+
+	tx := new(sql.Tx)
+
+	id, _, err := fsm.InsertTx(ctx, tx, insert{})
+	jtest.RequireNil(t, err)
+	_, err = events.InsertWithMetadata(ctx, nil, 2, status(1), nil)
+	jtest.RequireNil(t, err)
+	_, err = events.InsertWithMetadata(ctx, nil, id, status(2), []byte("meta"))
+	jtest.RequireNil(t, err)
+
+	hist, err := fsm.History(ctx, nil, id)
+	jtest.RequireNil(t, err)
+	require.Len(t, hist, 2)
+	require.Equal(t, "1", hist[0].ForeignID)
+	require.True(t, reflex.IsType(hist[0].Type, status(1)))
+	require.True(t, reflex.IsType(hist[1].Type, status(2)))
+	require.Equal(t, []byte("meta"), hist[1].MetaData)
+	require.Nil(t, hist[0].Metadata)
+	require.Equal(t, []byte("meta"), hist[1].Metadata)
+
+	strEvents := shifttest.NewEvents[string]()
+	arc := shift.NewGenArcFSM[string](strEvents).
+		Insert(status(1), insertStr{}).
+		Build()
+
+	_, _, err = arc.InsertTx(ctx, tx, status(1), insertStr{ID: "a"})
+	jtest.RequireNil(t, err)
+	_, _, err = arc.InsertTx(ctx, tx, status(1), insertStr{ID: "b"})
+	jtest.RequireNil(t, err)
+
+	hist, err = arc.History(ctx, nil, "b")
+	jtest.RequireNil(t, err)
+	require.Len(t, hist, 1)
+	require.Equal(t, "b", hist[0].ForeignID)
+}
+
+type reason struct {
+	Reason string `json:"reason"`
+}
+
+type typedInsert struct {
+	insert
+}
+
+func (typedInsert) GetMetadata(context.Context, *sql.Tx, int64, shift.Status) (reason, error) {
+	return reason{Reason: "created"}, nil
+}
+
+func TestHistory_TypedMetadata(t *testing.T) {
+	ctx := context.Background()
+	events := shifttest.NewEvents[int64]()
+
+	fsm := shift.NewFSM(events, shift.WithTypedMetadata[int64, reason]()).
+		Insert(status(1), typedInsert{}).
+		Build()
+
+	id, _, err := fsm.InsertTx(ctx, new(sql.Tx), typedInsert{})
+	jtest.RequireNil(t, err)
+
+	hist, err := fsm.History(ctx, nil, id)
+	jtest.RequireNil(t, err)
+	require.Len(t, hist, 1)
+	require.Equal(t, []byte(`{"reason":"created"}`), hist[0].MetaData)
+	require.Equal(t, reason{Reason: "created"}, hist[0].Metadata)
+}
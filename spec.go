@@ -0,0 +1,102 @@
+package shift
+
+import (
+	"fmt"
+
+	"github.com/luno/jettison/errors"
+	"github.com/luno/jettison/j"
+)
+
+// Spec is a declarative specification of an FSM, ex. decoded from JSON config.
+// Statuses are identified by their ShiftStatus value and requests by their
+// name in the Registry.
+type Spec struct {
+	Inserts []SpecStatus `json:"inserts"`
+	Updates []SpecStatus `json:"updates"`
+}
+
+// SpecStatus is an insert or update status of a Spec with its next statuses.
+type SpecStatus struct {
+	Status  int    `json:"status"`
+	Request string `json:"request"`
+	Next    []int  `json:"next,omitempty"`
+}
+
+// Registry resolves the statuses and request types of a Spec.
+type Registry struct {
+	// Statuses are the Status values used for the spec's statuses, spec
+	// statuses without one use RawStatus. Note that Insert and Update
+	// must be called with the same Status values.
+	Statuses []Status
+	// Requests are the inserters and updaters by name.
+	Requests map[string]any
+}
+
+// NewFSMFromSpec returns an FSM with an int64 primary key built from the spec,
+// see NewGenFSMFromSpec.
+func NewFSMFromSpec(events EventInserter[int64], spec Spec, reg Registry, opts ...option) (*FSM, error) {
+	return NewGenFSMFromSpec[int64](events, spec, reg, opts...)
+}
+
+// NewGenFSMFromSpec returns an FSM built from the spec, resolving its statuses
+// and requests from the registry. It returns an ErrInvalidFSM error if the spec
+// has no inserts, a request isn't registered or isn't an inserter or updater
+// as required, or if the FSM isn't valid, see BuildE.
+func NewGenFSMFromSpec[T primary](events EventInserter[T], spec Spec, reg Registry, opts ...option) (*GenFSM[T], error) {
+	if len(spec.Inserts) == 0 {
+		return nil, errors.Wrap(ErrInvalidFSM, "spec without inserts")
+	}
+
+	statuses := make(map[int]Status)
+	for _, st := range reg.Statuses {
+		statuses[st.ShiftStatus()] = st
+	}
+	status := func(i int) Status {
+		if st, ok := statuses[i]; ok {
+			return st
+		}
+		return RawStatus(i)
+	}
+	next := func(ss SpecStatus) []Status {
+		var res []Status
+		for _, n := range ss.Next {
+			res = append(res, status(n))
+		}
+		return res
+	}
+	request := func(ss SpecStatus) (any, error) {
+		req, ok := reg.Requests[ss.Request]
+		if !ok {
+			return nil, errors.Wrap(ErrInvalidFSM, "request not registered",
+				j.MKV{"status": fmt.Sprintf("%v", status(ss.Status)), "request": ss.Request})
+		}
+		return req, nil
+	}
+
+	b := builder[T](NewGenFSM[T](events, opts...))
+	for _, ss := range spec.Inserts {
+		req, err := request(ss)
+		if err != nil {
+			return nil, err
+		}
+		inserter, ok := req.(Inserter[T])
+		if !ok {
+			return nil, errors.Wrap(ErrInvalidFSM, "request isn't an inserter",
+				j.MKV{"status": fmt.Sprintf("%v", status(ss.Status)), "request": ss.Request})
+		}
+		b = b.Insert(status(ss.Status), inserter, next(ss)...)
+	}
+	for _, ss := range spec.Updates {
+		req, err := request(ss)
+		if err != nil {
+			return nil, err
+		}
+		updater, ok := req.(Updater[T])
+		if !ok {
+			return nil, errors.Wrap(ErrInvalidFSM, "request isn't an updater",
+				j.MKV{"status": fmt.Sprintf("%v", status(ss.Status)), "request": ss.Request})
+		}
+		b = b.Update(status(ss.Status), updater, next(ss)...)
+	}
+	return b.BuildE()
+}
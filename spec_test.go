@@ -0,0 +1,99 @@
+package shift_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/luno/jettison/j"
+	"github.com/luno/jettison/jtest"
+	"github.com/luno/shift"
+	"github.com/stretchr/testify/require"
+)
+
+const specJSON = `{
+  "inserts": [{"status": 1, "request": "insert", "next": [2]}],
+  "updates": [
+    {"status": 2, "request": "update", "next": [3]},
+    {"status": 3, "request": "complete"}
+  ]
+}`
+
+var registry = shift.Registry{
+	Statuses: []shift.Status{StatusInit, StatusUpdate, StatusComplete},
+	Requests: map[string]any{
+		"insert":   insert{},
+		"update":   update{},
+		"complete": complete{},
+	},
+}
+
+func TestNewFSMFromSpec(t *testing.T) {
+	var spec shift.Spec
+	err := json.Unmarshal([]byte(specJSON), &spec)
+	jtest.RequireNil(t, err)
+
+	f, err := shift.NewFSMFromSpec(events, spec, registry)
+	jtest.RequireNil(t, err)
+	require.Equal(t, fsm.InsertStatuses(), f.InsertStatuses())
+	require.Equal(t, fsm.Transitions(), f.Transitions())
+}
+
+func TestNewFSMFromSpec_RawStatus(t *testing.T) {
+	spec := shift.Spec{
+		Inserts: []shift.SpecStatus{{Status: 1, Request: "insert", Next: []int{2}}},
+		Updates: []shift.SpecStatus{{Status: 2, Request: "update"}},
+	}
+
+	f, err := shift.NewFSMFromSpec(events, spec, shift.Registry{Requests: registry.Requests})
+	jtest.RequireNil(t, err)
+	require.Equal(t, []shift.Transition{{From: shift.RawStatus(1), To: shift.RawStatus(2)}}, f.Transitions())
+}
+
+func TestNewFSMFromSpec_Invalid(t *testing.T) {
+	cases := []struct {
+		name  string
+		spec  shift.Spec
+		expKV j.MKS
+	}{
+		{
+			name: "no inserts",
+			spec: shift.Spec{Updates: []shift.SpecStatus{{Status: 2, Request: "update"}}},
+		},
+		{
+			name:  "unknown request",
+			spec:  shift.Spec{Inserts: []shift.SpecStatus{{Status: 1, Request: "unknown"}}},
+			expKV: j.MKS{"status": "1", "request": "unknown"},
+		},
+		{
+			name:  "not an inserter",
+			spec:  shift.Spec{Inserts: []shift.SpecStatus{{Status: 1, Request: "update"}}},
+			expKV: j.MKS{"status": "1", "request": "update"},
+		},
+		{
+			name: "not an updater",
+			spec: shift.Spec{
+				Inserts: []shift.SpecStatus{{Status: 1, Request: "insert", Next: []int{2}}},
+				Updates: []shift.SpecStatus{{Status: 2, Request: "insert"}},
+			},
+			expKV: j.MKS{"status": "2", "request": "insert"},
+		},
+		{
+			name: "dangling transition",
+			spec: shift.Spec{
+				Inserts: []shift.SpecStatus{{Status: 1, Request: "insert", Next: []int{2}}},
+				Updates: []shift.SpecStatus{{Status: 2, Request: "update", Next: []int{3}}},
+			},
+			expKV: j.MKS{"transitions": "2->3"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := shift.NewFSMFromSpec(events, c.spec, registry)
+			jtest.Require(t, shift.ErrInvalidFSM, err)
+			if c.expKV != nil {
+				jtest.AssertKeyValues(t, c.expKV, err)
+			}
+		})
+	}
+}
@@ -1,87 +1,718 @@
 package shift
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"encoding/hex"
 	"fmt"
 	"math/rand"
 	"reflect"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/luno/jettison/errors"
+	"github.com/luno/jettison/j"
 )
 
 // TODO: Implement TestArcFSM
 
+var errNotReachable = errors.New("status not reachable")
+
+// errSubtestFailed is returned for paths whose error is already reported by
+// their subtest, see WithSubtests.
+var errSubtestFailed = errors.New("subtest failed")
+
+type testOptions struct {
+	seed     int64
+	gens     generators
+	rollback bool
+	subtests bool
+
+	skipReachability bool
+	timestamps       timestampColumns
+	dialect          string
+}
+
+// timestampColumns are the names of the created and updated timestamp columns.
+type timestampColumns struct {
+	created string
+	updated string
+}
+
+// timestampColumns returns the configured timestamp columns, defaulting to
+// created_at and updated_at.
+func (o testOptions) timestampColumns() timestampColumns {
+	ts := o.timestamps
+	if ts.created == "" {
+		ts.created = "created_at"
+	}
+	if ts.updated == "" {
+		ts.updated = "updated_at"
+	}
+	return ts
+}
+
+// placeholder returns the first query placeholder of the configured dialect,
+// defaulting to MySQL.
+func (o testOptions) placeholder() (string, error) {
+	switch o.dialect {
+	case "", "mysql", "sqlite":
+		return "?", nil
+	case "postgres":
+		return "$1", nil
+	default:
+		return "", errors.New("unsupported dialect", j.KV("dialect", o.dialect))
+	}
+}
+
+// generators are custom fuzzed value generators by type.
+type generators map[reflect.Type]func(r *rand.Rand) reflect.Value
+
+// TestOption configures TestFSM.
+type TestOption func(*testOptions)
+
+// WithSeed provides an option to fuzz the data with a fixed seed, ex. to reproduce
+// a failure with the seed logged by TestFSM. A random seed is used by default.
+func WithSeed(seed int64) TestOption {
+	return func(o *testOptions) {
+		o.seed = seed
+	}
+}
+
+// WithGenerator provides an option to fuzz fields of the type with the
+// generator, ex. for custom driver.Valuer types which are otherwise zero.
+// The generator must return a value of the type.
+func WithGenerator(typ reflect.Type, gen func(r *rand.Rand) reflect.Value) TestOption {
+	return func(o *testOptions) {
+		if o.gens == nil {
+			o.gens = make(generators)
+		}
+		o.gens[typ] = gen
+	}
+}
+
+// WithRollback provides an option to drive all the transitions in a single
+// transaction that is rolled back at the end, leaving no rows or events behind,
+// ex. when testing against a shared database. Note that rolled back auto
+// increment ids are not reused.
+func WithRollback() TestOption {
+	return func(o *testOptions) {
+		o.rollback = true
+	}
+}
+
+// WithSubtests provides an option to drive each path in a t.Run subtest named
+// after the path, so failures are reported per path. All paths are driven even
+// if some fail. Path errors are only reported by their subtests, they aren't
+// returned. It requires t to be a *testing.T.
+func WithSubtests() TestOption {
+	return func(o *testOptions) {
+		o.subtests = true
+	}
+}
+
+// WithoutReachabilityCheck provides an option to not fail if some statuses
+// aren't reached by TestFSM, logging them instead, ex. for FSMs under
+// construction built with WithUnreachableStatuses. The SQL of the reachable
+// statuses is still tested.
+func WithoutReachabilityCheck() TestOption {
+	return func(o *testOptions) {
+		o.skipReachability = true
+	}
+}
+
+// WithTimestampColumns provides an option to check the created and updated
+// timestamp columns with the names instead of created_at and updated_at, ex.
+// for tables generated with shiftgen's -created_col and -updated_col flags.
+func WithTimestampColumns(created, updated string) TestOption {
+	return func(o *testOptions) {
+		o.timestamps = timestampColumns{created: created, updated: updated}
+	}
+}
+
+// WithDialect provides an option to query the table with the SQL dialect
+// instead of MySQL, ex. "postgres" for tables generated with shiftgen's
+// -dialect flag. Supported dialects are "mysql", "postgres" and "sqlite".
+func WithDialect(dialect string) TestOption {
+	return func(o *testOptions) {
+		o.dialect = dialect
+	}
+}
+
 // TestFSM tests the provided FSM instance by driving it through all possible
 // state transitions using fuzzed data. It ensures all states are reachable and
-// that the sql queries match the schema.
-func TestFSM(_ testing.TB, dbc *sql.DB, fsm *FSM) error {
-	if fsm.insertStatus == nil {
-		return errors.New("fsm without insert status not supported")
+// that the sql queries match the schema. If the FSM's table is configured via
+// WithTable, it also ensures the created_at and updated_at columns (if any, see
+// WithTimestampColumns) are set and that updated_at doesn't decrease on updates
+// without a field of the updated column, reading the rows by the WithIDColumn
+// column (default "id") with WithDialect queries. Integer timestamp columns are
+// read as unix seconds. These checks are skipped with a logged reason if the
+// table or its id column isn't known. If the FSM is configured with metadata, it
+// ensures the events have metadata (unless WithRollback is used). The fuzzing
+// seed is logged on failure. Updaters must have an ID field or a field tagged
+// `shift:",primary"`.
+func TestFSM(t testing.TB, dbc *sql.DB, fsm *FSM, opts ...TestOption) error {
+	return TestGenFSM[int64](t, dbc, fsm, opts...)
+}
+
+// TestGenFSM is the generic version of TestFSM supporting FSMs of user tables
+// with any primary key type. Inserters of string keyed tables get random ids.
+func TestGenFSM[T primary](t testing.TB, dbc *sql.DB, fsm *GenFSM[T], opts ...TestOption) error {
+	_, err := TestGenFSMResult[T](t, dbc, fsm, opts...)
+	return err
+}
+
+// TestPath is a path driven by TestFSMResult, the id of the inserted entity
+// and the statuses it visited in order.
+type TestPath[T primary] struct {
+	ID       T
+	Statuses []Status
+}
+
+// TestResult is the result of TestFSMResult.
+type TestResult[T primary] struct {
+	Paths []TestPath[T]
+}
+
+// IDs returns the ids of the entities inserted by all paths.
+func (r TestResult[T]) IDs() []T {
+	ids := make([]T, 0, len(r.Paths))
+	for _, p := range r.Paths {
+		ids = append(ids, p.ID)
 	}
-	found := map[int]bool{
-		fsm.insertStatus.ShiftStatus(): true,
+	return ids
+}
+
+// TestFSMResult is the same as TestFSM but also returns the paths it drove,
+// ex. to assert the events or final states of the inserted entities. The
+// paths driven before a failure are returned with the error.
+func TestFSMResult(t testing.TB, dbc *sql.DB, fsm *FSM, opts ...TestOption) (TestResult[int64], error) {
+	return TestGenFSMResult[int64](t, dbc, fsm, opts...)
+}
+
+// TestGenFSMResult is the generic version of TestFSMResult.
+func TestGenFSMResult[T primary](t testing.TB, dbc *sql.DB, fsm *GenFSM[T], opts ...TestOption) (TestResult[T], error) {
+	o := testOptions{seed: time.Now().UnixNano()}
+	for _, opt := range opts {
+		opt(&o)
 	}
 
-	paths := buildPaths(fsm.states, fsm.insertStatus)
-	for i, path := range paths {
-		name := fmt.Sprintf("%d_from_%d_to_%d_len_%d", i, path[0].st, path[len(path)-1].st, len(path))
-		msg := "error in path " + name
+	var tx *sql.Tx
+	if o.rollback {
+		var err error
+		tx, err = dbc.Begin()
+		if err != nil {
+			return TestResult[T]{}, err
+		}
+		defer func() {
+			if err := tx.Rollback(); err != nil {
+				t.Logf("TestFSM failed to roll back: %v", err)
+			}
+		}()
+	}
+
+	run := func(_ string, fn func() error) error { return fn() }
+	if st, ok := t.(*testing.T); ok && o.subtests {
+		run = func(name string, fn func() error) error {
+			ok := st.Run(name, func(t *testing.T) {
+				if err := fn(); err != nil {
+					t.Error(err)
+				}
+			})
+			if !ok {
+				return errSubtestFailed
+			}
+			return nil
+		}
+	}
+
+	paths, err := testFSM(rand.New(rand.NewSource(o.seed)), o, dbc, tx, fsm, run, t.Logf)
+	if err == nil && tx == nil {
+		err = checkMetadata(dbc, fsm, paths)
+	}
+	if o.skipReachability && errors.Is(err, errNotReachable) {
+		t.Logf("TestFSM ignoring unreachable statuses: %+v", err)
+		err = nil
+	}
+	if err != nil {
+		t.Logf("TestFSM failed with seed %d", o.seed)
+	}
+	if errors.Is(err, errSubtestFailed) {
+		err = nil // Already reported by the subtests.
+	}
+	return TestResult[T]{Paths: paths}, err
+}
+
+// testFSM drives the FSM through all paths, in the transaction if not nil,
+// and returns the driven paths. Each path is driven by run, it continues
+// after failed paths if subtests are enabled. Skipped checks are logged with logf.
+func testFSM[T primary](r *rand.Rand, o testOptions, dbc *sql.DB, tx *sql.Tx, fsm *GenFSM[T],
+	run func(name string, fn func() error) error, logf func(format string, args ...any),
+) ([]TestPath[T], error) {
+	gens, all := o.gens, o.subtests
+	if len(fsm.insertStatuses) == 0 {
+		return nil, errors.New("fsm without insert status not supported")
+	}
+	ctx := context.Background()
+	insert := func(inserter Inserter[T]) (T, error) {
+		if tx == nil {
+			return fsm.Insert(ctx, dbc, inserter)
+		}
+		id, _, err := fsm.InsertTx(ctx, tx, inserter)
+		return id, err
+	}
+	update := func(from, to Status, updater Updater[T]) error {
+		if tx == nil {
+			return fsm.Update(ctx, dbc, from, to, updater)
+		}
+		_, err := fsm.UpdateTx(ctx, tx, from, to, updater)
+		return err
+	}
+	var q queryer = dbc
+	if tx != nil {
+		q = tx
+	}
+	ph, err := o.placeholder()
+	if err != nil {
+		return nil, err
+	}
+	tsCols := o.timestampColumns()
+	ts, skip, err := newTimestampChecker(ctx, q, fsm.table, fsm.idColumn(), ph, tsCols)
+	if err != nil {
+		return nil, err
+	} else if skip != "" {
+		logf("TestFSM skipping timestamp checks: %s", skip)
+	}
 
-		insert, err := randomInsert(path[0].req)
+	found := make(map[int]bool)
+	var paths [][]status
+	for _, st := range fsm.insertStatuses {
+		found[st.ShiftStatus()] = true
+		paths = append(paths, buildPaths(fsm.states, st)...)
+	}
+
+	drive := func(path []status, res *TestPath[T]) error {
+		ins, err := randomInsert[T](r, gens, path[0].req)
 		if err != nil {
-			return errors.Wrap(err, msg)
+			return err
 		}
-		id, err := fsm.Insert(context.Background(), dbc, insert)
+		id, err := insert(ins)
 		if err != nil {
-			return errors.Wrap(err, msg)
+			return err
+		}
+		*res = TestPath[T]{ID: id, Statuses: []Status{path[0].st}}
+
+		var updatedAt time.Time
+		err = ts.check(ctx, id, &updatedAt, false)
+		if err != nil {
+			return err
 		}
 
 		from := path[0].st
 		for _, up := range path[1:] {
-			update, err := randomUpdate(up.req, id)
+			upd, err := randomUpdate(r, gens, up.req, id)
 			if err != nil {
-				return errors.Wrap(err, msg)
+				return err
 			}
-			err = fsm.Update(context.Background(), dbc, from, up.st, update)
+			err = update(from, up.st, upd)
 			if err != nil {
-				return errors.Wrap(err, msg)
+				return err
+			}
+			res.Statuses = append(res.Statuses, up.st)
+
+			err = ts.check(ctx, id, &updatedAt, !hasColumn(up.req, tsCols.updated))
+			if err != nil {
+				return err
 			}
 			from = up.st
 			found[up.st.ShiftStatus()] = true
 		}
+		return nil
 	}
-	for st := range fsm.states {
+
+	var (
+		res      []TestPath[T]
+		firstErr error
+	)
+	for i, path := range paths {
+		name := fmt.Sprintf("%d_from_%d_to_%d_len_%d", i, path[0].st, path[len(path)-1].st, len(path))
+
+		var p TestPath[T]
+		err := run(name, func() error {
+			if err := drive(path, &p); err != nil {
+				return errors.Wrap(err, "error in path "+name)
+			}
+			return nil
+		})
+		if p.Statuses != nil {
+			res = append(res, p)
+		}
+		if err != nil && !all {
+			return res, err
+		} else if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return res, firstErr
+	}
+	var unreachable []Status
+	for st, s := range fsm.states {
 		if !found[st] {
-			return errors.New("status not reachable")
+			unreachable = append(unreachable, s.st)
+		}
+	}
+	if len(unreachable) > 0 {
+		sortStatuses(unreachable)
+		return res, errors.Wrap(errNotReachable, "", j.MKV{"statuses": fmt.Sprintf("%v", unreachable)})
+	}
+	return res, nil
+}
+
+// checkMetadata returns an error if the events of the paths don't have
+// metadata while the FSM is configured with metadata. It is skipped if the
+// events can't be streamed. Each events table is streamed once.
+func checkMetadata[T primary](dbc *sql.DB, fsm *GenFSM[T], paths []TestPath[T]) error {
+	if !fsm.withMetadata && fsm.typedMetadata == nil && fsm.metadataFunc == nil {
+		return nil
+	}
+
+	// Group the ids by events table, since they may be sharded.
+	var (
+		tables []EventStreamer
+		ids    = make(map[EventStreamer][]string)
+	)
+	for _, p := range paths {
+		events, ok := eventsFor(fsm.options, p.ID, fsm.events).(EventStreamer)
+		if !ok {
+			return nil
+		}
+		if _, ok := ids[events]; !ok {
+			tables = append(tables, events)
+		}
+		ids[events] = append(ids[events], fmt.Sprint(p.ID))
+	}
+
+	for _, events := range tables {
+		byID, err := readEvents(context.Background(), events.ToStream(dbc), ids[events]...)
+		if err != nil {
+			return err
+		}
+		for _, foreignID := range ids[events] {
+			for _, e := range byID[foreignID] {
+				if len(e.MetaData) == 0 {
+					return errors.New("event without metadata", j.MKV{"id": foreignID, "type": e.Type.ReflexType()})
+				}
+			}
 		}
 	}
 	return nil
 }
 
-func randomUpdate(req any, id int64) (u Updater[int64], err error) {
-	u, ok := req.(Updater[int64])
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// timestampChecker checks the created and updated timestamp columns of the
+// table if it has them. A nil checker doesn't check anything.
+type timestampChecker struct {
+	q     queryer
+	table string
+	idCol string
+	ph    string
+
+	// created and updated are the checked columns, empty if not in the table.
+	created string
+	updated string
+
+	// unix are the integer columns containing unix seconds.
+	unix map[string]bool
+}
+
+// newTimestampChecker returns a checker of the table's timestamp columns or
+// nil if the table has no timestamp columns. If the checks can't be done, it
+// returns nil and the reason, ex. if the table isn't known.
+func newTimestampChecker(ctx context.Context, q queryer, table, idCol, ph string, ts timestampColumns,
+) (*timestampChecker, string, error) {
+	if table == "" {
+		return nil, "table not provided via shift.WithTable", nil
+	}
+
+	rows, err := q.QueryContext(ctx, "select * from "+table+" limit 0")
+	if err != nil {
+		return nil, "", errors.Wrap(err, "query table columns")
+	}
+	defer rows.Close()
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, "", err
+	}
+
+	cols := make(map[string]string)
+	for _, typ := range types {
+		cols[typ.Name()] = typ.DatabaseTypeName()
+	}
+
+	c, skip := timestampCheckerFor(q, table, idCol, ph, ts, cols)
+	return c, skip, nil
+}
+
+// timestampCheckerFor returns a checker of the table with the columns by
+// database type name and the id placeholder, see newTimestampChecker.
+func timestampCheckerFor(q queryer, table, idCol, ph string, ts timestampColumns, cols map[string]string,
+) (*timestampChecker, string) {
+	c := &timestampChecker{
+		q:     q,
+		table: table,
+		idCol: idCol,
+		ph:    ph,
+		unix:  make(map[string]bool),
+	}
+	if typ, ok := cols[ts.created]; ok {
+		c.created = ts.created
+		c.unix[ts.created] = isIntType(typ)
+	}
+	if typ, ok := cols[ts.updated]; ok {
+		c.updated = ts.updated
+		c.unix[ts.updated] = isIntType(typ)
+	}
+	if c.created == "" && c.updated == "" {
+		return nil, ""
+	}
+	if _, ok := cols[idCol]; !ok {
+		return nil, "id column " + idCol + " not in table " + table + ", provide it via shift.WithIDColumn"
+	}
+	return c, ""
+}
+
+// isIntType returns true if the database type name is an integer type, ex.
+// INT, BIGINT or UNSIGNED BIGINT.
+func isIntType(typ string) bool {
+	return strings.Contains(strings.ToUpper(typ), "INT")
+}
+
+// query returns the query of the timestamps of a row by id.
+func (c *timestampChecker) query() string {
+	var cols []string
+	if c.created != "" {
+		cols = append(cols, c.created)
+	}
+	if c.updated != "" {
+		cols = append(cols, c.updated)
+	}
+	return "select " + strings.Join(cols, ", ") + " from " + c.table + " where " + c.idCol + "=" + c.ph
+}
+
+// timestamp is a scanned timestamp column of either a time or unix seconds.
+type timestamp struct {
+	unix bool
+	t    sql.NullTime
+	i    sql.NullInt64
+}
+
+// dest returns the scan destination of the timestamp.
+func (ts *timestamp) dest() any {
+	if ts.unix {
+		return &ts.i
+	}
+	return &ts.t
+}
+
+// time returns the scanned time, which is zero if null.
+func (ts *timestamp) time() time.Time {
+	if ts.unix {
+		if !ts.i.Valid || ts.i.Int64 == 0 {
+			return time.Time{}
+		}
+		return time.Unix(ts.i.Int64, 0)
+	}
+	if !ts.t.Valid {
+		return time.Time{}
+	}
+	return ts.t.Time
+}
+
+// check returns an error if the timestamps of the row aren't set or, if
+// monotonic, the updated timestamp is before the previous one.
+func (c *timestampChecker) check(ctx context.Context, id any, prevUpdatedAt *time.Time, monotonic bool) error {
+	if c == nil {
+		return nil
+	}
+
+	createdAt := timestamp{unix: c.unix[c.created]}
+	updatedAt := timestamp{unix: c.unix[c.updated]}
+	var dest []any
+	if c.created != "" {
+		dest = append(dest, createdAt.dest())
+	}
+	if c.updated != "" {
+		dest = append(dest, updatedAt.dest())
+	}
+
+	err := c.q.QueryRowContext(ctx, c.query(), id).Scan(dest...)
+	if err != nil {
+		return errors.Wrap(err, "query timestamps")
+	}
+
+	if c.created != "" && createdAt.time().IsZero() {
+		return errors.New("created timestamp not set", j.MKV{"id": id, "column": c.created})
+	}
+	if c.updated == "" {
+		return nil
+	}
+	current := updatedAt.time()
+	if current.IsZero() {
+		return errors.New("updated timestamp not set", j.MKV{"id": id, "column": c.updated})
+	}
+	if monotonic && current.Before(*prevUpdatedAt) {
+		return errors.New("updated timestamp decreased", j.MKV{
+			"id":       id,
+			"column":   c.updated,
+			"previous": prevUpdatedAt.String(),
+			"current":  current.String(),
+		})
+	}
+	*prevUpdatedAt = current
+	return nil
+}
+
+// hasColumn returns true if the request is a struct with a field of the
+// column, either tagged `shift:"<col>"` or named after it, ex. UpdatedAt for
+// updated_at.
+func hasColumn(req any, col string) bool {
+	t := reflect.TypeOf(req)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if name, _, _ := strings.Cut(f.Tag.Get("shift"), ","); name != "" {
+			if name == col {
+				return true
+			}
+			continue
+		}
+		if strings.EqualFold(f.Name, strings.ReplaceAll(col, "_", "")) {
+			return true
+		}
+	}
+	return false
+}
+
+// AssertTransitions asserts that the reflex events of the entity with the provided
+// id match the expected statuses in order. All events up to the current head of
+// the events table are read, events of other entities are ignored.
+func AssertTransitions[T primary](t testing.TB, dbc *sql.DB, events EventStreamer, id T, expected []Status) {
+	t.Helper()
+
+	AssertTransitionsWithMetadata(t, dbc, events, id, expected, nil)
+}
+
+// AssertTransitionsWithMetadata is like AssertTransitions, but also asserts the
+// metadata of the events. Metadata is compared by index with the expected
+// statuses, nil or missing metadata isn't compared.
+func AssertTransitionsWithMetadata[T primary](t testing.TB, dbc *sql.DB, events EventStreamer, id T,
+	expected []Status, metadata [][]byte,
+) {
+	t.Helper()
+
+	foreignID := fmt.Sprint(id)
+	byID, err := readEvents(context.Background(), events.ToStream(dbc), foreignID)
+	if err != nil {
+		t.Fatalf("read events: %v", err)
+	}
+	actual := byID[foreignID]
+
+	exp := make([]int, 0, len(expected))
+	for _, st := range expected {
+		exp = append(exp, st.ReflexType())
+	}
+	act := make([]int, 0, len(actual))
+	for _, e := range actual {
+		act = append(act, e.Type.ReflexType())
+	}
+
+	if !slices.Equal(exp, act) {
+		t.Fatalf("unexpected transitions for id %v: expected event types %v, got %v", id, exp, act)
+	}
+	for i, meta := range metadata {
+		if meta != nil && i < len(actual) && !bytes.Equal(meta, actual[i].MetaData) {
+			t.Fatalf("unexpected metadata of event %d for id %v: expected %q, got %q", i, id, meta, actual[i].MetaData)
+		}
+	}
+}
+
+func randomUpdate[T primary](r *rand.Rand, gens generators, req any, id T) (u Updater[T], err error) {
+	u, ok := req.(Updater[T])
 	if !ok {
 		return nil, errors.New("req not of tupe Updater")
 	}
 	s := reflect.New(reflect.ValueOf(req).Type()).Elem()
+	idx, err := primaryField(s.Type(), reflect.TypeOf(id))
+	if err != nil {
+		return nil, err
+	}
 	for i := 0; i < s.NumField(); i++ {
 		f := s.Field(i)
 		t := f.Type()
-		if s.Type().Field(i).Name == "ID" {
-			f.SetInt(id)
+		if i == idx {
+			f.Set(reflect.ValueOf(id).Convert(t))
 		} else {
-			f.Set(randVal(t))
+			f.Set(randVal(r, gens, t))
 		}
 	}
-	return s.Interface().(Updater[int64]), nil
+	return s.Interface().(Updater[T]), nil
 }
 
-func randomInsert(req any) (Inserter[int64], error) {
-	_, ok := req.(Inserter[int64])
+// primaryField returns the index of the struct's field containing the id, the
+// field tagged `shift:",primary"` or named ID. It returns an error if there is
+// no such field or it is not convertible from the id type.
+func primaryField(t reflect.Type, idType reflect.Type) (int, error) {
+	var primaries []int
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		opts := strings.Split(f.Tag.Get("shift"), ",")[1:]
+		if f.Name == "ID" || slices.ContainsFunc(opts, func(o string) bool { return strings.TrimSpace(o) == "primary" }) {
+			primaries = append(primaries, i)
+		}
+	}
+
+	var idx int
+	if len(primaries) == 1 {
+		idx = primaries[0]
+	} else if len(primaries) > 1 {
+		// Composite primary keys include the ID field.
+		f, ok := t.FieldByName("ID")
+		if !ok {
+			return 0, errors.New("composite primary key without ID field", j.KV("type", t.String()))
+		}
+		idx = f.Index[0]
+	} else {
+		return 0, errors.New("primary field not found, name it ID or tag it with shift:\",primary\"", j.KV("type", t.String()))
+	}
+
+	// Integers are convertible to strings as runes, so compare string kinds too.
+	ft := t.Field(idx).Type
+	if !idType.ConvertibleTo(ft) || (idType.Kind() == reflect.String) != (ft.Kind() == reflect.String) {
+		return 0, errors.New("primary field type mismatch", j.MKV{
+			"type":  t.String(),
+			"field": t.Field(idx).Name,
+			"id":    idType.String(),
+		})
+	}
+	return idx, nil
+}
+
+func randomInsert[T primary](r *rand.Rand, gens generators, req any) (Inserter[T], error) {
+	_, ok := req.(Inserter[T])
 	if !ok {
 		return nil, errors.New("req not of type Inserter")
 	}
@@ -89,9 +720,9 @@ func randomInsert(req any) (Inserter[int64], error) {
 	s := reflect.New(reflect.ValueOf(req).Type()).Elem()
 	for i := 0; i < s.NumField(); i++ {
 		f := s.Field(i)
-		f.Set(randVal(f.Type()))
+		f.Set(randVal(r, gens, f.Type()))
 	}
-	return s.Interface().(Inserter[int64]), nil
+	return s.Interface().(Inserter[T]), nil
 }
 
 func buildPaths(states map[int]status, from Status) [][]status {
@@ -100,8 +731,8 @@ func buildPaths(states map[int]status, from Status) [][]status {
 	hasEnd := len(here.next) == 0
 	delete(states, from.ShiftStatus()) // Break cycles
 	for next := range here.next {
-		if _, ok := states[next.ShiftStatus()]; !ok {
-			hasEnd = true // Stop at breaks
+		if n, ok := states[next.ShiftStatus()]; !ok || n.insert {
+			hasEnd = true // Stop at breaks and insert statuses
 			continue
 		}
 		paths := buildPaths(states, next)
@@ -117,44 +748,88 @@ func buildPaths(states map[int]status, from Status) [][]status {
 }
 
 var (
-	intType        = reflect.TypeOf((int)(0))
-	int64Type      = reflect.TypeOf((int64)(0))
-	float64Type    = reflect.TypeOf((float64)(0))
-	timeType       = reflect.TypeOf(time.Time{})
-	sliceByteType  = reflect.TypeOf([]byte(nil))
-	boolType       = reflect.TypeOf(false)
-	stringType     = reflect.TypeOf("")
-	nullTimeType   = reflect.TypeOf(sql.NullTime{})
-	nullStringType = reflect.TypeOf(sql.NullString{})
+	intType         = reflect.TypeOf((int)(0))
+	int64Type       = reflect.TypeOf((int64)(0))
+	uint64Type      = reflect.TypeOf((uint64)(0))
+	float64Type     = reflect.TypeOf((float64)(0))
+	timeType        = reflect.TypeOf(time.Time{})
+	durationType    = reflect.TypeOf(time.Duration(0))
+	sliceByteType   = reflect.TypeOf([]byte(nil))
+	boolType        = reflect.TypeOf(false)
+	stringType      = reflect.TypeOf("")
+	nullTimeType    = reflect.TypeOf(sql.NullTime{})
+	nullStringType  = reflect.TypeOf(sql.NullString{})
+	nullInt64Type   = reflect.TypeOf(sql.NullInt64{})
+	nullInt32Type   = reflect.TypeOf(sql.NullInt32{})
+	nullFloat64Type = reflect.TypeOf(sql.NullFloat64{})
+	nullBoolType    = reflect.TypeOf(sql.NullBool{})
 )
 
-func randVal(t reflect.Type) reflect.Value {
+func randVal(r *rand.Rand, gens generators, t reflect.Type) reflect.Value {
+	if gen, ok := gens[t]; ok {
+		return gen(r)
+	}
+
+	if t.Kind() == reflect.Pointer {
+		// Nil half the time to exercise nullable columns.
+		if r.Float64() < 0.5 {
+			return reflect.Zero(t)
+		}
+		p := reflect.New(t.Elem())
+		p.Elem().Set(randVal(r, gens, t.Elem()))
+		return p
+	}
+
 	var v any
 	switch t {
 	case intType:
-		v = rand.Intn(1000)
+		v = r.Intn(1000)
 	case int64Type:
-		v = int64(rand.Intn(1000))
+		v = int64(r.Intn(1000))
+	case uint64Type:
+		v = uint64(r.Intn(1000))
 	case float64Type:
-		v = rand.Float64() * 1000
+		v = r.Float64() * 1000
 	case timeType:
-		d := time.Duration(rand.Intn(1000)) * time.Hour
+		d := time.Duration(r.Intn(1000)) * time.Hour
 		v = time.Now().Add(-d)
+	case durationType:
+		v = time.Duration(r.Int63n(int64(time.Hour))) + 1
 	case sliceByteType:
-		v = randBytes(rand.Intn(64))
+		v = randBytes(r, r.Intn(64))
 	case boolType:
-		v = rand.Float64() < 0.5
+		v = r.Float64() < 0.5
 	case stringType:
-		v = hex.EncodeToString(randBytes(rand.Intn(5) + 5))
+		v = hex.EncodeToString(randBytes(r, r.Intn(5)+5))
 	case nullTimeType:
 		v = sql.NullTime{
-			Valid: rand.Float64() < 0.5,
+			Valid: r.Float64() < 0.5,
 			Time:  time.Now(),
 		}
 	case nullStringType:
 		v = sql.NullString{
-			Valid:  rand.Float64() < 0.5,
-			String: hex.EncodeToString(randBytes(rand.Intn(5) + 5)),
+			Valid:  r.Float64() < 0.5,
+			String: hex.EncodeToString(randBytes(r, r.Intn(5)+5)),
+		}
+	case nullInt64Type:
+		v = sql.NullInt64{
+			Valid: r.Float64() < 0.5,
+			Int64: int64(r.Intn(1000)),
+		}
+	case nullInt32Type:
+		v = sql.NullInt32{
+			Valid: r.Float64() < 0.5,
+			Int32: int32(r.Intn(1000)),
+		}
+	case nullFloat64Type:
+		v = sql.NullFloat64{
+			Valid:   r.Float64() < 0.5,
+			Float64: r.Float64() * 1000,
+		}
+	case nullBoolType:
+		v = sql.NullBool{
+			Valid: r.Float64() < 0.5,
+			Bool:  r.Float64() < 0.5,
 		}
 	default:
 		return reflect.Indirect(reflect.New(t))
@@ -162,8 +837,8 @@ func randVal(t reflect.Type) reflect.Value {
 	return reflect.ValueOf(v)
 }
 
-func randBytes(size int) []byte {
+func randBytes(r *rand.Rand, size int) []byte {
 	b := make([]byte, size)
-	rand.Read(b)
+	r.Read(b)
 	return b
 }
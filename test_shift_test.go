@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/luno/jettison/jtest"
 	"github.com/luno/reflex"
 	"github.com/luno/reflex/rsql"
 	"github.com/luno/shift"
@@ -53,7 +54,7 @@ func TestTestFSM(t *testing.T) {
 		},
 		{
 			name: "update not reachable",
-			fsm: shift.NewFSM(events).
+			fsm: shift.NewFSM(events, shift.WithUnreachableStatuses()).
 				Insert(s(1), i{}).
 				Update(s(2), u{}).
 				Build(),
@@ -89,6 +90,101 @@ func TestTestFSM(t *testing.T) {
 	}
 }
 
+func TestTestFSM_WithoutReachabilityCheck(t *testing.T) {
+	dbc := setup(t)
+
+	fsm := shift.NewFSM(events, shift.WithUnreachableStatuses()).
+		Insert(s(1), i{}).
+		Update(s(2), u{}).
+		Build()
+
+	res, err := shift.TestGenFSMResult[int64](t, dbc, fsm, shift.WithoutReachabilityCheck())
+	require.NoError(t, err)
+	require.Len(t, res.Paths, 1)
+	require.Equal(t, []shift.Status{s(1)}, res.Paths[0].Statuses)
+}
+
+func TestBuildE_NotReachable(t *testing.T) {
+	_, err := shift.NewFSM(events).
+		Insert(s(1), i{}).
+		Update(s(2), u{}).
+		BuildE()
+	jtest.Require(t, shift.ErrInvalidFSM, err)
+	require.Equal(t, "statuses not reachable from insert statuses: invalid fsm", err.Error())
+
+	_, err = shift.NewFSM(events, shift.WithUnreachableStatuses()).
+		Insert(s(1), i{}).
+		Update(s(2), u{}).
+		BuildE()
+	jtest.RequireNil(t, err)
+}
+
+func TestTestFSM_WithRollback(t *testing.T) {
+	dbc := setup(t)
+
+	fsm := shift.NewFSM(events).
+		Insert(s(1), i{}, s(2)).
+		Update(s(2), u{}, s(2)).
+		Build()
+
+	err := shift.TestFSM(t, dbc, fsm, shift.WithRollback())
+	require.NoError(t, err)
+
+	for _, table := range []string{"tests", "events"} {
+		var n int
+		err = dbc.QueryRow("select count(*) from " + table).Scan(&n)
+		require.NoError(t, err)
+		require.Zero(t, n, table)
+	}
+}
+
+func TestTestFSMResult(t *testing.T) {
+	dbc := setup(t)
+
+	res, err := shift.TestFSMResult(t, dbc, fsm)
+	require.NoError(t, err)
+	require.NotEmpty(t, res.Paths)
+	require.Len(t, res.IDs(), len(res.Paths))
+
+	for _, p := range res.Paths {
+		require.Equal(t, StatusInit, p.Statuses[0])
+		shift.AssertTransitions(t, dbc, events, p.ID, p.Statuses)
+	}
+}
+
+func TestTestFSM_Timestamps(t *testing.T) {
+	dbc := setup(t)
+
+	fsm := shift.NewFSM(events, shift.WithTable(usersTable)).
+		Insert(StatusInit, insert{}, StatusUpdate).
+		Update(StatusUpdate, update{}, StatusComplete).
+		Update(StatusComplete, complete{}).
+		Build()
+
+	err := shift.TestFSM(t, dbc, fsm)
+	require.NoError(t, err)
+}
+
+func TestTestFSM_WithSubtests(t *testing.T) {
+	dbc := setup(t)
+
+	err := shift.TestFSM(t, dbc, fsm, shift.WithSubtests())
+	require.NoError(t, err)
+}
+
+func TestTestGenFSM_String(t *testing.T) {
+	dbc := setup(t)
+
+	fsm := shift.NewGenFSM[string](eventsStr).
+		Insert(StatusInit, insertStr{}, StatusUpdate).
+		Update(StatusUpdate, updateStr{}, StatusComplete, StatusUpdate).
+		Update(StatusComplete, completeStr{}).
+		Build()
+
+	err := shift.TestGenFSM(t, dbc, fsm)
+	require.NoError(t, err)
+}
+
 func (ii i) GetMetadata(ctx context.Context, tx *sql.Tx, id int64, status shift.Status) ([]byte, error) {
 	return []byte(fmt.Sprint(id)), nil
 }
@@ -133,6 +229,22 @@ func TestWithMeta(t *testing.T) {
 	require.Equal(t, e.ForeignID, string(e.MetaData))
 }
 
+func TestWithMeta_Missing(t *testing.T) {
+	dbc := setup(t)
+
+	events := events.Clone(rsql.WithEventMetadataField("metadata"))
+
+	fsm := shift.NewFSM(events, shift.WithMetadataFunc(func(context.Context, any, shift.Status, shift.Status) ([]byte, error) {
+		return nil, nil
+	})).
+		Insert(s(1), i{}, s(2)).
+		Update(s(2), u{}).
+		Build()
+
+	err := shift.TestFSM(t, dbc, fsm)
+	require.ErrorContains(t, err, "event without metadata")
+}
+
 func s(i int) shift.Status {
 	return TestStatus(i)
 }
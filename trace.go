@@ -0,0 +1,60 @@
+package shift
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// noopEnd is returned by startSpan and observe if disabled.
+var noopEnd = func(error) {}
+
+// startSpan starts a span named like "shift.Update/<from>-><to>" if a tracer is
+// configured. It returns the context containing the span and a func ending the
+// span, recording the error if not nil. A nil from or to status is omitted,
+// as is the table if not provided via WithTable.
+func (o options) startSpan(ctx context.Context, op string, from, to Status) (context.Context, func(error)) {
+	if o.tracer == nil {
+		return ctx, noopEnd
+	}
+
+	name := op + "/"
+	var attrs []attribute.KeyValue
+	if o.table != "" {
+		attrs = append(attrs, attribute.String("shift.table", o.table))
+	}
+	if to != nil {
+		attrs = append(attrs, attribute.String("shift.to", fmt.Sprintf("%v", to)))
+	}
+	if from != nil {
+		name += fmt.Sprintf("%v", from)
+		attrs = append(attrs, attribute.String("shift.from", fmt.Sprintf("%v", from)))
+	}
+	if from != nil && to != nil {
+		name += "->"
+	}
+	if to != nil {
+		name += fmt.Sprintf("%v", to)
+	}
+
+	ctx, span := o.tracer.Start(ctx, name, trace.WithAttributes(attrs...), trace.WithTimestamp(o.now()))
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End(trace.WithTimestamp(o.now()))
+	}
+}
+
+// setSpanID sets the entity id attribute of the span in the context if a
+// tracer is configured.
+func (o options) setSpanID(ctx context.Context, id any) {
+	if o.tracer == nil {
+		return
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("shift.id", fmt.Sprint(id)))
+}
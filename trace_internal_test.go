@@ -0,0 +1,146 @@
+package shift
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/luno/jettison/errors"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type testStatus int
+
+func (s testStatus) ShiftStatus() int { return int(s) }
+func (s testStatus) ReflexType() int  { return int(s) }
+
+type fakeSpan struct {
+	trace.Span
+	name   string
+	attrs  []attribute.KeyValue
+	err    error
+	status codes.Code
+	ended  bool
+	start  time.Time
+	end    time.Time
+}
+
+func (s *fakeSpan) SetAttributes(kv ...attribute.KeyValue)        { s.attrs = append(s.attrs, kv...) }
+func (s *fakeSpan) RecordError(err error, _ ...trace.EventOption) { s.err = err }
+func (s *fakeSpan) SetStatus(code codes.Code, _ string)           { s.status = code }
+func (s *fakeSpan) End(opts ...trace.SpanEndOption) {
+	s.ended = true
+	cfg := trace.NewSpanEndConfig(opts...)
+	s.end = cfg.Timestamp()
+}
+
+type fakeTracer struct {
+	spans []*fakeSpan
+}
+
+func (t *fakeTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	cfg := trace.NewSpanStartConfig(opts...)
+	span := &fakeSpan{
+		Span:  trace.SpanFromContext(context.Background()),
+		name:  name,
+		attrs: cfg.Attributes(),
+		start: cfg.Timestamp(),
+	}
+	t.spans = append(t.spans, span)
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+func TestStartSpan(t *testing.T) {
+	tracer := new(fakeTracer)
+	o := options{tracer: tracer}
+
+	ctx, end := o.startSpan(context.Background(), "shift.Update", testStatus(1), testStatus(2))
+	o.setSpanID(ctx, int64(3))
+	end(nil)
+
+	_, end = o.startSpan(context.Background(), "shift.Insert", nil, testStatus(1))
+	errFailed := errors.New("failed")
+	end(errFailed)
+
+	_, end = o.startSpan(context.Background(), "shift.Delete", testStatus(3), nil)
+	end(nil)
+
+	require.Len(t, tracer.spans, 3)
+
+	update := tracer.spans[0]
+	require.Equal(t, "shift.Update/1->2", update.name)
+	require.Equal(t, []attribute.KeyValue{
+		attribute.String("shift.to", "2"),
+		attribute.String("shift.from", "1"),
+		attribute.String("shift.id", "3"),
+	}, update.attrs)
+	require.True(t, update.ended)
+	require.Nil(t, update.err)
+
+	insert := tracer.spans[1]
+	require.Equal(t, "shift.Insert/1", insert.name)
+	require.True(t, insert.ended)
+	require.Equal(t, errFailed, insert.err)
+	require.Equal(t, codes.Error, insert.status)
+
+	del := tracer.spans[2]
+	require.Equal(t, "shift.Delete/3", del.name)
+	require.Equal(t, []attribute.KeyValue{attribute.String("shift.from", "3")}, del.attrs)
+}
+
+func TestStartSpan_WithTable(t *testing.T) {
+	tracer := new(fakeTracer)
+	o := options{tracer: tracer}
+	WithTable("users")(&o)
+
+	_, end := o.startSpan(context.Background(), "shift.Update", testStatus(1), testStatus(2))
+	end(nil)
+
+	require.Len(t, tracer.spans, 1)
+	require.Equal(t, []attribute.KeyValue{
+		attribute.String("shift.table", "users"),
+		attribute.String("shift.to", "2"),
+		attribute.String("shift.from", "1"),
+	}, tracer.spans[0].attrs)
+}
+
+func TestInsertBatch_Span(t *testing.T) {
+	tracer := new(fakeTracer)
+	fsm := NewFSM(new(fakeEvents), WithTracer(tracer)).
+		Insert(testStatus(1), plainInsert{}).
+		Build()
+
+	_, err := fsm.InsertBatch(context.Background(), new(countingBeginner), plainBatch{})
+	require.Error(t, err)
+
+	require.Len(t, tracer.spans, 1)
+	require.Equal(t, "shift.InsertBatch/1", tracer.spans[0].name)
+	require.True(t, tracer.spans[0].ended)
+	require.Equal(t, err, tracer.spans[0].err)
+}
+
+func TestStartSpan_NoTracer(t *testing.T) {
+	ctx := context.Background()
+	spanCtx, end := options{}.startSpan(ctx, "shift.Update", testStatus(1), testStatus(2))
+	require.Equal(t, ctx, spanCtx)
+	end(nil)
+}
+
+func TestStartSpan_WithClock(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := t0
+	tracer := new(fakeTracer)
+	o := options{tracer: tracer}
+	WithClock(func() time.Time { return now })(&o)
+
+	_, end := o.startSpan(context.Background(), "shift.Insert", nil, testStatus(1))
+	now = now.Add(time.Second)
+	end(nil)
+
+	require.Len(t, tracer.spans, 1)
+	require.Equal(t, t0, tracer.spans[0].start)
+	require.Equal(t, t0.Add(time.Second), tracer.spans[0].end)
+}
@@ -0,0 +1,169 @@
+package shift
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/luno/jettison/errors"
+	"github.com/luno/jettison/j"
+	"github.com/luno/reflex/rsql"
+)
+
+const (
+	mysqlLockWaitTimeout = 1205
+	mysqlDeadlock        = 1213
+	mysqlDuplicateEntry  = 1062
+
+	savepointName = "shift_transition"
+
+	minBackoff = 10 * time.Millisecond
+	maxBackoff = time.Second
+)
+
+// Beginner begins transactions. It is implemented by *sql.DB and *sql.Conn,
+// so FSMs can also be used with a pinned connection or a wrapper.
+type Beginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// IsRetryableMySQL returns true if the error is a MySQL deadlock or lock wait
+// timeout, after which the transaction can be retried.
+func IsRetryableMySQL(err error) bool {
+	var myErr *mysql.MySQLError
+	if !errors.As(err, &myErr) {
+		return false
+	}
+	return myErr.Number == mysqlDeadlock || myErr.Number == mysqlLockWaitTimeout
+}
+
+// wrapDuplicate returns the error wrapped with ErrDuplicate's code if it is a
+// MySQL duplicate entry error, otherwise it returns the error as is.
+func wrapDuplicate(err error) error {
+	var myErr *mysql.MySQLError
+	if !errors.As(err, &myErr) || myErr.Number != mysqlDuplicateEntry {
+		return err
+	}
+	return errors.Wrap(err, "duplicate entry", j.C(codeDuplicate))
+}
+
+// runTx runs fn in a transaction and calls the returned notify func after
+// committing it. The transaction is retried if WithRetry is configured.
+// If WithEventsDB is configured, the events are inserted in a second
+// transaction which is committed after the first, see eventsDBC.
+func (o options) runTx(ctx context.Context, dbc Beginner, fn func(ctx context.Context, tx *sql.Tx) (rsql.NotifyFunc, error)) error {
+	var notify rsql.NotifyFunc
+	err := o.retry(ctx, func() error {
+		tx, err := o.begin(ctx, dbc)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		var etx *sql.Tx
+		txCtx := ctx
+		if o.eventsDB != nil {
+			etx, err = o.begin(ctx, o.eventsDB)
+			if err != nil {
+				return errors.Wrap(err, "begin events tx")
+			}
+			defer etx.Rollback()
+			txCtx = context.WithValue(ctx, eventsTxKey{}, etx)
+		}
+
+		notify, err = fn(txCtx, tx)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			setResult(ctx, resultCommit)
+			return err
+		}
+
+		if etx != nil {
+			err = etx.Commit()
+			if err != nil {
+				setResult(ctx, resultCommit)
+				return errors.Wrap(err, "commit events tx", j.C(codeEventsNotCommitted))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	notify()
+	return nil
+}
+
+type eventsTxKey struct{}
+
+// eventsDBC returns the events transaction of runTx in the context if
+// WithEventsDB is configured, otherwise tx.
+func eventsDBC(ctx context.Context, tx *sql.Tx) rsql.DBC {
+	if etx, ok := ctx.Value(eventsTxKey{}).(*sql.Tx); ok {
+		return etx
+	}
+	return tx
+}
+
+// retry calls fn until it succeeds, fails with an error that isn't retryable
+// or the retries are exhausted, backing off exponentially between attempts.
+func (o options) retry(ctx context.Context, fn func() error) error {
+	backoff := minBackoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= o.retries || !o.isRetryable(err) || errors.Is(err, ErrEventsNotCommitted) {
+			return err
+		}
+		setResult(ctx, "")
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+// begin begins a transaction with the transaction options if provided.
+func (o options) begin(ctx context.Context, dbc Beginner) (*sql.Tx, error) {
+	if o.txOptions == nil {
+		// Like sql.DB's Begin, don't bind the transaction to ctx.
+		return dbc.BeginTx(context.Background(), nil)
+	}
+	return dbc.BeginTx(ctx, o.txOptions)
+}
+
+// savepoint calls fn within a savepoint of tx if WithSavepoints is set,
+// rolling back to the savepoint if fn fails.
+func (o options) savepoint(ctx context.Context, tx *sql.Tx, fn func() error) error {
+	if !o.withSavepoints {
+		return fn()
+	}
+
+	_, err := tx.ExecContext(ctx, "savepoint "+savepointName)
+	if err != nil {
+		return errors.Wrap(err, "create savepoint")
+	}
+
+	err = fn()
+	if err != nil {
+		_, rbErr := tx.ExecContext(ctx, "rollback to savepoint "+savepointName)
+		if rbErr != nil {
+			return errors.Wrap(rbErr, "rollback to savepoint")
+		}
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "release savepoint "+savepointName)
+	if err != nil {
+		return errors.Wrap(err, "release savepoint")
+	}
+	return nil
+}
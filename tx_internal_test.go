@@ -0,0 +1,279 @@
+package shift
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/luno/jettison/errors"
+	"github.com/luno/jettison/j"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsRetryableMySQL(t *testing.T) {
+	require.True(t, IsRetryableMySQL(&mysql.MySQLError{Number: 1213}))
+	require.True(t, IsRetryableMySQL(errors.Wrap(&mysql.MySQLError{Number: 1205}, "")))
+	require.False(t, IsRetryableMySQL(&mysql.MySQLError{Number: 1062}))
+	require.False(t, IsRetryableMySQL(errors.New("other")))
+	require.False(t, IsRetryableMySQL(nil))
+}
+
+func TestRetry(t *testing.T) {
+	deadlock := &mysql.MySQLError{Number: 1213}
+	errOther := errors.New("other")
+
+	cc := []struct {
+		name     string
+		retries  int
+		errs     []error
+		expErr   error
+		expCalls int
+	}{
+		{name: "no retry", errs: []error{deadlock}, expErr: deadlock, expCalls: 1},
+		{name: "success", retries: 3, errs: []error{nil}, expCalls: 1},
+		{name: "retried", retries: 3, errs: []error{deadlock, deadlock, nil}, expCalls: 3},
+		{name: "exhausted", retries: 2, errs: []error{deadlock, deadlock, deadlock, nil}, expErr: deadlock, expCalls: 3},
+		{name: "not retryable", retries: 3, errs: []error{errOther, nil}, expErr: errOther, expCalls: 1},
+	}
+
+	for _, c := range cc {
+		t.Run(c.name, func(t *testing.T) {
+			var o options
+			WithRetry(c.retries, nil)(&o)
+
+			var calls int
+			err := o.retry(context.Background(), func() error {
+				calls++
+				return c.errs[calls-1]
+			})
+			require.Equal(t, c.expErr, err)
+			require.Equal(t, c.expCalls, calls)
+		})
+	}
+}
+
+func TestWrapDuplicate(t *testing.T) {
+	dup := &mysql.MySQLError{Number: 1062, Message: "Duplicate entry '1' for key 'PRIMARY'"}
+
+	err := wrapDuplicate(dup)
+	require.True(t, errors.Is(err, ErrDuplicate))
+	require.True(t, errors.Is(err, dup))
+
+	var myErr *mysql.MySQLError
+	require.True(t, errors.As(err, &myErr))
+	require.Equal(t, uint16(1062), myErr.Number)
+
+	errOther := errors.New("other")
+	require.Equal(t, errOther, wrapDuplicate(errOther))
+	require.False(t, errors.Is(wrapDuplicate(&mysql.MySQLError{Number: 1213}), ErrDuplicate))
+}
+
+func TestEventsDBC(t *testing.T) {
+	tx, etx := new(sql.Tx), new(sql.Tx)
+
+	require.Same(t, tx, eventsDBC(context.Background(), tx))
+
+	ctx := context.WithValue(context.Background(), eventsTxKey{}, etx)
+	require.Same(t, etx, eventsDBC(ctx, tx))
+}
+
+func TestRetry_EventsNotCommitted(t *testing.T) {
+	var o options
+	WithRetry(3, func(error) bool { return true })(&o)
+
+	var calls int
+	err := o.retry(context.Background(), func() error {
+		calls++
+		return errors.Wrap(errors.New("commit failed"), "commit events tx", j.C(codeEventsNotCommitted))
+	})
+	require.True(t, errors.Is(err, ErrEventsNotCommitted))
+	require.Equal(t, 1, calls)
+}
+
+type countingBeginner struct {
+	begins int
+}
+
+func (b *countingBeginner) BeginTx(context.Context, *sql.TxOptions) (*sql.Tx, error) {
+	b.begins++
+	return nil, errors.New("unexpected begin")
+}
+
+type otherInsert struct {
+	validatingInsert
+}
+
+func TestInvalidTransitionNoTx(t *testing.T) {
+	ctx := context.Background()
+	var updated bool
+	upd := validatingUpdate{updated: &updated}
+
+	fsm := NewFSM(new(fakeEvents)).
+		Insert(testStatus(1), validatingInsert{}, testStatus(2)).
+		Update(testStatus(2), upd).
+		Build()
+
+	arc := NewArcFSM(new(fakeEvents)).
+		Insert(testStatus(1), validatingInsert{}).
+		Update(testStatus(1), testStatus(2), upd).
+		Build()
+
+	cases := []struct {
+		name   string
+		call   func(dbc Beginner) error
+		expErr error
+	}{
+		{
+			name: "fsm insert",
+			call: func(dbc Beginner) error {
+				_, err := fsm.Insert(ctx, dbc, otherInsert{})
+				return err
+			},
+			expErr: ErrInvalidType,
+		},
+		{
+			name: "fsm insert batch",
+			call: func(dbc Beginner) error {
+				_, err := fsm.InsertBatch(ctx, dbc, plainBatch{})
+				return err
+			},
+			expErr: ErrInvalidType,
+		},
+		{
+			name:   "fsm update",
+			call:   func(dbc Beginner) error { return fsm.Update(ctx, dbc, testStatus(2), testStatus(1), upd) },
+			expErr: ErrInvalidType,
+		},
+		{
+			name:   "fsm already in state",
+			call:   func(dbc Beginner) error { return fsm.Update(ctx, dbc, testStatus(2), testStatus(2), upd) },
+			expErr: ErrAlreadyInState,
+		},
+		{
+			name:   "fsm unknown status",
+			call:   func(dbc Beginner) error { return fsm.Update(ctx, dbc, testStatus(2), testStatus(3), upd) },
+			expErr: ErrUnknownStatus,
+		},
+		{
+			name:   "fsm delete",
+			call:   func(dbc Beginner) error { return fsm.Delete(ctx, dbc, testStatus(1), nil) },
+			expErr: ErrInvalidStateTransition,
+		},
+		{
+			name: "arc insert",
+			call: func(dbc Beginner) error {
+				_, err := arc.Insert(ctx, dbc, testStatus(2), validatingInsert{})
+				return err
+			},
+			expErr: ErrInvalidStateTransition,
+		},
+		{
+			name:   "arc update",
+			call:   func(dbc Beginner) error { return arc.Update(ctx, dbc, testStatus(2), testStatus(1), upd) },
+			expErr: ErrInvalidStateTransition,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dbc := new(countingBeginner)
+			err := c.call(dbc)
+			require.True(t, errors.Is(err, c.expErr), err)
+			require.Zero(t, dbc.begins)
+		})
+	}
+}
+
+func TestUpdateMany_Invalid(t *testing.T) {
+	ctx := context.Background()
+	var updated bool
+	upd := validatingUpdate{updated: &updated}
+
+	build := func(opts ...option) *FSM {
+		return NewFSM(new(fakeEvents), opts...).
+			Insert(testStatus(1), validatingInsert{}, testStatus(2)).
+			Update(testStatus(2), upd).
+			Build()
+	}
+
+	cases := []struct {
+		name   string
+		fsm    *FSM
+		to     Status
+		expErr error
+	}{
+		{name: "no table", fsm: build(), to: testStatus(2), expErr: ErrInvalidFSM},
+		{name: "metadata", fsm: build(WithTable("users"), WithMetadata()), to: testStatus(2), expErr: ErrInvalidType},
+		{name: "validation", fsm: build(WithTable("users"), WithValidation()), to: testStatus(2), expErr: ErrInvalidType},
+		{name: "already in state", fsm: build(WithTable("users")), to: testStatus(1), expErr: ErrAlreadyInState},
+		{name: "unknown status", fsm: build(WithTable("users")), to: testStatus(3), expErr: ErrUnknownStatus},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dbc := new(countingBeginner)
+			_, _, err := c.fsm.UpdateMany(ctx, dbc, testStatus(1), c.to, []int64{1, 2})
+			require.True(t, errors.Is(err, c.expErr), err)
+			require.Zero(t, dbc.begins)
+		})
+	}
+}
+
+func TestUpdateMany_Empty(t *testing.T) {
+	events := new(fakeEvents)
+	fsm := NewFSM(events, WithTable("users")).
+		Insert(testStatus(1), validatingInsert{}, testStatus(2)).
+		Update(testStatus(2), validatingUpdate{}).
+		Build()
+
+	n, skipped, notify, err := fsm.UpdateManyTx(context.Background(), nil, testStatus(1), testStatus(2), nil)
+	require.NoError(t, err)
+	require.Zero(t, n)
+	require.Empty(t, skipped)
+	require.NotNil(t, notify)
+	require.Empty(t, events.metadata)
+}
+
+func TestChunkIDs(t *testing.T) {
+	require.Empty(t, chunkIDs([]int64{}))
+
+	ids := make([]int64, 2*updateManyChunk+1)
+	chunks := chunkIDs(ids)
+	require.Len(t, chunks, 3)
+	require.Len(t, chunks[0], updateManyChunk)
+	require.Len(t, chunks[1], updateManyChunk)
+	require.Len(t, chunks[2], 1)
+}
+
+type plainBatch []plainInsert
+
+func (plainBatch) InsertBatch(context.Context, *sql.Tx, Status) ([]int64, error) { return nil, nil }
+
+func TestInsertBatch_Retry(t *testing.T) {
+	fsm := NewFSM(new(fakeEvents), WithRetry(2, func(error) bool { return true })).
+		Insert(testStatus(1), plainInsert{}).
+		Build()
+
+	dbc := new(countingBeginner)
+	_, err := fsm.InsertBatch(context.Background(), dbc, plainBatch{})
+	require.Error(t, err)
+	require.Equal(t, 3, dbc.begins)
+}
+
+type replayedInsert struct{}
+
+func (replayedInsert) Insert(context.Context, *sql.Tx, Status) (int64, error) {
+	return 5, errors.Wrap(ErrAlreadyInserted, "replayed")
+}
+
+func TestInsertTx_Replayed(t *testing.T) {
+	events := new(fakeEvents)
+	fsm := NewFSM(events).
+		Insert(testStatus(1), replayedInsert{}).
+		Build()
+
+	id, notify, err := fsm.InsertTx(context.Background(), nil, replayedInsert{})
+	require.NoError(t, err)
+	require.Equal(t, int64(5), id)
+	require.NotNil(t, notify)
+	require.Empty(t, events.metadata)
+}
@@ -0,0 +1,62 @@
+package shift
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/luno/jettison/errors"
+	"github.com/luno/jettison/jtest"
+	"github.com/stretchr/testify/require"
+)
+
+var errInvalid = errors.New("invalid")
+
+type validatingInsert struct{}
+
+func (validatingInsert) Insert(context.Context, *sql.Tx, Status) (int64, error) { return 1, nil }
+
+func (validatingInsert) Validate(context.Context, *sql.Tx, int64, Status) error { return errInvalid }
+
+type validatingUpdate struct {
+	updated *bool
+}
+
+func (u validatingUpdate) Update(context.Context, *sql.Tx, Status, Status) (int64, error) {
+	*u.updated = true
+	return 1, nil
+}
+
+func (validatingUpdate) Validate(context.Context, *sql.Tx, Status, Status) error { return errInvalid }
+
+func TestWithValidationBefore(t *testing.T) {
+	ctx := context.Background()
+
+	cases := []struct {
+		name    string
+		opt     option
+		events  int
+		updated bool
+	}{
+		{name: "after", opt: WithValidation(), events: 1, updated: true},
+		{name: "before", opt: WithValidationBefore(), events: 0, updated: false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var o options
+			c.opt(&o)
+
+			events := new(fakeEvents)
+			_, _, err := insertTx[int64](ctx, nil, testStatus(1), validatingInsert{}, events, testStatus(1), o)
+			jtest.Require(t, errInvalid, err)
+			require.Len(t, events.metadata, c.events)
+
+			events = new(fakeEvents)
+			var updated bool
+			_, err = updateTx[int64](ctx, nil, testStatus(1), testStatus(2), validatingUpdate{updated: &updated}, events, testStatus(2), "", o)
+			jtest.Require(t, errInvalid, err)
+			require.Len(t, events.metadata, c.events)
+			require.Equal(t, c.updated, updated)
+		})
+	}
+}